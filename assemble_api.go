@@ -16,11 +16,103 @@ type ListingEntry = internal.ListingEntry
 // Error provides source-location context for parse and assembly failures.
 type Error = internal.Error
 
+// ErrorList is returned when assembly finds more than one error; errors.As
+// reaches each *Error in it.
+type ErrorList = internal.ErrorList
+
+// Code is the stable class of an Error that tools can match on instead of
+// its message.
+type Code = internal.Code
+
+// Error codes; Codes lists them.
+const (
+	CodeSyntax            = internal.CodeSyntax
+	CodeUnknownMnemonic   = internal.CodeUnknownMnemonic
+	CodeUnknownDirective  = internal.CodeUnknownDirective
+	CodeInvalidSize       = internal.CodeInvalidSize
+	CodeInvalidOperand    = internal.CodeInvalidOperand
+	CodeOutOfRange        = internal.CodeOutOfRange
+	CodeUndefinedSymbol   = internal.CodeUndefinedSymbol
+	CodeRedefinedSymbol   = internal.CodeRedefinedSymbol
+	CodeInvalidExpression = internal.CodeInvalidExpression
+	CodeInvalidDirective  = internal.CodeInvalidDirective
+	CodeUnbalancedBlock   = internal.CodeUnbalancedBlock
+	CodeMacro             = internal.CodeMacro
+	CodeSection           = internal.CodeSection
+	CodeLayout            = internal.CodeLayout
+	CodeFile              = internal.CodeFile
+	CodeUser              = internal.CodeUser
+	CodeInternal          = internal.CodeInternal
+)
+
+// Codes lists every error code.
+var Codes = internal.Codes
+
+// ErrorCode returns the code of err, or of the first error of an ErrorList.
+func ErrorCode(err error) Code { return internal.ErrorCode(err) }
+
+// DefaultMaxErrors is the number of errors reported when
+// ParseOptions.MaxErrors is 0.
+const DefaultMaxErrors = internal.DefaultMaxErrors
+
+// MacroFrame is one "in expansion of macro X at line N" step of an Error's
+// macro expansion backtrace.
+type MacroFrame = internal.MacroFrame
+
+// Diagnostic is a warning raised while assembling, with the position rules
+// of Error and the category that turns it off.
+type Diagnostic = internal.Diagnostic
+
+// Severity tells warnings from errors in a Diagnostic.
+type Severity = internal.Severity
+
+// Category names a class of warnings that ParseOptions.Warnings switches.
+type Category = internal.Category
+
+// Diagnostic severities.
+const (
+	SeverityWarning = internal.SeverityWarning
+	SeverityError   = internal.SeverityError
+)
+
+// Warning categories; WarningCategories lists them with their defaults.
+const (
+	WarnOddAddress   = internal.WarnOddAddress
+	WarnBranchNext   = internal.WarnBranchNext
+	WarnUnused       = internal.WarnUnused
+	WarnShadow       = internal.WarnShadow
+	WarnTruncation   = internal.WarnTruncation
+	WarnOrgGap       = internal.WarnOrgGap
+	WarnImplicitSize = internal.WarnImplicitSize
+	WarnInform       = internal.WarnInform
+	WarnPIC          = internal.WarnPIC
+)
+
+// WarningCategories lists every warning category with whether it is on by
+// default.
+var WarningCategories = internal.WarningCategories
+
 // ParseOptions controls parser customization for all public assembly helpers.
-// Symbols predefines label values, and InstrTable lets advanced callers supply
-// an alternate instruction table.
+// Symbols predefines label values, InstrTable lets advanced callers supply
+// an alternate instruction table, and Syntax selects the source dialect.
 type ParseOptions = internal.ParseOptions
 
+// Syntax selects the source dialect accepted by the parser.
+type Syntax = internal.Syntax
+
+// Supported source dialects.
+const (
+	SyntaxMotorola = internal.SyntaxMotorola
+	SyntaxGAS      = internal.SyntaxGAS
+	SyntaxDevpac   = internal.SyntaxDevpac
+	SyntaxASM68K   = internal.SyntaxASM68K
+)
+
+// ParseSyntax returns the dialect with the given name ("motorola", "gas", "devpac" or "asm68k").
+func ParseSyntax(name string) (Syntax, error) {
+	return internal.ParseSyntax(name)
+}
+
 // Assemble parses Motorola 68k assembly source from r and returns the encoded
 // machine code bytes. The program origin, if specified via directives, is
 // accounted for in the parser but the returned slice contains only the
@@ -395,3 +487,77 @@ func AssembleFileSRecordWithOptions(path string, opts ParseOptions) ([]byte, err
 func srecHeader() string {
 	return "m68kasm " + Version
 }
+
+// AssembleIntelHex parses Motorola 68k assembly source from r and returns an
+// Intel HEX representation with 32-bit linear addresses.
+func AssembleIntelHex(r io.Reader) ([]byte, error) {
+	return AssembleIntelHexWithOptions(r, ParseOptions{})
+}
+
+// AssembleIntelHexWithOptions parses Motorola 68k assembly source from r
+// using the supplied parsing options and returns an Intel HEX representation.
+func AssembleIntelHexWithOptions(r io.Reader, opts ParseOptions) ([]byte, error) {
+	prog, err := internal.ParseWithOptions(r, internal.ParseOptions(opts))
+	if err != nil {
+		return nil, err
+	}
+
+	return internal.AssembleIntelHex(prog)
+}
+
+// AssembleBytesIntelHex assembles Motorola 68k source provided as a byte
+// slice and returns an Intel HEX representation.
+func AssembleBytesIntelHex(src []byte) ([]byte, error) {
+	return AssembleIntelHex(bytes.NewReader(src))
+}
+
+// AssembleBytesIntelHexWithOptions assembles Motorola 68k source provided as
+// a byte slice using the supplied parsing options and returns an Intel HEX
+// representation.
+func AssembleBytesIntelHexWithOptions(src []byte, opts ParseOptions) ([]byte, error) {
+	return AssembleIntelHexWithOptions(bytes.NewReader(src), opts)
+}
+
+// AssembleStringIntelHex assembles Motorola 68k source provided as a string
+// and returns an Intel HEX representation.
+func AssembleStringIntelHex(src string) ([]byte, error) {
+	return AssembleIntelHex(strings.NewReader(src))
+}
+
+// AssembleStringIntelHexWithOptions assembles Motorola 68k source provided as
+// a string using the supplied parsing options and returns an Intel HEX
+// representation.
+func AssembleStringIntelHexWithOptions(src string, opts ParseOptions) ([]byte, error) {
+	return AssembleIntelHexWithOptions(strings.NewReader(src), opts)
+}
+
+// AssembleFileIntelHex assembles a Motorola 68k source file specified by path
+// and returns an Intel HEX representation.
+func AssembleFileIntelHex(path string) ([]byte, error) {
+	return AssembleFileIntelHexWithOptions(path, ParseOptions{})
+}
+
+// AssembleFileIntelHexWithOptions assembles a Motorola 68k source file
+// specified by path using the supplied parsing options and returns an Intel
+// HEX representation.
+func AssembleFileIntelHexWithOptions(path string, opts ParseOptions) ([]byte, error) {
+	prog, err := internal.ParseFileWithOptions(path, internal.ParseOptions(opts))
+	if err != nil {
+		return nil, err
+	}
+	return internal.AssembleIntelHex(prog)
+}
+
+// Preprocess writes the source read from r after conditional assembly and
+// macro expansion, one statement per line, with "# line file" markers that map
+// the output back to the original source. name is the file name used in the
+// markers. The output assembles to the same bytes as the original.
+func Preprocess(w io.Writer, r io.Reader, name string, opts ParseOptions) error {
+	return internal.Preprocess(w, r, name, internal.ParseOptions(opts))
+}
+
+// PreprocessFile preprocesses the source file specified by path like
+// Preprocess.
+func PreprocessFile(w io.Writer, path string, opts ParseOptions) error {
+	return internal.PreprocessFile(w, path, internal.ParseOptions(opts))
+}
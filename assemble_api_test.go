@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -53,7 +54,7 @@ func TestAssembleString(t *testing.T) {
 		t.Fatalf("assemble failed: %v", err)
 	}
 
-	want := []byte{0x00, 0x00, 0x10, 0x39, 0x00, 0x00, 0x00, 0x00}
+	want := []byte{0x00, 0x00, 0x10, 0x38, 0x00, 0x00}
 	if !bytes.Equal(got, want) {
 		t.Fatalf("unexpected encoding: got %x want %x", got, want)
 	}
@@ -69,7 +70,7 @@ func TestAssembleStringInto(t *testing.T) {
 		t.Fatalf("assemble failed: %v", err)
 	}
 
-	want := []byte{0xff, 0x00, 0x00, 0x10, 0x39, 0x00, 0x00, 0x00, 0x00}
+	want := []byte{0xff, 0x00, 0x00, 0x10, 0x38, 0x00, 0x00}
 	if !bytes.Equal(out, want) {
 		t.Fatalf("unexpected encoding: got %x want %x", out, want)
 	}
@@ -86,7 +87,7 @@ func TestAssembleStringWithListing(t *testing.T) {
 		t.Fatalf("assemble failed: %v", err)
 	}
 
-	wanted := []byte{0x00, 0x00, 0x10, 0x39, 0x00, 0x00, 0x00, 0x00}
+	wanted := []byte{0x00, 0x00, 0x10, 0x38, 0x00, 0x00}
 	if !bytes.Equal(got, wanted) {
 		t.Fatalf("unexpected encoding: got %x want %x", got, wanted)
 	}
@@ -99,7 +100,7 @@ func TestAssembleStringWithListing(t *testing.T) {
 		t.Fatalf("unexpected first listing entry: %+v", listing[0])
 	}
 
-	if listing[1].Line != 3 || listing[1].PC != 2 || !bytes.Equal(listing[1].Bytes, []byte{0x10, 0x39, 0x00, 0x00, 0x00, 0x00}) {
+	if listing[1].Line != 3 || listing[1].PC != 2 || !bytes.Equal(listing[1].Bytes, []byte{0x10, 0x38, 0x00, 0x00}) || !reflect.DeepEqual(listing[1].Sizes, []string{"abs.W"}) {
 		t.Fatalf("unexpected second listing entry: %+v", listing[1])
 	}
 }
@@ -305,6 +306,72 @@ func TestAssembleStringDetailed(t *testing.T) {
 	}
 }
 
+func TestAssembleStringDetailedScopedLocals(t *testing.T) {
+	src := "main:\n.loop: NOP\nBRA.S .loop\nsub:\n.loop: RTS\n"
+
+	result, err := AssembleStringDetailed(src)
+	if err != nil {
+		t.Fatalf("assemble failed: %v", err)
+	}
+	for name, want := range map[string]uint32{"main.loop": 0, "sub.loop": 4} {
+		if got, ok := result.AddressOf(name); !ok || got != want {
+			t.Fatalf("unexpected %s label: got 0x%X ok=%v", name, got, ok)
+		}
+	}
+	if _, ok := result.AddressOf(".loop"); ok {
+		t.Fatalf("local label stored unqualified")
+	}
+}
+
+func TestAssembleStringDetailedSymbolicOperands(t *testing.T) {
+	src := "OFF EQU 4\nstart: MOVE.W #OFF*2,D0\nMOVE.L table+OFF(PC),D1\nLEA (start).L,A0\ntable: DC.L 0\n"
+
+	result, err := AssembleStringDetailed(src)
+	if err != nil {
+		t.Fatalf("assemble failed: %v", err)
+	}
+	want := []struct{ canonical, symbolic string }{
+		{"MOVE.W #$8,D0", "MOVE.W #OFF*2,D0"},
+		{"MOVE.L $12(PC),D1", "MOVE.L table+OFF(PC),D1"},
+		{"LEA.L ($00000000).L,A0", "LEA.L (start).L,A0"},
+	}
+	if len(result.Instructions) != len(want) {
+		t.Fatalf("expected %d instructions, got %d", len(want), len(result.Instructions))
+	}
+	for i, w := range want {
+		md := result.Instructions[i]
+		if md.Canonical != w.canonical || md.Symbolic != w.symbolic {
+			t.Fatalf("instruction %d: got %q / %q want %q / %q", i, md.Canonical, md.Symbolic, w.canonical, w.symbolic)
+		}
+	}
+}
+
+func TestAssembleStringDetailedWarnings(t *testing.T) {
+	src := "MOVE.W $1001,D0\nCLR D1\n"
+
+	result, err := AssembleStringDetailedWithOptions(src, ParseOptions{Warnings: map[string]bool{"implicit-size": true}})
+	if err != nil {
+		t.Fatalf("assemble failed: %v", err)
+	}
+	if len(result.Warnings) != 2 {
+		t.Fatalf("expected 2 warnings, got %v", result.Warnings)
+	}
+	if w := result.Warnings[0]; w.Category != WarnOddAddress || w.Severity != SeverityWarning || w.Line != 1 {
+		t.Fatalf("unexpected first warning %+v", w)
+	}
+	if w := result.Warnings[1]; w.Category != WarnImplicitSize || w.Line != 2 {
+		t.Fatalf("unexpected second warning %+v", w)
+	}
+	if !reflect.DeepEqual(result.Listing[0].Sizes, []string{"abs.W"}) {
+		t.Fatalf("listing sizes = %v, want [abs.W]", result.Listing[0].Sizes)
+	}
+
+	_, err = AssembleStringDetailedWithOptions(src, ParseOptions{WarningsAsErrors: true})
+	if got := NormalizeError(err); got != ".W access to odd address $1001 [-Werror=odd-address]" {
+		t.Fatalf("unexpected -Werror result %q", got)
+	}
+}
+
 func TestProgramBuilder(t *testing.T) {
 	builder := NewProgramBuilder().
 		Origin(0x1000).
@@ -473,6 +540,60 @@ func TestAssembleSRecordVariants(t *testing.T) {
 	}
 }
 
+func TestAssembleStringIntelHexWithOptions(t *testing.T) {
+	src := ".org 0x1000\n.byte FOO\n"
+	opts := ParseOptions{Symbols: map[string]uint32{"FOO": 0x11}}
+
+	got, err := AssembleStringIntelHexWithOptions(src, opts)
+	if err != nil {
+		t.Fatalf("assemble failed: %v", err)
+	}
+
+	want := ":0110000011DE\n:0400000500001000E7\n:00000001FF\n"
+	if string(got) != want {
+		t.Fatalf("unexpected Intel HEX output:\n%s\nwant:\n%s", string(got), want)
+	}
+}
+
+func TestAssembleIntelHexVariants(t *testing.T) {
+	src := ".org 0x1000\n.byte 0x11,0x22,0x33\n"
+
+	stringHex, err := AssembleStringIntelHex(src)
+	if err != nil {
+		t.Fatalf("assemble string failed: %v", err)
+	}
+
+	bytesHex, err := AssembleBytesIntelHex([]byte(src))
+	if err != nil {
+		t.Fatalf("assemble bytes failed: %v", err)
+	}
+
+	readerHex, err := AssembleIntelHex(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("assemble reader failed: %v", err)
+	}
+
+	tempFile := filepath.Join(t.TempDir(), "sample.s")
+	if err := os.WriteFile(tempFile, []byte(src), 0o644); err != nil {
+		t.Fatalf("write temp source: %v", err)
+	}
+
+	fileHex, err := AssembleFileIntelHex(tempFile)
+	if err != nil {
+		t.Fatalf("assemble file failed: %v", err)
+	}
+
+	for name, got := range map[string][]byte{
+		"bytes":  bytesHex,
+		"reader": readerHex,
+		"file":   fileHex,
+	} {
+		if string(got) != string(stringHex) {
+			t.Fatalf("%s Intel HEX mismatch:\n%s\nwant:\n%s", name, string(got), string(stringHex))
+		}
+	}
+}
+
 func TestAssembleELFWithOptions(t *testing.T) {
 	src := ".org ENTRY\nMOVEQ #1,D0\n"
 	opts := ParseOptions{Symbols: map[string]uint32{"ENTRY": 0x2000}}
@@ -495,7 +616,7 @@ func TestAssembleFileVariants(t *testing.T) {
 		t.Fatalf("assemble file failed: %v", err)
 	}
 
-	if want := []byte{0x12, 0x34, 0x70, 0x01, 0xc0, 0xfc, 0x81, 0xfc}; !bytes.Equal(bytesOut, want) {
+	if want := []byte{0x12, 0x34, 0x70, 0x01, 0xc0, 0xfc, 0x00, 0x02, 0x81, 0xfc, 0x00, 0x02}; !bytes.Equal(bytesOut, want) {
 		t.Fatalf("unexpected encoding: got %x want %x", bytesOut, want)
 	}
 
@@ -76,6 +76,16 @@ func parseSingleInstruction(src string, opts ParseOptions) (*internal.Program, *
 }
 
 func canonicalInstruction(ins *internal.Instr) string {
+	return formatInstruction(ins, false)
+}
+
+// symbolicInstruction spells the instruction like canonicalInstruction but
+// with operand values written as their source expressions.
+func symbolicInstruction(ins *internal.Instr) string {
+	return formatInstruction(ins, true)
+}
+
+func formatInstruction(ins *internal.Instr, symbolic bool) string {
 	if ins == nil || ins.Def == nil {
 		return ""
 	}
@@ -85,7 +95,7 @@ func canonicalInstruction(ins *internal.Instr) string {
 		mnemonic += "." + sizeSuffix(ins.Args.Size)
 	}
 
-	operands := canonicalOperands(ins)
+	operands := canonicalOperands(ins, symbolic)
 	if len(operands) == 0 {
 		return mnemonic
 	}
@@ -96,7 +106,7 @@ func hasExplicitInstructionSize(ins *internal.Instr) bool {
 	return ins != nil && ins.Form != nil && len(ins.Form.Sizes) > 0
 }
 
-func canonicalOperands(ins *internal.Instr) []string {
+func canonicalOperands(ins *internal.Instr, symbolic bool) []string {
 	if ins == nil || ins.Form == nil {
 		return nil
 	}
@@ -104,15 +114,13 @@ func canonicalOperands(ins *internal.Instr) []string {
 	ops := make([]string, 0, len(ins.Form.OperKinds))
 	for i, kind := range ins.Form.OperKinds {
 		switch kind {
-		case instructions.OpkImm:
-			ops = append(ops, "#"+formatSignedHex(ins.Args.Src.Imm))
-		case instructions.OpkImmQuick:
-			ops = append(ops, "#"+formatSignedHex(ins.Args.Src.Imm))
+		case instructions.OpkImm, instructions.OpkImmQuick:
+			ops = append(ops, "#"+formatValue(ins.Args.Src.Expr, formatSignedHex(ins.Args.Src.Imm), symbolic))
 		case instructions.OpkDn, instructions.OpkAn, instructions.OpkSR, instructions.OpkCCR, instructions.OpkUSP, instructions.OpkEA, instructions.OpkPredecAn:
 			if i == 0 {
-				ops = append(ops, formatEA(ins.Args.Src))
+				ops = append(ops, formatEA(ins.Args.Src, symbolic))
 			} else {
-				ops = append(ops, formatEA(ins.Args.Dst))
+				ops = append(ops, formatEA(ins.Args.Dst, symbolic))
 			}
 		case instructions.OpkRegList:
 			if i == 0 {
@@ -124,13 +132,13 @@ func canonicalOperands(ins *internal.Instr) []string {
 			if ins.Args.Target != "" {
 				ops = append(ops, ins.Args.Target)
 			} else {
-				ops = append(ops, formatSignedHex(ins.Args.TargetAddr))
+				ops = append(ops, formatValue(ins.Args.TargetExpr, formatSignedHex(ins.Args.TargetAddr), symbolic))
 			}
 		default:
 			if i == 0 {
-				ops = append(ops, formatEA(ins.Args.Src))
+				ops = append(ops, formatEA(ins.Args.Src, symbolic))
 			} else {
-				ops = append(ops, formatEA(ins.Args.Dst))
+				ops = append(ops, formatEA(ins.Args.Dst, symbolic))
 			}
 		}
 	}
@@ -150,10 +158,19 @@ func sizeSuffix(sz instructions.Size) string {
 	}
 }
 
-func formatEA(e instructions.EAExpr) string {
+// formatValue returns the source expression when symbolic is set and one is
+// known, and the formatted number otherwise.
+func formatValue(expr instructions.Expr, number string, symbolic bool) string {
+	if symbolic && expr != nil {
+		return expr.String()
+	}
+	return number
+}
+
+func formatEA(e instructions.EAExpr, symbolic bool) string {
 	switch e.Kind {
 	case instructions.EAkImm:
-		return "#" + formatSignedHex(e.Imm)
+		return "#" + formatValue(e.Expr, formatSignedHex(e.Imm), symbolic)
 	case instructions.EAkDn:
 		return formatDataRegister(e.Reg)
 	case instructions.EAkAn:
@@ -165,17 +182,17 @@ func formatEA(e instructions.EAExpr) string {
 	case instructions.EAkAddrInd:
 		return "(" + formatAddrRegister(e.Reg) + ")"
 	case instructions.EAkAddrDisp16:
-		return formatSignedHex(int64(e.Disp16)) + "(" + formatAddrRegister(e.Reg) + ")"
+		return formatValue(e.Expr, formatSignedHex(int64(e.Disp16)), symbolic) + "(" + formatAddrRegister(e.Reg) + ")"
 	case instructions.EAkPCDisp16:
-		return formatSignedHex(int64(e.Disp16)) + "(PC)"
+		return formatValue(e.Expr, formatSignedHex(int64(e.Disp16)), symbolic) + "(PC)"
 	case instructions.EAkIdxAnBrief:
-		return formatIndexAddress(formatAddrRegister(e.Reg), e.Index)
+		return formatIndexAddress(formatAddrRegister(e.Reg), e, symbolic)
 	case instructions.EAkIdxPCBrief:
-		return formatIndexAddress("PC", e.Index)
+		return formatIndexAddress("PC", e, symbolic)
 	case instructions.EAkAbsW:
-		return "(" + formatUint32Hex(uint32(e.Abs16), 4) + ").W"
+		return "(" + formatValue(e.Expr, formatUint32Hex(uint32(e.Abs16), 4), symbolic) + ").W"
 	case instructions.EAkAbsL:
-		return "(" + formatUint32Hex(e.Abs32, 8) + ").L"
+		return "(" + formatValue(e.Expr, formatUint32Hex(e.Abs32, 8), symbolic) + ").L"
 	case instructions.EAkSR:
 		return "SR"
 	case instructions.EAkCCR:
@@ -187,8 +204,9 @@ func formatEA(e instructions.EAExpr) string {
 	}
 }
 
-func formatIndexAddress(base string, ix instructions.EAIndex) string {
-	return formatSignedHex(int64(ix.Disp8)) + "(" + base + "," + formatIndexRegister(ix) + ")"
+func formatIndexAddress(base string, e instructions.EAExpr, symbolic bool) string {
+	disp := formatValue(e.Expr, formatSignedHex(int64(e.Index.Disp8)), symbolic)
+	return disp + "(" + base + "," + formatIndexRegister(e.Index) + ")"
 }
 
 func formatIndexRegister(ix instructions.EAIndex) string {
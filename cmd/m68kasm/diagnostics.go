@@ -0,0 +1,239 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"github.com/jenska/m68kasm"
+	"github.com/jenska/m68kasm/internal/asm"
+)
+
+// diagnostic is one warning or error of a run in the shape
+// --diagnostics-format=json writes it. Columns are 1-based; EndColumn is
+// the column just past the text the diagnostic is about.
+type diagnostic struct {
+	File      string       `json:"file"`
+	Line      int          `json:"line,omitempty"`
+	Column    int          `json:"column,omitempty"`
+	EndColumn int          `json:"end_column,omitempty"`
+	Severity  string       `json:"severity"`
+	Code      string       `json:"code"`
+	Message   string       `json:"message"`
+	Hint      string       `json:"hint,omitempty"`
+	Backtrace []stackFrame `json:"backtrace,omitempty"`
+}
+
+// stackFrame is a macro invocation that led to a diagnostic.
+type stackFrame struct {
+	Macro  string `json:"macro"`
+	File   string `json:"file"`
+	Line   int    `json:"line"`
+	Column int    `json:"column,omitempty"`
+}
+
+// diagnosticReport collects the warnings and errors of a run. In the text
+// format they are printed as they come; json and sarif write one document
+// to stderr once the run has passed or failed.
+type diagnosticReport struct {
+	format  string
+	file    string
+	out     io.Writer
+	diags   []diagnostic
+	limited bool
+}
+
+func isDiagnosticsFormat(format string) bool {
+	return format == "text" || format == "json" || format == "sarif"
+}
+
+// warnings reports the warnings of a successful parse.
+func (r *diagnosticReport) warnings(ws []asm.Diagnostic) {
+	for _, w := range ws {
+		if r.format == "text" {
+			fmt.Fprintln(r.out, w)
+			continue
+		}
+		r.diags = append(r.diags, diagnostic{
+			File:      r.file,
+			Line:      w.Line,
+			Column:    w.Col,
+			Severity:  w.Severity.String(),
+			Code:      string(w.Category),
+			Message:   w.Message,
+			Backtrace: r.backtrace(w.Expansions),
+		})
+	}
+}
+
+// fail reports the errors of a failed run and, for json and sarif, writes
+// the document.
+func (r *diagnosticReport) fail(err error) {
+	if r.format == "text" {
+		printErrors(err)
+		return
+	}
+	errs := []error{err}
+	var list *asm.ErrorList
+	if errors.As(err, &list) {
+		errs, r.limited = list.Errors, list.Limited
+	}
+	for _, err := range errs {
+		r.diags = append(r.diags, r.errorDiagnostic(err))
+	}
+	r.flush()
+}
+
+func (r *diagnosticReport) errorDiagnostic(err error) diagnostic {
+	d := diagnostic{File: r.file, Severity: asm.SeverityError.String(), Code: string(asm.ErrorCode(err)), Message: err.Error()}
+	var e *asm.Error
+	if errors.As(err, &e) {
+		d.Line, d.Column, d.EndColumn = e.Line, e.Col, e.EndCol
+		d.Message, d.Hint = e.Message(), e.Hint
+		d.Backtrace = r.backtrace(e.Expansions)
+	}
+	return d
+}
+
+func (r *diagnosticReport) backtrace(frames []asm.MacroFrame) []stackFrame {
+	var out []stackFrame
+	for _, f := range frames {
+		out = append(out, stackFrame{Macro: f.Macro, File: r.file, Line: f.Line, Column: f.Col})
+	}
+	return out
+}
+
+// flush writes the json or sarif document.
+func (r *diagnosticReport) flush() {
+	var doc any
+	switch r.format {
+	case "json":
+		doc = struct {
+			Diagnostics []diagnostic `json:"diagnostics"`
+			// Limited is set when --max-errors left errors out.
+			Limited bool `json:"limited,omitempty"`
+		}{append([]diagnostic{}, r.diags...), r.limited}
+	case "sarif":
+		doc = sarifLog(r.diags)
+	default:
+		return
+	}
+	enc := json.NewEncoder(r.out)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		fmt.Println("diagnostics error:", err)
+	}
+}
+
+// SARIF 2.1.0, the subset needed for code scanning annotations.
+type (
+	sarifDocument struct {
+		Schema  string     `json:"$schema"`
+		Version string     `json:"version"`
+		Runs    []sarifRun `json:"runs"`
+	}
+	sarifRun struct {
+		Tool    sarifTool     `json:"tool"`
+		Results []sarifResult `json:"results"`
+	}
+	sarifTool struct {
+		Driver sarifDriver `json:"driver"`
+	}
+	sarifDriver struct {
+		Name    string      `json:"name"`
+		Version string      `json:"version"`
+		Rules   []sarifRule `json:"rules"`
+	}
+	sarifRule struct {
+		ID string `json:"id"`
+	}
+	sarifResult struct {
+		RuleID           string          `json:"ruleId"`
+		Level            string          `json:"level"`
+		Message          sarifMessage    `json:"message"`
+		Locations        []sarifLocation `json:"locations"`
+		RelatedLocations []sarifLocation `json:"relatedLocations,omitempty"`
+	}
+	sarifMessage struct {
+		Text string `json:"text"`
+	}
+	sarifLocation struct {
+		ID               int                   `json:"id,omitempty"`
+		PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+		Message          *sarifMessage         `json:"message,omitempty"`
+	}
+	sarifPhysicalLocation struct {
+		ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+		Region           *sarifRegion          `json:"region,omitempty"`
+	}
+	sarifArtifactLocation struct {
+		URI string `json:"uri"`
+	}
+	sarifRegion struct {
+		StartLine   int `json:"startLine"`
+		StartColumn int `json:"startColumn,omitempty"`
+		EndColumn   int `json:"endColumn,omitempty"`
+	}
+)
+
+// sarifLog turns diags into a SARIF log with one run. The code of each
+// diagnostic is its rule; macro invocations are related locations.
+func sarifLog(diags []diagnostic) sarifDocument {
+	driver := sarifDriver{Name: "m68kasm", Version: m68kasm.Version, Rules: []sarifRule{}}
+	rules := map[string]bool{}
+	results := []sarifResult{}
+	for _, d := range diags {
+		if !rules[d.Code] {
+			rules[d.Code] = true
+			driver.Rules = append(driver.Rules, sarifRule{ID: d.Code})
+		}
+		text := d.Message
+		if d.Hint != "" {
+			text += " (hint: " + d.Hint + ")"
+		}
+		res := sarifResult{
+			RuleID:    d.Code,
+			Level:     d.Severity,
+			Message:   sarifMessage{Text: text},
+			Locations: []sarifLocation{{PhysicalLocation: sarifPhysical(d.File, d.Line, d.Column, d.EndColumn)}},
+		}
+		for i, f := range d.Backtrace {
+			res.RelatedLocations = append(res.RelatedLocations, sarifLocation{
+				ID:               i + 1,
+				PhysicalLocation: sarifPhysical(f.File, f.Line, f.Column, 0),
+				Message:          &sarifMessage{Text: "in expansion of macro " + f.Macro},
+			})
+		}
+		results = append(results, res)
+	}
+	return sarifDocument{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{{Tool: sarifTool{Driver: driver}, Results: results}},
+	}
+}
+
+func sarifPhysical(file string, line, col, endCol int) sarifPhysicalLocation {
+	loc := sarifPhysicalLocation{ArtifactLocation: sarifArtifactLocation{URI: fileURI(file)}}
+	if line > 0 {
+		loc.Region = &sarifRegion{StartLine: line, StartColumn: col, EndColumn: endCol}
+	}
+	return loc
+}
+
+// fileURI turns a path into the URI SARIF expects: a file URI for absolute
+// paths, a relative reference otherwise.
+func fileURI(path string) string {
+	u := url.URL{Path: filepath.ToSlash(path)}
+	if filepath.IsAbs(path) {
+		u.Scheme = "file"
+		if !strings.HasPrefix(u.Path, "/") {
+			u.Path = "/" + u.Path
+		}
+	}
+	return u.String()
+}
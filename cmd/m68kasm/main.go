@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"io"
@@ -17,14 +18,26 @@ func main() {
 	in := flag.String("i", "", "input assembly file")
 	out := flag.String("o", "out.bin", "output binary file")
 	list := flag.String("list", "", "write listing output (use '-' for stdout)")
-	format := flag.String("format", "bin", "output format: bin, srec, or elf")
+	format := flag.String("format", "bin", "output format: bin, srec, ihex, or elf")
+	syntaxName := flag.String("syntax", "motorola", "source syntax: motorola, gas, devpac, or asm68k")
 	showVersion := flag.Bool("version", false, "print assembler version and exit")
+	allowTruncation := flag.Bool("allow-truncation", false, "keep the low bits of values that do not fit their field instead of failing")
+	pcRelative := flag.Bool("pcrel", false, "turn absolute source operands that name a code label into label(PC) where possible")
+	auditPIC := flag.Bool("pic-audit", false, "warn about absolute references to code labels")
+	longAbsolute := flag.Bool("abs-long", false, "keep absolute addresses without a size suffix at .L instead of picking .W where it reaches")
+	maxErrors := flag.Int("max-errors", asm.DefaultMaxErrors, "number of errors to report before leaving out the rest (0 for no limit)")
+	diagFormat := flag.String("diagnostics-format", "text", "how to report warnings and errors: text, or json or sarif written to stderr")
+	var preprocess bool
+	flag.BoolVar(&preprocess, "E", false, "write the source after macro expansion and conditional assembly to stdout (or -o)")
+	flag.BoolVar(&preprocess, "preprocess", false, "same as -E")
 	var includePaths multiFlag
 	defines := make(defineFlag)
+	warnings := warningFlag{enabled: map[string]bool{}}
 
 	flag.Var(&includePaths, "I", "add include search path")
 	flag.Var(&defines, "D", "define symbol (NAME or NAME=VALUE)")
-	flag.Parse()
+	flag.Var(&warnings, "W", "-W<name> enables and -Wno-<name> disables a warning category (or all); -Werror fails on warnings")
+	flag.CommandLine.Parse(splitWarningFlags(os.Args[1:]))
 
 	if *showVersion {
 		fmt.Printf("m68kasm %s\n", m68kasm.Version)
@@ -32,12 +45,21 @@ func main() {
 	}
 
 	fmtFormat := strings.ToLower(*format)
-	if fmtFormat != "bin" && fmtFormat != "srec" && fmtFormat != "elf" {
+	if fmtFormat != "bin" && fmtFormat != "srec" && fmtFormat != "ihex" && fmtFormat != "elf" {
 		fmt.Println("unknown format:", *format)
 		os.Exit(1)
 	}
+	if !isDiagnosticsFormat(*diagFormat) {
+		fmt.Println("unknown diagnostics format:", *diagFormat)
+		os.Exit(1)
+	}
+	syntax, err := asm.ParseSyntax(*syntaxName)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
 	if *in == "" {
-		fmt.Println("Usage: m68kasm -i input.s [-o out.bin] [--list out.lst] [--format bin|srec|elf] [-I path] [-D name[=val]]")
+		fmt.Println("Usage: m68kasm -i input.s [-o out.bin] [--list out.lst] [--format bin|srec|ihex|elf] [--syntax motorola|gas|devpac|asm68k] [-I path] [-D name[=val]] [-E] [--allow-truncation] [--abs-long] [--pcrel] [--pic-audit] [-W<name>] [-Wno-<name>] [-Werror] [--max-errors n] [--diagnostics-format text|json|sarif]")
 		os.Exit(1)
 	}
 	srcPath, err := resolveInputPath(*in, includePaths)
@@ -46,25 +68,54 @@ func main() {
 		os.Exit(1)
 	}
 
-	prog, err := asm.ParseFileWithOptions(srcPath, asm.ParseOptions{Symbols: defines})
+	opts := asm.ParseOptions{
+		Symbols:          defines,
+		Syntax:           syntax,
+		IncludeDirs:      includePaths,
+		Messages:         os.Stderr,
+		AllowTruncation:  *allowTruncation,
+		LongAbsolute:     *longAbsolute,
+		PCRelative:       *pcRelative,
+		AuditPIC:         *auditPIC,
+		Warnings:         warnings.enabled,
+		WarningsAsErrors: warnings.asErrors,
+		MaxErrors:        cliMaxErrors(*maxErrors),
+	}
+	report := &diagnosticReport{format: *diagFormat, file: srcPath, out: os.Stderr}
+	if report.format != "text" {
+		// Keep stderr for the diagnostics document.
+		opts.Messages = os.Stdout
+	}
+	if preprocess {
+		if err := writePreprocessed(srcPath, opts, explicitOutput()); err != nil {
+			report.fail(err)
+			os.Exit(2)
+		}
+		report.flush()
+		return
+	}
+
+	prog, err := asm.ParseFileWithOptions(srcPath, opts)
 	if err != nil {
-		fmt.Println("assemble error:", err)
+		report.fail(err)
 		os.Exit(2)
 	}
+	report.warnings(prog.Warnings)
 	var (
 		listing []asm.ListingEntry
 		bytes   []byte
 	)
-	wantListing := *list != "" || fmtFormat == "srec"
+	wantListing := *list != "" || fmtFormat == "srec" || fmtFormat == "ihex"
 	if wantListing {
 		bytes, listing, err = asm.AssembleWithListing(prog)
 	} else {
 		bytes, err = asm.Assemble(prog)
 	}
 	if err != nil {
-		fmt.Println("assemble error:", err)
+		report.fail(err)
 		os.Exit(3)
 	}
+	report.flush()
 	switch fmtFormat {
 
 	case "srec":
@@ -75,6 +126,12 @@ func main() {
 			os.Exit(4)
 		}
 		fmt.Printf("assembled %d bytes into S-record %s\n", len(bytes), *out)
+	case "ihex":
+		if err := os.WriteFile(*out, asm.FormatIntelHex(listing, prog.Origin), 0644); err != nil {
+			fmt.Println("write error:", err)
+			os.Exit(4)
+		}
+		fmt.Printf("assembled %d bytes into Intel HEX %s\n", len(bytes), *out)
 	case "elf":
 		elfBytes := asm.FormatELFWithLabels(bytes, prog.Origin, prog.DefinedLabels)
 		if err := os.WriteFile(*out, elfBytes, 0644); err != nil {
@@ -90,14 +147,67 @@ func main() {
 		fmt.Printf("wrote %d bytes to %s\n", len(bytes), *out)
 	}
 	if *list != "" {
-		if err := writeListing(*list, listing, srcPath); err != nil {
+		if err := writeListing(*list, listing, prog.DefinedLabels, srcPath); err != nil {
 			fmt.Println("listing error:", err)
 			os.Exit(5)
 		}
 	}
 }
 
-func writeListing(path string, entries []asm.ListingEntry, srcPath string) error {
+// printErrors prints every error of a failed run, followed by their count.
+func printErrors(err error) {
+	var list *asm.ErrorList
+	if !errors.As(err, &list) {
+		fmt.Println("assemble error:", err)
+		return
+	}
+	for _, e := range list.Errors {
+		fmt.Println("assemble error:", e)
+	}
+	summary := fmt.Sprintf("%d errors", len(list.Errors))
+	if len(list.Errors) == 1 {
+		summary = "1 error"
+	}
+	if list.Limited {
+		summary += " (more left out, see --max-errors)"
+	}
+	fmt.Println(summary)
+}
+
+// cliMaxErrors maps the --max-errors value, where 0 means no limit, to
+// ParseOptions.MaxErrors.
+func cliMaxErrors(n int) int {
+	if n <= 0 {
+		return -1
+	}
+	return n
+}
+
+// writePreprocessed writes the preprocessed source to stdout, or to the -o
+// file when one was given.
+func writePreprocessed(srcPath string, opts asm.ParseOptions, toFile string) error {
+	if toFile == "" {
+		return asm.PreprocessFile(os.Stdout, srcPath, opts)
+	}
+	var buf strings.Builder
+	if err := asm.PreprocessFile(&buf, srcPath, opts); err != nil {
+		return err
+	}
+	return os.WriteFile(toFile, []byte(buf.String()), 0644)
+}
+
+// explicitOutput returns the -o path if it was given explicitly.
+func explicitOutput() string {
+	path := ""
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "o" {
+			path = f.Value.String()
+		}
+	})
+	return path
+}
+
+func writeListing(path string, entries []asm.ListingEntry, labels []asm.DefinedLabel, srcPath string) error {
 	lines, err := readLines(srcPath)
 	if err != nil {
 		return err
@@ -118,8 +228,22 @@ func writeListing(path string, entries []asm.ListingEntry, srcPath string) error
 		if idx := e.Line - 1; idx >= 0 && idx < len(lines) {
 			lineText = lines[idx]
 		}
+		if len(e.Sizes) > 0 {
+			// Mark the sizes the assembler chose, e.g. "; abs.W".
+			lineText += "  ; " + strings.Join(e.Sizes, ", ")
+		}
 		fmt.Fprintf(w, "%5d  0x%08X  %-32s %s\n", e.Line, e.PC, formatBytes(e.Bytes), lineText)
 	}
+
+	if len(labels) > 0 {
+		// Labels include names generated by macro expansion (\@, .local).
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "Symbol                           Value      Line  Kind")
+		fmt.Fprintln(w, "-------------------------------- ---------- ----- --------")
+		for _, l := range labels {
+			fmt.Fprintf(w, "%-32s 0x%08X %5d  %s\n", l.Name, l.Addr, l.Line, l.Kind)
+		}
+	}
 	return nil
 }
 
@@ -225,3 +349,65 @@ func resolveInputPath(path string, includePaths []string) (string, error) {
 	}
 	return "", fmt.Errorf("cannot find %s (searched: %s)", path, strings.Join(includePaths, string(os.PathListSeparator)))
 }
+
+// warningFlag collects -W<name>, -Wno-<name>, -Wall and -Werror.
+type warningFlag struct {
+	enabled  map[string]bool
+	asErrors bool
+}
+
+func (w *warningFlag) String() string {
+	if w == nil {
+		return ""
+	}
+	parts := make([]string, 0, len(w.enabled))
+	for name, on := range w.enabled {
+		if !on {
+			name = "no-" + name
+		}
+		parts = append(parts, name)
+	}
+	return strings.Join(parts, ",")
+}
+
+func (w *warningFlag) Set(value string) error {
+	name, on := value, true
+	if rest, ok := strings.CutPrefix(value, "no-"); ok {
+		name, on = rest, false
+	}
+	if name == "error" {
+		w.asErrors = on
+		return nil
+	}
+	if name != "all" && !isWarningCategory(name) {
+		return fmt.Errorf("unknown warning category %q", name)
+	}
+	w.enabled[name] = on
+	return nil
+}
+
+func isWarningCategory(name string) bool {
+	for _, c := range asm.WarningCategories {
+		if string(c.Category) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// splitWarningFlags rewrites "-Wname" as "-W=name", which the flag package
+// reads as the W flag with the value name.
+func splitWarningFlags(args []string) []string {
+	out := make([]string, len(args))
+	for i, a := range args {
+		if a == "--" {
+			copy(out[i:], args[i:])
+			break
+		}
+		if len(a) > 2 && strings.HasPrefix(a, "-W") && a[2] != '=' {
+			a = "-W=" + a[2:]
+		}
+		out[i] = a
+	}
+	return out
+}
@@ -29,13 +29,19 @@ func main() {
 	}
 	writeFile("example.srec", srec)
 
+	hex, err := m68kasm.AssembleStringIntelHex(source)
+	if err != nil {
+		log.Fatalf("assemble intel hex: %v", err)
+	}
+	writeFile("example.hex", hex)
+
 	elf, err := m68kasm.AssembleStringELF(source)
 	if err != nil {
 		log.Fatalf("assemble elf: %v", err)
 	}
 	writeFile("example.elf", elf)
 
-	fmt.Println("Wrote example.bin, example.srec, and example.elf in the current directory")
+	fmt.Println("Wrote example.bin, example.srec, example.hex, and example.elf in the current directory")
 }
 
 func writeFile(name string, contents []byte) {
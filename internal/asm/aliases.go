@@ -0,0 +1,259 @@
+package asm
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jenska/m68kasm/internal/asm/instructions"
+)
+
+// implicitForm describes a dedicated encoding that Motorola assemblers pick
+// automatically when a generic mnemonic is used with matching operands.
+type implicitForm struct {
+	mnemonic string
+	applies  func(a *instructions.Args) bool
+	// layoutDependent marks rewrites that change the instruction size based on
+	// operand values, so the decision goes through layoutChoice.
+	layoutDependent bool
+}
+
+var implicitForms = map[string][]implicitForm{
+	"MOVE": {{mnemonic: "MOVEA", applies: moveToAddrReg}},
+	"ADD": {
+		{mnemonic: "ADDA", applies: dstIsAddrReg},
+		{mnemonic: "ADDQ", applies: quickImmToMemory, layoutDependent: true},
+		{mnemonic: "ADDI", applies: immToMemory},
+	},
+	"SUB": {
+		{mnemonic: "SUBA", applies: dstIsAddrReg},
+		{mnemonic: "SUBQ", applies: quickImmToMemory, layoutDependent: true},
+		{mnemonic: "SUBI", applies: immToMemory},
+	},
+}
+
+func dstIsAddrReg(a *instructions.Args) bool {
+	return a.Dst.Kind == instructions.EAkAn
+}
+
+func moveToAddrReg(a *instructions.Args) bool {
+	return dstIsAddrReg(a) && a.Src.Kind != instructions.EAkUSP
+}
+
+func quickImmToMemory(a *instructions.Args) bool {
+	return a.HasImmQuick && a.Src.Imm >= 1 && a.Src.Imm <= 8 && isMemoryOperand(a.Dst.Kind)
+}
+
+func immToMemory(a *instructions.Args) bool {
+	return a.Src.Kind == instructions.EAkImm && isMemoryOperand(a.Dst.Kind)
+}
+
+func isMemoryOperand(k instructions.EAExprKind) bool {
+	switch k {
+	case instructions.EAkNone, instructions.EAkImm, instructions.EAkDn, instructions.EAkAn,
+		instructions.EAkSR, instructions.EAkCCR, instructions.EAkUSP:
+		return false
+	}
+	return true
+}
+
+// implicitCandidate is a dedicated instruction whose forms accepted the
+// operands of a generic mnemonic.
+type implicitCandidate struct {
+	implicitForm
+	def    *instructions.InstrDef
+	parsed parsedForm
+}
+
+// implicitInstruction returns the dedicated instructions (MOVEA, ADDA, ADDQ,
+// ADDI, ...) that may take over from a generic mnemonic, in order. parsed
+// reports whether the generic mnemonic itself accepted the operands; in that
+// case args holds its parse so rewrites that cannot apply are skipped without
+// reparsing. The list ends with the first candidate that applies whatever
+// the layout; whether a layoutDependent one applies is left to layout.
+func (p *Parser) implicitInstruction(mn Token, def *instructions.InstrDef, operandTokens []Token, args instructions.Args, parsed bool) []implicitCandidate {
+	var candidates []implicitCandidate
+	for _, alt := range implicitForms[def.Mnemonic] {
+		if parsed && !alt.applies(&args) {
+			continue
+		}
+		altDef := p.instrs.Lookup(alt.mnemonic)
+		if altDef == nil {
+			continue
+		}
+		altParsed, err := p.parseForms(mn, altDef, operandTokens)
+		if err != nil || (!alt.layoutDependent && !alt.applies(&altParsed.args)) {
+			continue
+		}
+		candidates = append(candidates, implicitCandidate{implicitForm: alt, def: altDef, parsed: altParsed})
+		if !alt.layoutDependent {
+			break
+		}
+	}
+	return candidates
+}
+
+func boolChoice(v bool) int {
+	if v {
+		return 1
+	}
+	return 0
+}
+
+// Jump pseudo-instructions (gas jbsr/jra/jbCC) pick the shortest branch that
+// reaches a label target and fall back to an absolute JMP/JSR otherwise. The
+// choices are ordered by code size for layoutChoice.
+const (
+	jumpShort = iota
+	jumpWord
+	jumpLong
+)
+
+var invertedConditions = map[string]string{
+	"HI": "LS", "LS": "HI",
+	"HS": "LO", "LO": "HS",
+	"CC": "CS", "CS": "CC",
+	"NE": "EQ", "EQ": "NE",
+	"VC": "VS", "VS": "VC",
+	"PL": "MI", "MI": "PL",
+	"GE": "LT", "LT": "GE",
+	"GT": "LE", "LE": "GT",
+}
+
+// jumpBranchMnemonic maps a jump pseudo-instruction to its branch mnemonic,
+// e.g. JBSR -> BSR, JRA -> BRA, JBEQ/JEQ -> BEQ.
+func jumpBranchMnemonic(base string) (string, bool) {
+	switch base {
+	case "JRA", "JBRA":
+		return "BRA", true
+	case "JBSR":
+		return "BSR", true
+	}
+	if !strings.HasPrefix(base, "J") {
+		return "", false
+	}
+	cond := strings.TrimPrefix(base[1:], "B")
+	if _, ok := invertedConditions[cond]; ok && len(base) <= 4 {
+		return "B" + cond, true
+	}
+	return "", false
+}
+
+// jump is a jump pseudo-instruction with the instructions of each choice,
+// or the error they could not be parsed with.
+type jump struct {
+	target jumpTarget
+	sizes  [jumpLong + 1][]*instruction
+	errs   [jumpLong + 1]error
+}
+
+// jumpTarget is the label a jump names when its operand is a plain label.
+type jumpTarget struct {
+	name string
+	// label marks a named label, forward a numeric local label further down.
+	label   bool
+	forward bool
+}
+
+func (p *Parser) parseJumpPseudo(branch string) error {
+	if err := p.checkCodeSection(); err != nil {
+		return err
+	}
+	mn := p.next()
+	raw := p.consumeUntilEOL()
+	operands := append([]Token(nil), raw...)
+	p.releaseTokens(raw)
+
+	j := &jump{target: p.jumpTarget(operands)}
+	token := func(kind Kind, text string, val int64) Token {
+		t := mn
+		t.Kind, t.Text, t.Val = kind, text, val
+		return t
+	}
+	parse := func(choice int, mnemonic string, operands []Token) {
+		if j.errs[choice] != nil {
+			return
+		}
+		base, _ := splitMnemonic(mnemonic)
+		def := p.instrs.Lookup(base)
+		if def == nil {
+			j.errs[choice] = parserError(mn, fmt.Sprintf("%s needs the instruction %s", mn.Text, base))
+			return
+		}
+		ins, err := p.parseOperands(token(IDENT, mnemonic, 0), def, operands)
+		j.sizes[choice], j.errs[choice] = append(j.sizes[choice], ins), err
+	}
+	parse(jumpShort, branch+".S", operands)
+	parse(jumpWord, branch+".W", operands)
+	switch branch {
+	case "BRA":
+		parse(jumpLong, "JMP", operands)
+	case "BSR":
+		parse(jumpLong, "JSR", operands)
+	default:
+		// Skip over a JMP.L with the inverted condition: 2 bytes Bcc.S + 6 bytes JMP.
+		skip := "B" + invertedConditions[branch[1:]] + ".S"
+		parse(jumpLong, skip, []Token{token(DOLLAR, "$", 0), token(PLUS, "+", 0), token(NUMBER, "8", 8)})
+		parse(jumpLong, "JMP", operands)
+	}
+	p.place(j.layout)
+	return nil
+}
+
+// layout picks the shortest branch that reaches the target on the layout of
+// the pass and emits its instructions.
+func (j *jump) layout(p *Parser) error {
+	choice := jumpLong
+	if addr, ok := p.knownJumpTarget(j.target); ok {
+		disp := int64(addr) - int64(p.pc) - 2
+		switch {
+		case disp >= -128 && disp <= 127 && disp != 0:
+			choice = jumpShort
+		case disp >= -32768 && disp <= 32767:
+			choice = jumpWord
+		}
+	}
+	choice = p.layoutChoice(choice)
+	if err := j.errs[choice]; err != nil {
+		return err
+	}
+	for _, ins := range j.sizes[choice] {
+		if err := ins.layout(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// jumpTarget resolves a plain label operand of a jump.
+func (p *Parser) jumpTarget(operands []Token) jumpTarget {
+	switch {
+	case len(operands) == 1 && operands[0].Kind == IDENT:
+		return jumpTarget{name: p.resolveSymbol(operands[0].Text), label: true}
+	case len(operands) == 2 && operands[0].Kind == NUMBER && strings.EqualFold(operands[1].Text, "b"):
+		num := int(operands[0].Val)
+		if p.locals[num] == 0 {
+			return jumpTarget{}
+		}
+		return jumpTarget{name: localLabelName(num, p.locals[num])}
+	case len(operands) == 2 && operands[0].Kind == NUMBER && strings.EqualFold(operands[1].Text, "f"):
+		num := int(operands[0].Val)
+		return jumpTarget{name: localLabelName(num, p.locals[num]+1), forward: true}
+	}
+	return jumpTarget{}
+}
+
+// knownJumpTarget returns the address of the target of a jump: a label
+// defined earlier in this pass, or one further down at its address from the
+// previous pass.
+func (p *Parser) knownJumpTarget(t jumpTarget) (uint32, bool) {
+	if t.name == "" {
+		return 0, false
+	}
+	if p.prev == nil {
+		if _, ok := p.definedLabelPos[t.name]; t.forward || (t.label && !ok) {
+			return 0, false
+		}
+	}
+	addr, ok := p.labels[t.name]
+	return addr, ok
+}
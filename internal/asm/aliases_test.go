@@ -0,0 +1,49 @@
+package asm_test
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestConditionAndMnemonicAliases(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want []byte
+	}{
+		{"BranchCarryClear", "BCC target\n.WORD 0\ntarget:\n", []byte{0x64, 0x02, 0x00, 0x00}},
+		{"BranchCarrySetShort", "BCS.S target\n.WORD 0\ntarget:\n", []byte{0x65, 0x02, 0x00, 0x00}},
+		{"BranchLessShort", "loop:\nBLT.S loop\n", []byte{0x6D, 0xFE}},
+		{"DecrementCarrySet", "loop:\nDBCS D1,loop\n", []byte{0x55, 0xC9, 0xFF, 0xFE}},
+		{"DecrementAlwaysWord", "loop:\nDBRA.W D0,loop\n", []byte{0x51, 0xC8, 0xFF, 0xFE}},
+		{"SetCarryClear", "SCC D0\n", []byte{0x54, 0xC0}},
+		{"SetCarrySet", "SCS (A1)\n", []byte{0x55, 0xD1}},
+		{"MoveToAddressRegister", "MOVE.L D0,A0\n", []byte{0x20, 0x40}},
+		{"AddToAddressRegister", "ADD.W D1,A2\n", []byte{0xD4, 0xC1}},
+		{"AddQuickToMemory", "ADD.W #1,(A0)\n", []byte{0x52, 0x50}},
+		{"SubQuickToMemory", "SUB.L #8,-(A7)\n", []byte{0x51, 0xA7}},
+		{"AddImmediateToMemory", "ADD.W #$1234,(A0)\n", []byte{0x06, 0x50, 0x12, 0x34}},
+		{"SubImmediateToMemory", "SUB.L #100,(4,A0)\n", []byte{0x04, 0xA8, 0x00, 0x00, 0x00, 0x64, 0x00, 0x04}},
+		{"AddImmediateToDataRegister", "ADD.W #1,D0\n", []byte{0xD0, 0x7C, 0x00, 0x01}},
+		{"JumpBackwardShort", "loop:\nJRA loop\n", []byte{0x60, 0xFE}},
+		{"JumpSubroutineBackward", "sub:\nNOP\nJBSR sub\n", []byte{0x4E, 0x71, 0x61, 0xFC}},
+		{"JumpBackwardWord", "loop:\nNOP\n.ALIGN 256\nJBRA loop\n", append(append([]byte{0x4E, 0x71}, make([]byte, 254)...), 0x60, 0x00, 0xFE, 0xFE)},
+		{"JumpForwardShort", "JBRA target\nNOP\ntarget:\n", []byte{0x60, 0x02, 0x4E, 0x71}},
+		{"JumpForwardNext", "JBRA target\ntarget:\n", []byte{0x60, 0x00, 0x00, 0x02}},
+		{"JumpForwardLong", "JBRA target\n.ALIGN 65536\ntarget:\n", append([]byte{0x4E, 0xF9, 0x00, 0x01, 0x00, 0x00}, make([]byte, 65530)...)},
+		{"JumpSubroutineForward", "JBSR target\nNOP\ntarget:\n", []byte{0x61, 0x02, 0x4E, 0x71}},
+		{"JumpLocalForward", "JRA 1f\nNOP\n1:\n", []byte{0x60, 0x02, 0x4E, 0x71}},
+		{"JumpConditionalBackward", "1:\nJEQ 1b\n", []byte{0x67, 0xFE}},
+		{"JumpConditionalForward", "JBNE target\nNOP\ntarget:\n", []byte{0x66, 0x02, 0x4E, 0x71}},
+		{"JumpConditionalForwardLong", "JBNE target\n.ALIGN 65536\ntarget:\n", append([]byte{0x67, 0x06, 0x4E, 0xF9, 0x00, 0x01, 0x00, 0x00}, make([]byte, 65528)...)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := assembleSource(t, tt.src)
+			if !bytes.Equal(got, tt.want) {
+				t.Fatalf("unexpected encoding for %s: got %x want %x", tt.src, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package asm_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jenska/m68kasm/internal/asm"
+)
+
+// TestASM68KCompatibilitySample assembles the public-domain Mega Drive style
+// sample in tests/testdata/asm68k and compares it byte for byte.
+func TestASM68KCompatibilitySample(t *testing.T) {
+	src := filepath.Join("..", "..", "tests", "testdata", "asm68k", "sample.asm")
+	golden, err := os.ReadFile(filepath.Join("..", "..", "tests", "testdata", "asm68k", "sample.hex"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := hex.DecodeString(strings.Join(strings.Fields(string(golden)), ""))
+	if err != nil {
+		t.Fatalf("bad golden file: %v", err)
+	}
+
+	var messages bytes.Buffer
+	prog, err := asm.ParseFileWithOptions(src, asm.ParseOptions{Syntax: asm.SyntaxASM68K, Messages: &messages})
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	got, err := asm.Assemble(prog)
+	if err != nil {
+		t.Fatalf("assemble: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("output mismatch\n got: % X\nwant: % X", got, want)
+	}
+	if msg := messages.String(); msg != "line 36: object size is 8 bytes\n" {
+		t.Fatalf("unexpected INFORM output %q", msg)
+	}
+}
+
+func TestASM68KDirectives(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want []byte
+	}{
+		{"AtLocalLabels", "a:\n@l: nop\n bra.s @l\nb:\n@l: bra.s @l\n", []byte{0x4E, 0x71, 0x60, 0xFC, 0x60, 0xFE}},
+		{"RsCounterSymbol", " rsset 8\nx: rs.w 3\nsize equ __rs\n dc.b x,size\n", []byte{8, 14}},
+		{"LabelColonBeforeEqu", "val: equ 3\n dc.b val\n", []byte{3}},
+		{"Narg", "cnt macro\n dc.b narg\n endm\n cnt\n cnt 1,2\n", []byte{0, 2}},
+		{"PassThroughArgs", "in macro\n dc.b \\2,\\1\n endm\nout macro\n in \\*\n endm\n out 1,2\n", []byte{2, 1}},
+		{"ObjBlock", " nop\n obj $100\nl: bra.s l\n objend\nm: dc.b m,l&$FF\n", []byte{0x4E, 0x71, 0x60, 0xFE, 0x04, 0x00}},
+		{"InformMessage", " inform 0,\"hello\"\n nop\n", []byte{0x4E, 0x71}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := assembleWithSyntax(t, tt.src, asm.SyntaxASM68K)
+			if !bytes.Equal(got, tt.want) {
+				t.Fatalf("unexpected encoding for %q: got %x want %x", tt.src, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestASM68KDirectiveErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"InformError", " inform 2,\"value %d too big\",7\n", "value 7 too big"},
+		{"UnclosedObj", " obj $100\n nop\n", "OBJ without matching OBJEND"},
+		{"StrayObjend", " objend\n", "OBJEND without OBJ"},
+		{"MissingIncbin", " incbin \"missing.bin\"\n", "missing.bin"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := asm.ParseWithOptions(strings.NewReader(tt.src), asm.ParseOptions{Syntax: asm.SyntaxASM68K})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestIncbinRange(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "data.bin"), []byte{1, 2, 3, 4, 5}, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	prog, err := asm.ParseWithOptions(strings.NewReader(".incbin \"data.bin\",1,3\n"), asm.ParseOptions{IncludeDirs: []string{dir}})
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	got, err := asm.Assemble(prog)
+	if err != nil {
+		t.Fatalf("assemble: %v", err)
+	}
+	if want := []byte{2, 3, 4}; !bytes.Equal(got, want) {
+		t.Fatalf("got %x want %x", got, want)
+	}
+
+	_, err = asm.ParseWithOptions(strings.NewReader(".incbin \"data.bin\",4,2\n"), asm.ParseOptions{IncludeDirs: []string{dir}})
+	if err == nil || !strings.Contains(err.Error(), "exceeds") {
+		t.Fatalf("expected range error, got %v", err)
+	}
+}
+
+func TestScopedLocalLabels(t *testing.T) {
+	tests := []struct {
+		name   string
+		src    string
+		syntax asm.Syntax
+		want   []byte
+	}{
+		{"Motorola", "main:\n.loop: nop\n bra.s .loop\nsub:\n.loop: bra.s main.loop\n dc.b sub.loop-main\n", asm.SyntaxMotorola, []byte{0x4E, 0x71, 0x60, 0xFC, 0x60, 0xFA, 0x04}},
+		{"Devpac", "main\n.loop nop\n bra.s .loop\nsub\n.loop bra.s main.loop\n dc.b sub.loop-main\n", asm.SyntaxDevpac, []byte{0x4E, 0x71, 0x60, 0xFC, 0x60, 0xFA, 0x04}},
+		{"AtLocals", "main:\n@loop: nop\n bra.s @loop\nsub:\n@loop: bra.s main.loop\n dc.b sub.loop-main\n", asm.SyntaxASM68K, []byte{0x4E, 0x71, 0x60, 0xFC, 0x60, 0xFA, 0x04}},
+		{"DirectivesStaySplit", "FOO .equ 3\nmain: .byte FOO\n.x: .word .x\n", asm.SyntaxMotorola, []byte{0x03, 0x00, 0x01}},
+		{"DirectiveNames", "copy: tst.w d0\n beq.s .end\n bra.s .set\n nop\n.end: rts\n.set: dc.w .even-copy\n.even:\n", asm.SyntaxMotorola,
+			[]byte{0x4A, 0x40, 0x67, 0x04, 0x60, 0x04, 0x4E, 0x71, 0x4E, 0x75, 0x00, 0x0C}},
+		{"DirectiveNamesDevpac", "copy tst.w d0\n beq.s .end\n bra.s .set\n nop\n.end rts\n.set dc.w .even-copy\n.even\n", asm.SyntaxDevpac,
+			[]byte{0x4A, 0x40, 0x67, 0x04, 0x60, 0x04, 0x4E, 0x71, 0x4E, 0x75, 0x00, 0x0C}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := assembleWithSyntax(t, tt.src, tt.syntax)
+			if !bytes.Equal(got, tt.want) {
+				t.Fatalf("unexpected encoding for %q: got %x want %x", tt.src, got, tt.want)
+			}
+		})
+	}
+}
@@ -36,6 +36,30 @@ func BenchmarkParseOnly(b *testing.B) {
 	}
 }
 
+// BenchmarkParseForwardRefs parses a source whose references all point
+// further down, which the parser resolves from the previous pass.
+func BenchmarkParseForwardRefs(b *testing.B) {
+	var sb strings.Builder
+	const n = 4096
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&sb, "l%d: bra.w l%d\nmove.w v%d,d0\n", i, i+1, i)
+	}
+	fmt.Fprintf(&sb, "l%d: nop\n", n)
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&sb, "v%d: dc.w %d\n", i, i)
+	}
+	src := sb.String()
+	b.ReportAllocs()
+	b.SetBytes(int64(len(src)))
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := Parse(strings.NewReader(src)); err != nil {
+			b.Fatalf("parse error: %v", err)
+		}
+	}
+}
+
 func BenchmarkAssembleOnly(b *testing.B) {
 	src := buildBenchmarkSource(512)
 	prog, err := Parse(strings.NewReader(src))
@@ -13,6 +13,19 @@ type Program struct {
 	DefinedLabels []DefinedLabel
 	Origin        uint32
 	SourceLines   []string
+	// Warnings are the warnings raised in the enabled categories.
+	Warnings []Diagnostic
+
+	// maxErrors is the resolved ParseOptions.MaxErrors; 0 means no limit.
+	maxErrors     int
+	pass          int
+	layoutChoices map[choiceKey]int
+	sections      [sectionCount]sectionRange
+	// definedLabelPos maps the names in DefinedLabels to their index.
+	definedLabelPos map[string]int
+	// stale marks a layout on which a conditional or a namespace lookup of
+	// the front end comes out differently.
+	stale bool
 }
 
 // DefinedLabel captures a named label defined in source so that output formats
@@ -22,14 +35,49 @@ type DefinedLabel struct {
 	Addr    uint32
 	Line    int
 	Section SectionKind
+	// Global marks labels exported with .globl/.global.
+	Global bool
+	// Kind tells labels from EQU constants and SET variables, whose Addr is
+	// their value.
+	Kind SymbolKind
+}
+
+// SymbolKind classifies a symbol defined in the source.
+type SymbolKind uint8
+
+const (
+	// SymbolLabel is an address defined by a label.
+	SymbolLabel SymbolKind = iota
+	// SymbolConstant is a value defined once with EQU, = or RS.
+	SymbolConstant
+	// SymbolVariable is a value that SET or .set may assign again.
+	SymbolVariable
+)
+
+func (k SymbolKind) String() string {
+	switch k {
+	case SymbolConstant:
+		return "constant"
+	case SymbolVariable:
+		return "variable"
+	default:
+		return "label"
+	}
 }
 
 // ListingEntry captures the assembled bytes for a single source line so that a
 // human-readable listing file can be generated.
 type ListingEntry struct {
-	Line  int
-	PC    uint32
+	Line int
+	PC   uint32
+	// Load is the address the bytes occupy in the output image. It differs
+	// from PC inside an OBJ block, whose code runs at another address.
+	Load  uint32
 	Bytes []byte
+	// Sizes lists the sizes the assembler chose for operands written
+	// without one, such as "abs.W", or "(PC)" for operands it made
+	// PC-relative.
+	Sizes []string
 }
 
 // Assemble walks through the parsed program and encodes each instruction or data block.
@@ -85,13 +133,25 @@ func assemble(dst []byte, w io.Writer, p *Program, wantListing bool) ([]byte, []
 	}
 
 	var written int64
+	var errs []error
 	itemBuf := make([]byte, 0, 32)
+	// The output is flat, so each item loads where the previous one ends.
+	load := p.Origin
 
 	for _, it := range p.Items {
 		var err error
 		itemBuf, err = assembleItem(itemBuf[:0], it, p.Labels)
 		if err != nil {
-			return nil, nil, written, withSourceLines(err, p.SourceLines)
+			// Later items are still checked so that one run reports every
+			// error; nothing more is written.
+			errs = append(errs, withSourceLines(err, p.SourceLines))
+			if p.maxErrors > 0 && len(errs) > p.maxErrors {
+				break
+			}
+			continue
+		}
+		if errs != nil {
+			continue
 		}
 
 		if w != nil {
@@ -106,49 +166,140 @@ func assemble(dst []byte, w io.Writer, p *Program, wantListing bool) ([]byte, []
 
 		if wantListing {
 			pc, line, _ := itemLocation(it)
-			entry := ListingEntry{PC: pc, Line: line}
+			entry := ListingEntry{PC: pc, Load: load, Line: line}
 			entry.Bytes = append(entry.Bytes, itemBuf...)
+			if ins, ok := it.(*Instr); ok {
+				entry.Sizes = chosenSizes(&ins.Args)
+			}
 			listing = append(listing, entry)
 		}
+		load += uint32(len(itemBuf))
+	}
+	if errs != nil {
+		return nil, nil, written, joinErrors(errs, p.maxErrors)
 	}
-
 	return out, listing, written, nil
 }
 
+// chosenSizes names the sizes of the absolute operands the parser picked,
+// and "(PC)" for those it made PC-relative.
+func chosenSizes(a *instructions.Args) []string {
+	var sizes []string
+	for _, ea := range []instructions.EAExpr{a.Src, a.Dst} {
+		switch {
+		case !ea.AutoSize:
+		case ea.Kind == instructions.EAkAbsW:
+			sizes = append(sizes, "abs.W")
+		case ea.Kind == instructions.EAkAbsL:
+			sizes = append(sizes, "abs.L")
+		case ea.Kind == instructions.EAkPCDisp16:
+			sizes = append(sizes, "(PC)")
+		}
+	}
+	return sizes
+}
+
 func assembleItem(dst []byte, it any, labels map[string]uint32) ([]byte, error) {
 	switch x := it.(type) {
 	case *Instr:
-		ins := *x
-		ins.Args = x.Args
+		bytes, err := assembleInstr(dst, x, labels)
+		return bytes, x.origin.locate(err)
+
+	case *DataBytes:
+		return assembleData(dst, x, labels)
+
+	default:
+		return nil, fmt.Errorf("unknown item type in program")
+	}
+}
+
+func assembleInstr(dst []byte, x *Instr, labels map[string]uint32) ([]byte, error) {
+	ins := *x
+	ins.Args = x.Args
+
+	def := x.Def
+	if def == nil {
+		return nil, &Error{Line: x.Line, Col: x.Col, Code: CodeInternal, Err: fmt.Errorf("no definition for opcode")}
+	}
+
+	if err := resolveOperandExprs(&ins.Args, labels); err != nil {
+		return nil, contextualizeAt(x.Line, x.Col, withCode(CodeInvalidExpression, err))
+	}
 
-		def := x.Def
-		if def == nil {
-			return nil, &Error{Line: x.Line, Col: x.Col, Err: fmt.Errorf("no definition for opcode")}
+	actualKinds := operandKinds(&ins.Args)
+	form, err := selectForm(def, &ins, actualKinds)
+	if err != nil {
+		return nil, contextualizeAt(x.Line, x.Col, withCode(CodeInvalidOperand, err))
+	}
+	if form.Validate != nil {
+		if err := form.Validate(&ins.Args); err != nil {
+			err = withCode(CodeInvalidOperand, err)
+			err = withHint(err, validateHint(x.mnemonic, def, form, ins.Args, err))
+			if codeOf(err) == CodeOutOfRange && x.immediate != nil {
+				return nil, errorAtOperand(x.immediate, err)
+			}
+			return nil, contextualizeAt(x.Line, x.Col, err)
 		}
+	}
 
-		actualKinds := operandKinds(&ins.Args)
-		form, err := selectForm(def, &ins, actualKinds)
+	bytes, err := Encode(def, form, &ins, labels)
+	if err != nil {
+		return nil, contextualizeAt(x.Line, x.Col, withCode(CodeInvalidOperand, err))
+	}
+	return append(dst, bytes...), nil
+}
+
+// resolveOperandExprs evaluates the operand expressions again with the final
+// symbol table.
+func resolveOperandExprs(a *instructions.Args, labels map[string]uint32) error {
+	for _, ea := range []*instructions.EAExpr{&a.Src, &a.Dst} {
+		if ea.Expr == nil {
+			continue
+		}
+		v, err := ea.Expr.Eval(labels)
 		if err != nil {
-			return nil, contextualizeAt(x.Line, x.Col, err)
+			return err
 		}
-		if form.Validate != nil {
-			if err := form.Validate(&ins.Args); err != nil {
-				return nil, contextualizeAt(x.Line, x.Col, err)
-			}
+		switch ea.Kind {
+		case instructions.EAkNone, instructions.EAkImm:
+			ea.Imm = v
+		case instructions.EAkAddrDisp16, instructions.EAkPCDisp16:
+			ea.Disp16 = int32(v)
+		case instructions.EAkIdxAnBrief, instructions.EAkIdxPCBrief:
+			ea.Index.Disp8 = int8(v)
+		case instructions.EAkAbsW:
+			ea.Abs16 = uint16(v)
+		case instructions.EAkAbsL:
+			ea.Abs32 = uint32(v)
 		}
-
-		bytes, err := Encode(def, form, &ins, labels)
+	}
+	if a.TargetExpr != nil {
+		v, err := a.TargetExpr.Eval(labels)
 		if err != nil {
-			return nil, contextualizeAt(x.Line, x.Col, err)
+			return err
 		}
-		return append(dst, bytes...), nil
-
-	case *DataBytes:
-		return append(dst, x.Bytes...), nil
+		a.TargetAddr = v
+	}
+	return nil
+}
 
-	default:
-		return nil, fmt.Errorf("unknown item type in program")
+// assembleData copies a data block, patching the values whose expressions
+// refer to labels.
+func assembleData(dst []byte, x *DataBytes, labels map[string]uint32) ([]byte, error) {
+	start := len(dst)
+	dst = append(dst, x.Bytes...)
+	for _, f := range x.Exprs {
+		v, err := f.Expr.Eval(labels)
+		if err != nil {
+			return nil, contextualizeAt(x.Line, x.Col, withCode(CodeInvalidExpression, err))
+		}
+		field := dst[start+f.Offset : start+f.Offset+f.Size]
+		for i := len(field) - 1; i >= 0; i-- {
+			field[i] = byte(v)
+			v >>= 8
+		}
 	}
+	return dst, nil
 }
 
 func selectForm(def *instructions.InstrDef, ins *Instr, actual []instructions.OperandKind) (*instructions.FormDef, error) {
@@ -0,0 +1,157 @@
+package asm
+
+import (
+	"fmt"
+	"strings"
+)
+
+// condFrame tracks one level of conditional assembly (IF ... ELSE ... ENDC).
+type condFrame struct {
+	active       bool // statements in the current branch are assembled
+	taken        bool // some branch of this block has been assembled already
+	parentActive bool
+	line         int
+}
+
+// ifDirectives maps the IF family to the test applied to its operand.
+var ifDirectives = map[string]func(*Parser, Token) (bool, error){
+	"IF":     exprCondition(func(v int64) bool { return v != 0 }),
+	"IFNE":   exprCondition(func(v int64) bool { return v != 0 }),
+	"IFEQ":   exprCondition(func(v int64) bool { return v == 0 }),
+	"IFGT":   exprCondition(func(v int64) bool { return v > 0 }),
+	"IFGE":   exprCondition(func(v int64) bool { return v >= 0 }),
+	"IFLT":   exprCondition(func(v int64) bool { return v < 0 }),
+	"IFLE":   exprCondition(func(v int64) bool { return v <= 0 }),
+	"IFD":    definedCondition(true),
+	"IFDEF":  definedCondition(true),
+	"IFND":   definedCondition(false),
+	"IFNDEF": definedCondition(false),
+}
+
+// conditionalDirectives lists every directive that is interpreted even while
+// a conditional block is being skipped.
+var conditionalDirectives = func() map[string]bool {
+	m := map[string]bool{"ELSE": true, "ELSEIF": true, "ENDC": true, "ENDIF": true}
+	for name := range ifDirectives {
+		m[name] = true
+	}
+	return m
+}()
+
+// exprCondition tests an expression with the values the front end knows.
+// Each pass evaluates it again; a pass that sees it flip marks the program
+// stale, since the statements of the other branch were never parsed.
+func exprCondition(test func(int64) bool) func(*Parser, Token) (bool, error) {
+	return func(p *Parser, tok Token) (bool, error) {
+		v, err := p.parseExprInfo()
+		if err != nil {
+			return false, err
+		}
+		cond := test(v.Value)
+		p.place(func(p *Parser) error {
+			v, err := p.evaluate(v)
+			if err != nil {
+				return contextualizeAt(tok.Line, tok.firstCol(), err)
+			}
+			if test(v.Value) != cond {
+				p.stale = true
+			}
+			return nil
+		})
+		return cond, nil
+	}
+}
+
+func definedCondition(want bool) func(*Parser, Token) (bool, error) {
+	return func(p *Parser, _ Token) (bool, error) {
+		name, err := p.want(IDENT)
+		if err != nil {
+			return false, err
+		}
+		return p.defined[p.resolveSymbol(name.Text)] == want, nil
+	}
+}
+
+// skipping reports whether statements are currently excluded by a false
+// conditional.
+func (p *Parser) skipping() bool {
+	return len(p.conds) > 0 && !p.conds[len(p.conds)-1].active
+}
+
+// conditionalDirectiveName returns the upper-case name of a conditional
+// directive at the start of the current statement, with or without a leading
+// dot, and the number of tokens it spans.
+func (p *Parser) conditionalDirectiveName() (string, int) {
+	t := p.peek()
+	n := 1
+	if t.Kind == DOT {
+		t = p.peekN(2)
+		n = 2
+	}
+	if t.Kind != IDENT {
+		return "", 0
+	}
+	name := strings.ToUpper(strings.TrimPrefix(t.Text, "."))
+	if !conditionalDirectives[name] {
+		return "", 0
+	}
+	return name, n
+}
+
+// parseConditional handles IF/ELSE/ENDC style directives. It reports false
+// when the statement is not a conditional directive.
+func (p *Parser) parseConditional() (bool, error) {
+	name, n := p.conditionalDirectiveName()
+	if name == "" {
+		return false, nil
+	}
+	var tok Token
+	for i := 0; i < n; i++ {
+		tok = p.next()
+	}
+
+	if test, ok := ifDirectives[name]; ok {
+		if p.skipping() {
+			p.releaseTokens(p.consumeUntilEOL())
+			p.conds = append(p.conds, condFrame{taken: true, line: tok.Line})
+			return true, nil
+		}
+		cond, err := test(p, tok)
+		if err != nil {
+			return true, contextualizeAt(tok.Line, tok.firstCol(), err)
+		}
+		p.conds = append(p.conds, condFrame{active: cond, taken: cond, parentActive: true, line: tok.Line})
+		return true, nil
+	}
+
+	if len(p.conds) == 0 {
+		return true, withCode(CodeUnbalancedBlock, parserError(tok, fmt.Sprintf("%s without matching IF", name)))
+	}
+	top := &p.conds[len(p.conds)-1]
+	switch name {
+	case "ELSE":
+		top.active = top.parentActive && !top.taken
+		top.taken = true
+	case "ELSEIF":
+		if !top.parentActive || top.taken {
+			p.releaseTokens(p.consumeUntilEOL())
+			top.active = false
+			return true, nil
+		}
+		cond, err := exprCondition(func(v int64) bool { return v != 0 })(p, tok)
+		if err != nil {
+			return true, contextualizeAt(tok.Line, tok.firstCol(), err)
+		}
+		top.active, top.taken = cond, cond
+	default: // ENDC, ENDIF
+		p.conds = p.conds[:len(p.conds)-1]
+	}
+	return true, nil
+}
+
+func (p *Parser) ensureConditionalsClosed() error {
+	if len(p.conds) == 0 {
+		return nil
+	}
+	return errorAtLine(p.conds[len(p.conds)-1].line, withCode(CodeUnbalancedBlock, fmt.Errorf("IF without matching ENDC")))
+}
@@ -0,0 +1,92 @@
+package asm
+
+import "strings"
+
+// devpacLexer adapts Devpac/vasm-mot source layout to the token stream the
+// parser expects, one line at a time:
+//
+//   - an identifier starting in column one is a label even without a colon,
+//     unless it names the symbol of EQU/SET/EQUR/REG/RS/MACRO;
+//   - ".name" is merged into a single identifier so local labels can be
+//     defined and referenced.
+type devpacLexer struct {
+	lx      *Lexer
+	pending []Token
+}
+
+// devpacNamingDirectives take the symbol they define from the label field.
+var devpacNamingDirectives = map[string]bool{
+	"EQU": true, "SET": true, "EQUR": true, "REG": true, "MACRO": true, "RS": true,
+}
+
+func (d *devpacLexer) Next() Token {
+	if len(d.pending) == 0 {
+		var line []Token
+		var starts []int
+		for {
+			t := d.lx.Next()
+			line = append(line, t)
+			starts = append(starts, d.lx.startCol)
+			if t.Kind == NEWLINE || t.Kind == EOF {
+				break
+			}
+		}
+		d.pending = rewriteDevpacLine(line, starts)
+	}
+	t := d.pending[0]
+	d.pending = d.pending[1:]
+	return t
+}
+
+func rewriteDevpacLine(line []Token, starts []int) []Token {
+	out := make([]Token, 0, len(line)+1)
+	columnOne := len(line) > 1 && starts[0] == 1
+	for i := 0; i < len(line); i++ {
+		t := line[i]
+		if t.Kind == DOT && i+1 < len(line) && line[i+1].Kind == IDENT && starts[i+1] == starts[i]+1 && mergesLocalLabel(out, line, i, columnOne) {
+			ident := line[i+1]
+			ident.Text = "." + ident.Text
+			out = append(out, ident)
+			i++
+			continue
+		}
+		out = append(out, t)
+	}
+
+	if columnOne && out[0].Kind == IDENT && len(out) > 1 && out[1].Kind != COLON && !isColumnOneDirective(out[0].Text) {
+		next := out[1]
+		base, _ := splitMnemonic(next.Text)
+		if next.Kind == DOT && len(out) > 2 {
+			base, _ = splitMnemonic(out[2].Text)
+		}
+		if next.Kind != EQUAL && !devpacNamingDirectives[base] {
+			colon := Token{Kind: COLON, Text: ":", Line: out[0].Line, Col: out[0].Col}
+			out = append(out[:1], append([]Token{colon}, out[1:]...)...)
+		}
+	}
+	return out
+}
+
+// mergesLocalLabel reports whether the DOT at line[i] starts a ".name" local
+// label rather than a directive (".text") or a size suffix ("(abs).w").
+func mergesLocalLabel(out, line []Token, i int, columnOne bool) bool {
+	if len(out) == 0 {
+		return columnOne || (i+2 < len(line) && line[i+2].Kind == COLON)
+	}
+	prev := line[i-1]
+	switch prev.Kind {
+	case RPAREN, NUMBER, IDENT, STRING:
+		// Directly attached to an operand: a size suffix.
+		return line[i].Col != prev.Col+1
+	}
+	return true
+}
+
+func isColumnOneDirective(name string) bool {
+	upper := strings.ToUpper(name)
+	switch upper {
+	case "ENDM", "ENDMACRO", "END", "ENDS":
+		return true
+	}
+	return conditionalDirectives[upper]
+}
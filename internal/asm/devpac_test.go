@@ -0,0 +1,163 @@
+package asm_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jenska/m68kasm/internal/asm"
+)
+
+// TestDevpacConformanceCorpus assembles every source in tests/testdata/devpac
+// and compares the output with the golden .hex file next to it.
+func TestDevpacConformanceCorpus(t *testing.T) {
+	sources, err := filepath.Glob(filepath.Join("..", "..", "tests", "testdata", "devpac", "*.s"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sources) == 0 {
+		t.Fatal("no Devpac corpus sources found")
+	}
+	for _, src := range sources {
+		t.Run(filepath.Base(src), func(t *testing.T) {
+			data, err := os.ReadFile(src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			golden, err := os.ReadFile(strings.TrimSuffix(src, ".s") + ".hex")
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := hex.DecodeString(strings.Join(strings.Fields(string(golden)), ""))
+			if err != nil {
+				t.Fatalf("bad golden file: %v", err)
+			}
+			got := assembleWithSyntax(t, string(data), asm.SyntaxDevpac)
+			if !bytes.Equal(got, want) {
+				t.Fatalf("output mismatch\n got: % X\nwant: % X", got, want)
+			}
+		})
+	}
+}
+
+func TestConditionalAssembly(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want []byte
+	}{
+		{"IfTrue", "IF 1\nNOP\nENDC\n", []byte{0x4E, 0x71}},
+		{"IfFalseElse", "IFNE 0\nNOP\nELSE\nRTS\nENDC\n", []byte{0x4E, 0x75}},
+		{"DottedForms", ".ifeq 0\nNOP\n.endif\n", []byte{0x4E, 0x71}},
+		{"ElseIf", "V = 2\nIF V==1\nNOP\nELSEIF V==2\nRTS\nELSE\nILLEGAL\nENDC\n", []byte{0x4E, 0x75}},
+		{"NestedSkipped", "IFEQ 1\nIFEQ 0\nNOP\nELSE\nNOP\nENDC\nENDC\nRTS\n", []byte{0x4E, 0x75}},
+		{"SkippedLinesAreNotParsed", "IFD MISSING\nthis is not assembly\nENDC\nRTS\n", []byte{0x4E, 0x75}},
+		{"DefinedIgnoresLaterLabels", "IFD later\nNOP\nENDC\nlater:\nRTS\n", []byte{0x4E, 0x75}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := assembleSource(t, tt.src)
+			if !bytes.Equal(got, tt.want) {
+				t.Fatalf("unexpected encoding for %q: got %x want %x", tt.src, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConditionalAssemblyErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"Unterminated", "IFEQ 0\nNOP\n", "IF without matching ENDC"},
+		{"StrayEnd", "NOP\nENDC\n", "ENDC without matching IF"},
+		{"StrayElse", "ELSE\n", "ELSE without matching IF"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := asm.ParseWithOptions(strings.NewReader(tt.src), asm.ParseOptions{})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestMotorolaStorageDirectives(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want []byte
+	}{
+		{"BareEqu", "FIVE EQU 5\nMOVEQ #FIVE,D0\n", []byte{0x70, 0x05}},
+		{"DefineStorage", "DS.W 2\nDC.B 1\n", []byte{0, 0, 0, 0, 1}},
+		{"DefineConstantBlock", "DCB.L 2,$12345678\n", []byte{0x12, 0x34, 0x56, 0x78, 0x12, 0x34, 0x56, 0x78}},
+		{"Cnop", "NOP\nCNOP 0,4\nRTS\n", []byte{0x4E, 0x71, 0, 0, 0x4E, 0x75}},
+		{"RsCounter", "RSSET 4\na RS.L 1\nb RS.B 2\nc RS.W 0\nDC.B a,b,c\n", []byte{4, 8, 10}},
+		{"RegisterAlias", "ptr EQUR A3\nMOVE.L (ptr)+,D0\n", []byte{0x20, 0x1B}},
+		{"IndexAlias", "idx EQUR D2\nMOVE.B (A0,idx.W),D0\n", []byte{0x10, 0x30, 0x20, 0x00}},
+		{"RegisterList", "regs REG D0-D1/A0\nMOVEM.L regs,-(SP)\n", []byte{0x48, 0xE7, 0xC0, 0x80}},
+		{"StringBytes", "DC.B \"AB\",0\n", []byte{0x41, 0x42, 0x00}},
+		{"PackedString", "DC.L \"TAG\"\n", []byte{0x00, 0x54, 0x41, 0x47}},
+		{"StarIsLocationCounter", "NOP\nDC.W *\n", []byte{0x4E, 0x71, 0x00, 0x02}},
+		{"EndStopsAssembly", "NOP\nEND\nRTS\n", []byte{0x4E, 0x71}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := assembleSource(t, tt.src)
+			if !bytes.Equal(got, tt.want) {
+				t.Fatalf("unexpected encoding for %q: got %x want %x", tt.src, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegisterAliasScoping(t *testing.T) {
+	tests := []struct {
+		name   string
+		src    string
+		syntax asm.Syntax
+		want   []byte
+	}{
+		{"AliasOfAlias", "ptr EQUR A2\nsrc EQUR ptr\nMOVE.L (src)+,D0\n", asm.SyntaxMotorola, []byte{0x20, 0x1A}},
+		{"ListWithAlias", "ptr EQUR A2\nsaved REG D2-D3/ptr\nMOVEM.L saved,-(SP)\n", asm.SyntaxMotorola, []byte{0x48, 0xE7, 0x30, 0x20}},
+		{"DefinedInMacroBody", ".macro CLR1\n.local tmp\ntmp EQUR D1\nMOVEQ #0,tmp\n.endmacro\nCLR1\nCLR1\n", asm.SyntaxMotorola, []byte{0x72, 0x00, 0x72, 0x00}},
+		{"LocalToLabel", "a:\n@p equr a0\n move.l (@p)+,d0\nb:\n@p equr a1\n move.l (@p)+,d0\n", asm.SyntaxASM68K, []byte{0x20, 0x18, 0x20, 0x19}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := assembleWithSyntax(t, tt.src, tt.syntax)
+			if !bytes.Equal(got, tt.want) {
+				t.Fatalf("unexpected encoding for %q: got %x want %x", tt.src, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegisterAliasErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"Redefined", "ptr EQUR A2\nptr EQUR A3\n", "register alias ptr already defined at line 1"},
+		{"ShadowsLabel", "ptr: NOP\nptr EQUR A2\n", "register alias ptr shadows the label defined at line 1"},
+		{"ShadowsConstant", "ptr EQU 1\nptr EQUR A2\n", "register alias ptr shadows the constant defined at line 1"},
+		{"LabelShadowsAlias", "ptr EQUR A2\nptr: NOP\n", "ptr shadows the register alias defined at line 1"},
+		{"ConstantShadowsAlias", "ptr REG D0-D1\nptr EQU 1\n", "ptr shadows the register alias defined at line 1"},
+		{"RegisterName", "D3 EQUR A2\n", "register alias D3 cannot be a register name"},
+		{"BadList", "regs REG D0,5\n", "REG expects a register list"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := asm.Parse(strings.NewReader(tt.src))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,322 @@
+package asm
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/jenska/m68kasm/internal/asm/instructions"
+)
+
+// Severity tells warnings from errors.
+type Severity uint8
+
+const (
+	SeverityWarning Severity = iota
+	SeverityError
+)
+
+func (s Severity) String() string {
+	if s == SeverityError {
+		return "error"
+	}
+	return "warning"
+}
+
+// Category names a class of warnings that can be turned on and off as a
+// whole, as "-W<category>" and "-Wno-<category>" do on the command line.
+type Category string
+
+const (
+	// WarnOddAddress flags word and long accesses to an odd address, which
+	// raise an address error on the 68000.
+	WarnOddAddress Category = "odd-address"
+	// WarnBranchNext flags branches to the instruction that follows them.
+	WarnBranchNext Category = "branch-next"
+	// WarnUnused flags labels, constants and macros that are never used.
+	WarnUnused Category = "unused"
+	// WarnShadow flags symbols in a namespace that hide a global symbol and
+	// macros named like an instruction or directive.
+	WarnShadow Category = "shadow"
+	// WarnTruncation flags values that ParseOptions.AllowTruncation cut to
+	// their field.
+	WarnTruncation Category = "truncation"
+	// WarnOrgGap flags a label on an ORG line that moves the location
+	// counter, as the label keeps the address before the gap.
+	WarnOrgGap Category = "org-gap"
+	// WarnImplicitSize flags instructions that take several sizes and were
+	// written without one.
+	WarnImplicitSize Category = "implicit-size"
+	// WarnInform holds INFORM messages of severity 1.
+	WarnInform Category = "inform"
+	// WarnPIC flags absolute references to .text labels; see
+	// ParseOptions.AuditPIC.
+	WarnPIC Category = "pic"
+)
+
+// WarningCategories lists every category with whether it is on by default.
+var WarningCategories = []struct {
+	Category Category
+	Default  bool
+}{
+	{WarnOddAddress, true},
+	{WarnBranchNext, true},
+	{WarnUnused, false},
+	{WarnShadow, true},
+	{WarnTruncation, true},
+	{WarnOrgGap, true},
+	{WarnImplicitSize, false},
+	{WarnInform, true},
+	{WarnPIC, false},
+}
+
+// Diagnostic is a warning raised while assembling. Its position follows the
+// rules of Error: inside a macro expansion it points into the macro body and
+// lists the invocations in Expansions.
+type Diagnostic struct {
+	Severity   Severity
+	Category   Category
+	Line       int
+	Col        int
+	LineText   string
+	Message    string
+	Expansions []MacroFrame
+}
+
+// String formats d like an Error, with the category appended in the way
+// it is turned off: "line 3: warning: ... [-Wodd-address]".
+func (d Diagnostic) String() string {
+	e := d.asError()
+	e.Err = fmt.Errorf("%s: %s [-W%s]", d.Severity, d.Message, d.Category)
+	return e.Error()
+}
+
+func (d Diagnostic) asError() *Error {
+	return &Error{Line: d.Line, Col: d.Col, LineText: d.LineText, Err: errors.New(d.Message), Expansions: d.Expansions}
+}
+
+// enabledWarnings applies the overrides of opts to the default categories.
+// "all" switches every category and is applied before the others.
+func enabledWarnings(opts ParseOptions) map[Category]bool {
+	enabled := make(map[Category]bool, len(WarningCategories))
+	for _, c := range WarningCategories {
+		enabled[c.Category] = c.Default
+	}
+	if on, ok := opts.Warnings["all"]; ok {
+		for c := range enabled {
+			enabled[c] = on
+		}
+	}
+	for name, on := range opts.Warnings {
+		if name != "all" {
+			enabled[Category(name)] = on
+		}
+	}
+	if opts.AuditPIC {
+		enabled[WarnPIC] = true
+	}
+	return enabled
+}
+
+// warn records a warning of category at line. Warnings come from the strict
+// passes only, as a lenient pass sees forward references as 0.
+func (p *Parser) warn(category Category, line int, format string, args ...any) {
+	if p.allowForwardRefs || !p.warnings[category] {
+		return
+	}
+	e := &Error{Line: line, Err: fmt.Errorf(format, args...)}
+	if line == p.line {
+		p.lineOrigin.locate(e)
+	}
+	p.diagnostics = append(p.diagnostics, Diagnostic{
+		Severity:   SeverityWarning,
+		Category:   category,
+		Line:       e.Line,
+		Message:    e.Message(),
+		Expansions: e.Expansions,
+	})
+}
+
+// warningErrors returns the warnings of prog as errors, for
+// ParseOptions.WarningsAsErrors.
+func warningErrors(prog *Program) error {
+	errs := make([]error, len(prog.Warnings))
+	for i, d := range prog.Warnings {
+		e := d.asError()
+		e.Code = Code(d.Category)
+		e.Err = fmt.Errorf("%s [-Werror=%s]", d.Message, d.Category)
+		errs[i] = e
+	}
+	return joinErrors(errs, 0)
+}
+
+// truncated reports err, a value that does not fit its field, as
+// CodeOutOfRange unless
+// ParseOptions.AllowTruncation keeps its low bits, which is a warning.
+func (p *Parser) truncated(err error) error {
+	if !p.allowTruncation {
+		return withCode(CodeOutOfRange, err)
+	}
+	p.warn(WarnTruncation, p.line, "%v, truncated", err)
+	return nil
+}
+
+// checkInstruction raises the warnings about an instruction that parsed.
+// next is the address of the following instruction.
+func (p *Parser) checkInstruction(mn Token, def *instructions.InstrDef, form *instructions.FormDef, args *instructions.Args, next uint32) {
+	if p.allowForwardRefs {
+		return
+	}
+	p.checkImmediateTruncation(form, args)
+	if hasOperandKind(form, instructions.OpkDispRel) {
+		if target, ok := p.branchTarget(args); ok && target == int64(next) && strings.HasPrefix(def.Mnemonic, "B") && def.Mnemonic != "BSR" {
+			p.warn(WarnBranchNext, mn.Line, "branch to the next instruction")
+		}
+		return
+	}
+	if len(form.Sizes) > 1 && !strings.HasPrefix(def.Mnemonic, "DB") && !hasSizeSuffix(mn) {
+		p.warn(WarnImplicitSize, mn.Line, "%s without a size, assuming .%s", def.Mnemonic, sizeLetter(args.Size))
+	}
+	if args.Size == instructions.ByteSize || noDataAccess[def.Mnemonic] {
+		return
+	}
+	for _, ea := range []instructions.EAExpr{args.Src, args.Dst} {
+		if addr, ok := operandAddress(ea); ok && addr%2 != 0 {
+			p.warn(WarnOddAddress, mn.Line, ".%s access to odd address $%X", sizeLetter(args.Size), uint32(addr))
+		}
+	}
+}
+
+// branchTarget returns the address a branch goes to. Labels named on their
+// own are resolved when assembling; forward ones are known from the
+// previous pass.
+func (p *Parser) branchTarget(args *instructions.Args) (int64, bool) {
+	if args.HasTargetAddr {
+		return args.TargetAddr, true
+	}
+	addr, ok := p.labels[args.Target]
+	return int64(addr), ok
+}
+
+// noDataAccess holds the instructions whose memory operand is an address
+// only, which may be odd.
+var noDataAccess = map[string]bool{"LEA": true, "PEA": true, "JMP": true, "JSR": true}
+
+// checkImmediateTruncation warns about immediates that Validate accepts
+// only because ParseOptions.AllowTruncation skips its range check.
+func (p *Parser) checkImmediateTruncation(form *instructions.FormDef, args *instructions.Args) {
+	if !p.allowTruncation || form.Validate == nil {
+		return
+	}
+	strict := *args
+	strict.AllowTruncation = false
+	if err := form.Validate(&strict); err != nil {
+		lenient := *args
+		if form.Validate(&lenient) == nil {
+			p.warn(WarnTruncation, p.line, "%v, truncated", err)
+		}
+	}
+}
+
+// operandAddress returns the memory address an absolute or label(PC)
+// operand accesses.
+func operandAddress(ea instructions.EAExpr) (int64, bool) {
+	switch ea.Kind {
+	case instructions.EAkAbsW:
+		return int64(int16(ea.Abs16)), true
+	case instructions.EAkAbsL:
+		return int64(ea.Abs32), true
+	case instructions.EAkPCDisp16:
+		// The parser keeps the target address; the encoder subtracts the
+		// address of the extension word.
+		return int64(ea.Disp16), true
+	}
+	return 0, false
+}
+
+func hasOperandKind(form *instructions.FormDef, kind instructions.OperandKind) bool {
+	return slices.Contains(form.OperKinds, kind)
+}
+
+func hasSizeSuffix(mn Token) bool {
+	return strings.Contains(mn.Text, ".")
+}
+
+func sizeLetter(sz instructions.Size) string {
+	switch sz {
+	case instructions.ByteSize:
+		return "B"
+	case instructions.LongSize:
+		return "L"
+	default:
+		return "W"
+	}
+}
+
+// checkShadowedSymbol warns when name, defined in a namespace as full, hides
+// a global symbol of the same name.
+func (p *Parser) checkShadowedSymbol(name, full string, line int) {
+	if full == name || isLocalName(name) || strings.Contains(name, "::") {
+		return
+	}
+	if global, ok := p.lookupDefinedLabel(name); ok {
+		p.warn(WarnShadow, line, "%s shadows %s defined at line %d", full, name, global.Line)
+	}
+}
+
+// checkMacroName warns about a macro that hides an instruction or
+// directive, as macros are looked up first.
+func (p *Parser) checkMacroName(name Token) {
+	base, _ := splitMnemonic(name.Text)
+	if p.instrs.Lookup(base) != nil {
+		p.warn(WarnShadow, name.Line, "macro %s hides the instruction %s", name.Text, base)
+	} else if directiveNames["."+strings.ToUpper(name.Text)] {
+		p.warn(WarnShadow, name.Line, "macro %s hides the directive %s", name.Text, strings.ToUpper(name.Text))
+	}
+}
+
+// directiveNames holds the keys of pseudoMap, which checkMacroName cannot
+// read directly as pseudoMap refers to the MACRO directive.
+var directiveNames = map[string]bool{}
+
+func init() {
+	for name := range pseudoMap {
+		directiveNames[name] = true
+	}
+}
+
+// checkUnused warns about the symbols and macros defined in the source that
+// nothing refers to. Exported symbols and names generated by macro
+// expansion are left out.
+func (p *Parser) checkUnused() {
+	if p.allowForwardRefs || !p.warnings[WarnUnused] {
+		return
+	}
+	var unused []Diagnostic
+	for _, l := range p.definedLabels {
+		if p.used[l.Name] || p.globals[l.Name] || p.expanded[l.Name] {
+			continue
+		}
+		unused = append(unused, Diagnostic{Category: WarnUnused, Line: l.Line, Message: fmt.Sprintf("%s %s is never used", l.Kind, l.Name)})
+	}
+	for name, def := range p.macros {
+		if !p.usedMacros[name] {
+			unused = append(unused, Diagnostic{Category: WarnUnused, Line: def.line, Message: fmt.Sprintf("macro %s is never used", name)})
+		}
+	}
+	slices.SortStableFunc(unused, func(a, b Diagnostic) int { return a.Line - b.Line })
+	p.diagnostics = append(p.diagnostics, unused...)
+}
+
+// checkOrgLabel warns about a label on the ORG line when ORG moves the
+// location counter forward, as the label keeps the address before the gap.
+func (p *Parser) checkOrgLabel(newPC uint32) {
+	if newPC <= p.pc || len(p.definedLabels) == 0 {
+		return
+	}
+	l := p.definedLabels[len(p.definedLabels)-1]
+	if l.Kind == SymbolLabel && l.Line == p.line && l.Addr == p.pc {
+		p.warn(WarnOrgGap, p.line, "label %s is at $%X, before the ORG to $%X", l.Name, l.Addr, newPC)
+	}
+}
@@ -0,0 +1,201 @@
+package asm_test
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/jenska/m68kasm/internal/asm"
+)
+
+// warningLines returns the first line of each warning of prog, without the
+// source line and caret.
+func warningLines(prog *asm.Program) []string {
+	var lines []string
+	for _, d := range prog.Warnings {
+		first, _, _ := strings.Cut(d.String(), "\n")
+		lines = append(lines, first)
+	}
+	return lines
+}
+
+func TestWarnings(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		opts asm.ParseOptions
+		want []string
+	}{
+		{
+			name: "OddAddress",
+			src:  "MOVE.W $1001,D0\nMOVE.L D0,$20003.L\nMOVE.B $1001,D0\nLEA $1001,A0\n",
+			want: []string{
+				"line 1: warning: .W access to odd address $1001 [-Wodd-address]",
+				"line 2: warning: .L access to odd address $20003 [-Wodd-address]",
+			},
+		},
+		{
+			name: "OddLabelPCRelative",
+			src:  "MOVE.W tab(PC),D0\nDC.B 0\ntab: DC.B 1,2\n",
+			want: []string{"line 1: warning: .W access to odd address $5 [-Wodd-address]"},
+		},
+		{
+			name: "BranchNext",
+			src:  "BEQ.S next\nNOP\nnext: BRA.W fin\nfin: BSR.W sub\nsub: RTS\n",
+			want: []string{"line 3: warning: branch to the next instruction [-Wbranch-next]"},
+		},
+		{
+			name: "Unused",
+			src:  "start: BRA loop\nNOP\nloop: NOP\nN EQU 3\nM EQU N\nsetup MACRO\nENDM\nused MACRO\nENDM\nused\n.globl entry\nentry: RTS\n",
+			opts: asm.ParseOptions{Warnings: map[string]bool{"unused": true}},
+			want: []string{
+				"line 1: warning: label start is never used [-Wunused]",
+				"line 5: warning: constant M is never used [-Wunused]",
+				"line 6: warning: macro setup is never used [-Wunused]",
+			},
+		},
+		{
+			name: "UnusedSkipsMacroLabels",
+			src:  "mk MACRO\nl\\@: NOP\nENDM\nmk\n",
+			opts: asm.ParseOptions{Warnings: map[string]bool{"unused": true}},
+		},
+		{
+			name: "ShadowedSymbol",
+			src:  "count EQU 1\n.namespace gfx\ncount EQU 2\n.endnamespace\n",
+			want: []string{"line 3: warning: gfx::count shadows count defined at line 1 [-Wshadow]"},
+		},
+		{
+			name: "ShadowingMacro",
+			src:  ".macro nop\n.endm\n.macro even\n.endm\n",
+			want: []string{
+				"line 1: warning: macro nop hides the instruction NOP [-Wshadow]",
+				"line 3: warning: macro even hides the directive EVEN [-Wshadow]",
+			},
+		},
+		{
+			name: "Truncation",
+			src:  "DC.B 300\nMOVE.B #$1234,D0\nMOVE.W 40000(A0),D0\n",
+			opts: asm.ParseOptions{AllowTruncation: true},
+			want: []string{
+				"line 1: warning: .byte value out of 8-bit range: 300, truncated [-Wtruncation]",
+				"line 2: warning: immediate out of range for .b: 4660, truncated [-Wtruncation]",
+				"line 3: warning: displacement out of 16-bit range: 40000, truncated [-Wtruncation]",
+			},
+		},
+		{
+			name: "LabelOnOrg",
+			src:  "NOP\nstart: ORG $10\nNOP\nhere: ORG $12\n",
+			want: []string{"line 2: warning: label start is at $2, before the ORG to $10 [-Worg-gap]"},
+		},
+		{
+			name: "ImplicitSize",
+			src:  "CLR D0\nCLR.L D1\nMOVEQ #1,D2\nBRA next\nNOP\nnext: ADD D0,D1\n",
+			opts: asm.ParseOptions{Warnings: map[string]bool{"implicit-size": true}},
+			want: []string{
+				"line 1: warning: CLR without a size, assuming .W [-Wimplicit-size]",
+				"line 6: warning: ADD without a size, assuming .W [-Wimplicit-size]",
+			},
+		},
+		{
+			name: "Inform",
+			src:  "INFORM 1,\"check %d\",3\n",
+			want: []string{"line 1: warning: check 3 [-Winform]"},
+		},
+		{
+			name: "InMacroBody",
+			src:  "rd MACRO\nMOVE.W \\1,D0\nENDM\nrd $1001\n",
+			want: []string{"line 2: warning: .W access to odd address $1001 [-Wodd-address]"},
+		},
+		{
+			name: "DisabledCategory",
+			src:  "MOVE.W $1001,D0\nINFORM 1,\"x\"\n",
+			opts: asm.ParseOptions{Warnings: map[string]bool{"odd-address": false}},
+			want: []string{"line 2: warning: x [-Winform]"},
+		},
+		{
+			name: "AllOff",
+			src:  "MOVE.W $1001,D0\nINFORM 1,\"x\"\n",
+			opts: asm.ParseOptions{Warnings: map[string]bool{"all": false}},
+		},
+		{
+			name: "AllOnExceptOne",
+			src:  "CLR D0\nMOVE $1001,D0\n",
+			opts: asm.ParseOptions{Warnings: map[string]bool{"all": true, "odd-address": false}},
+			want: []string{
+				"line 1: warning: CLR without a size, assuming .W [-Wimplicit-size]",
+				"line 2: warning: MOVE without a size, assuming .W [-Wimplicit-size]",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prog, err := asm.ParseWithOptions(strings.NewReader(tt.src), tt.opts)
+			if err != nil {
+				t.Fatalf("parse failed: %v", err)
+			}
+			if got := warningLines(prog); !slices.Equal(got, tt.want) {
+				t.Fatalf("warnings = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMoveByteToAddressRegisterFails(t *testing.T) {
+	// The 68000 has no byte write to an address register; MOVEA.W would
+	// load a word instead, so this stays an error rather than a warning.
+	prog, err := asm.Parse(strings.NewReader("MOVE.B D0,A0\n"))
+	if err == nil {
+		_, err = asm.Assemble(prog)
+	}
+	if err == nil || !strings.Contains(err.Error(), "MOVE.B cannot write to address register") {
+		t.Fatalf("expected MOVE.B D0,A0 to fail, got %v", err)
+	}
+}
+
+func TestWarningDiagnostic(t *testing.T) {
+	src := "rd MACRO\nMOVE.W \\1,D0\nENDM\nNOP\nrd $1001\n"
+	prog, err := asm.Parse(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if len(prog.Warnings) != 1 {
+		t.Fatalf("expected one warning, got %v", prog.Warnings)
+	}
+	d := prog.Warnings[0]
+	if d.Severity != asm.SeverityWarning || d.Category != asm.WarnOddAddress || d.Line != 2 || d.LineText != "MOVE.W \\1,D0" {
+		t.Fatalf("unexpected diagnostic %+v", d)
+	}
+	if len(d.Expansions) != 1 || d.Expansions[0].Macro != "rd" || d.Expansions[0].Line != 5 {
+		t.Fatalf("unexpected expansions %v", d.Expansions)
+	}
+}
+
+func TestWarningsOncePerAssembly(t *testing.T) {
+	// The jump settles over several passes; only the last one reports.
+	src := "start: JRA fin\nDCB.B 250,0\nfin: MOVE.W $1001,D0\n"
+	prog, err := asm.Parse(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if got := warningLines(prog); len(got) != 1 {
+		t.Fatalf("warnings = %q, want one", got)
+	}
+}
+
+func TestWarningsAsErrors(t *testing.T) {
+	src := "NOP\nMOVE.W $1001,D0\n"
+	_, err := asm.ParseWithOptions(strings.NewReader(src), asm.ParseOptions{WarningsAsErrors: true})
+	var asmErr *asm.Error
+	if !errors.As(err, &asmErr) {
+		t.Fatalf("expected *asm.Error, got %v", err)
+	}
+	if asmErr.Line != 2 || asmErr.Message() != ".W access to odd address $1001 [-Werror=odd-address]" {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	opts := asm.ParseOptions{WarningsAsErrors: true, Warnings: map[string]bool{"odd-address": false}}
+	if _, err := asm.ParseWithOptions(strings.NewReader(src), opts); err != nil {
+		t.Fatalf("disabled warning failed the assembly: %v", err)
+	}
+}
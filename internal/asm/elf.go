@@ -31,8 +31,12 @@ const (
 	elfShfAlloc    = 0x2
 	elfShfExec     = 0x4
 	elfStbLocal    = 0
+	elfStbGlobal   = 1
 	elfSttNotype   = 0
+	elfSttObject   = 1
+	elfSttFunc     = 2
 	elfSttSection  = 3
+	elfShnAbs      = 0xFFF1
 )
 
 const (
@@ -181,7 +185,7 @@ func assembleELFLayout(p *Program) (elfLayout, error) {
 	}
 
 	if layout.bssPresent && (layout.dataPresent || layout.textPresent) && layout.bssAddr < initializedEnd {
-		return elfLayout{}, fmt.Errorf(".bss must come after initialized sections in ELF mode")
+		return elfLayout{}, withCode(CodeSection, fmt.Errorf(".bss must come after initialized sections in ELF mode"))
 	}
 
 	if layout.bssPresent && !layout.dataPresent && !layout.textPresent {
@@ -255,13 +259,29 @@ func formatELFLayout(layout elfLayout) []byte {
 			shndx: sectionToELFIndex(section),
 		})
 	}
-	for _, label := range layout.definedLabels {
-		symbols = append(symbols, elfSymbol{
-			name:  strtab.add(label.Name),
-			value: label.Addr,
-			info:  elfStInfo(elfStbLocal, elfSttNotype),
-			shndx: sectionToELFIndex(label.Section),
-		})
+	// ELF requires all local symbols to precede the global ones; sh_info of the
+	// symbol table holds the index of the first global symbol.
+	firstGlobal := len(symbols)
+	for _, global := range []bool{false, true} {
+		if global {
+			firstGlobal = len(symbols)
+		}
+		for _, label := range layout.definedLabels {
+			if label.Global != global {
+				continue
+			}
+			bind := byte(elfStbLocal)
+			if global {
+				bind = elfStbGlobal
+			}
+			typ, shndx := elfSymbolType(label)
+			symbols = append(symbols, elfSymbol{
+				name:  strtab.add(label.Name),
+				value: label.Addr,
+				info:  elfStInfo(bind, typ),
+				shndx: shndx,
+			})
+		}
 	}
 	symtabBytes := encodeELFSymbols(symbols)
 
@@ -314,7 +334,7 @@ func formatELFLayout(layout elfLayout) []byte {
 			offset:    uint32(symtabOffset),
 			size:      uint32(len(symtabBytes)),
 			link:      elfSectionStrtab,
-			info:      uint32(len(symbols)),
+			info:      uint32(firstGlobal),
 			addralign: 4,
 			entsize:   elfSymbolSize,
 		},
@@ -392,9 +412,22 @@ func (layout elfLayout) sectionHasSymbols(section SectionKind) bool {
 	}
 }
 
+// elfSymbolType maps code labels to functions and data labels to objects.
+// Constants and variables are absolute values outside any section.
+func elfSymbolType(label DefinedLabel) (byte, uint16) {
+	switch {
+	case label.Kind != SymbolLabel:
+		return elfSttNotype, elfShnAbs
+	case label.Section == SectionText:
+		return elfSttFunc, sectionToELFIndex(label.Section)
+	default:
+		return elfSttObject, sectionToELFIndex(label.Section)
+	}
+}
+
 func hasSectionLabel(labels []DefinedLabel, section SectionKind) bool {
 	for _, label := range labels {
-		if label.Section == section {
+		if label.Kind == SymbolLabel && label.Section == section {
 			return true
 		}
 	}
@@ -339,3 +339,67 @@ func readString(table []byte, off uint32) string {
 	}
 	return string(table[off:end])
 }
+
+func TestFormatELFWithLabels_GlobalSymbolsFollowLocals(t *testing.T) {
+	code := []byte{0x4E, 0x71, 0x4E, 0x75}
+	labels := []DefinedLabel{
+		{Name: "entry", Addr: 0, Global: true},
+		{Name: "helper", Addr: 2},
+	}
+
+	elf := FormatELFWithLabels(code, 0, labels)
+	sections := readSectionHeaders(t, elf)
+	symtab := sections[elfSectionSymtab]
+	strtab := sections[elfSectionStrtab]
+	strtabBytes := elf[strtab.offset : strtab.offset+strtab.size]
+
+	if symtab.info != 3 {
+		t.Fatalf("symtab info=%d want 3 (first global)", symtab.info)
+	}
+	symbols := readSymbols(t, elf[symtab.offset:symtab.offset+symtab.size])
+	if got := readString(strtabBytes, symbols[2].name); got != "helper" || symbols[2].info != elfStInfo(elfStbLocal, elfSttFunc) {
+		t.Fatalf("expected local helper at index 2, got %q info=0x%X", got, symbols[2].info)
+	}
+	if got := readString(strtabBytes, symbols[3].name); got != "entry" || symbols[3].info != elfStInfo(elfStbGlobal, elfSttFunc) {
+		t.Fatalf("expected global entry at index 3, got %q info=0x%X", got, symbols[3].info)
+	}
+}
+
+func TestAssembleELF_SymbolKinds(t *testing.T) {
+	src := "SIZE EQU 4\n.text\nstart: NOP\n.data\ntable: .word SIZE\n"
+	prog, err := ParseWithOptions(strings.NewReader(src), ParseOptions{})
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	elf, err := AssembleELF(prog)
+	if err != nil {
+		t.Fatalf("assemble failed: %v", err)
+	}
+	sections := readSectionHeaders(t, elf)
+	symtab := sections[elfSectionSymtab]
+	strtab := sections[elfSectionStrtab]
+	strtabBytes := elf[strtab.offset : strtab.offset+strtab.size]
+
+	want := map[string]struct {
+		info  byte
+		shndx uint16
+	}{
+		"SIZE":  {elfStInfo(elfStbLocal, elfSttNotype), elfShnAbs},
+		"start": {elfStInfo(elfStbLocal, elfSttFunc), elfSectionText},
+		"table": {elfStInfo(elfStbLocal, elfSttObject), elfSectionData},
+	}
+	found := 0
+	for _, sym := range readSymbols(t, elf[symtab.offset:symtab.offset+symtab.size]) {
+		w, ok := want[readString(strtabBytes, sym.name)]
+		if !ok {
+			continue
+		}
+		found++
+		if sym.info != w.info || sym.shndx != w.shndx {
+			t.Fatalf("symbol %q: info=0x%X shndx=%d, want info=0x%X shndx=%d", readString(strtabBytes, sym.name), sym.info, sym.shndx, w.info, w.shndx)
+		}
+	}
+	if found != len(want) {
+		t.Fatalf("found %d of %d symbols", found, len(want))
+	}
+}
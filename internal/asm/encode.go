@@ -15,6 +15,13 @@ type Instr struct {
 	Line    int
 	Col     int
 	Section SectionKind
+	// origin locates an instruction expanded from a macro body.
+	origin *macroOrigin
+	// mnemonic is the mnemonic as written, whose case hints follow.
+	mnemonic string
+	// immediate holds the tokens of the immediate operand, where range
+	// errors point.
+	immediate []Token
 }
 
 func sizeToBits(sz instructions.Size) uint16 {
@@ -199,12 +206,12 @@ func Encode(def *instructions.InstrDef, form *instructions.FormDef, ins *Instr,
 		if ins.Args.Target != "" {
 			resolved, ok := sym[ins.Args.Target]
 			if !ok {
-				return nil, fmt.Errorf("undefined label: %s", ins.Args.Target)
+				return nil, withCode(CodeUndefinedSymbol, withHint(fmt.Errorf("undefined label: %s", ins.Args.Target), labelHint(ins.Args.Target, sym)))
 			}
 			addr = resolved
 		} else {
 			if ins.Args.TargetAddr < 0 || ins.Args.TargetAddr > math.MaxUint32 {
-				return nil, fmt.Errorf("branch target out of 32-bit range: %d", ins.Args.TargetAddr)
+				return nil, withCode(CodeOutOfRange, fmt.Errorf("branch target out of 32-bit range: %d", ins.Args.TargetAddr))
 			}
 			addr = uint32(ins.Args.TargetAddr)
 		}
@@ -214,7 +221,7 @@ func Encode(def *instructions.InstrDef, form *instructions.FormDef, ins *Instr,
 		case instructions.ByteSize:
 			d8 := int32(addr) - int32(basePC)
 			if d8 < -128 || d8 > 127 {
-				return nil, fmt.Errorf("branch displacement out of range for .S")
+				return nil, withCode(CodeOutOfRange, fmt.Errorf("branch displacement out of range for .S"))
 			}
 			p.BrUseWord = false
 			p.BrDisp8 = int8(d8)
@@ -225,7 +232,7 @@ func Encode(def *instructions.InstrDef, form *instructions.FormDef, ins *Instr,
 				d16 = -2
 			}
 			if d16 < -32768 || d16 > 32767 {
-				return nil, fmt.Errorf("branch displacement out of range for .W")
+				return nil, withCode(CodeOutOfRange, fmt.Errorf("branch displacement out of range for .W"))
 			}
 			p.BrUseWord = true
 			p.BrDisp16 = int16(d16)
@@ -1,6 +1,8 @@
 package asm_test
 
 import (
+	"errors"
+	"reflect"
 	"strings"
 	"testing"
 
@@ -65,3 +67,258 @@ func TestParseErrorUsesReadableTokenNames(t *testing.T) {
 		t.Fatalf("expected readable token names, got:\n%s", msg)
 	}
 }
+
+func TestMacroErrorBacktrace(t *testing.T) {
+	src := ".macro LOAD v\n nop\n bogus v\n.endmacro\n.macro OUTER x\n LOAD x\n.endmacro\n OUTER #1\n"
+
+	_, err := asm.Parse(strings.NewReader(src))
+	var asmErr *asm.Error
+	if !errors.As(err, &asmErr) {
+		t.Fatalf("expected *asm.Error, got %v", err)
+	}
+	if asmErr.Line != 3 || asmErr.LineText != " bogus v" {
+		t.Fatalf("expected error on macro body line 3, got line %d %q", asmErr.Line, asmErr.LineText)
+	}
+	want := []asm.MacroFrame{{Macro: "LOAD", Line: 6, Col: 2}, {Macro: "OUTER", Line: 8, Col: 2}}
+	if !reflect.DeepEqual(asmErr.Expansions, want) {
+		t.Fatalf("unexpected expansion frames %+v", asmErr.Expansions)
+	}
+	msg := err.Error()
+	if !strings.Contains(msg, "in expansion of macro LOAD at line 6\n  in expansion of macro OUTER at line 8") {
+		t.Fatalf("expected backtrace in error, got:\n%s", msg)
+	}
+}
+
+func TestMacroAssembleErrorBacktrace(t *testing.T) {
+	src := ".macro JUMP\n nop\n BRA missing\n.endmacro\n JUMP\n"
+
+	prog, err := asm.Parse(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	_, err = asm.Assemble(prog)
+	var asmErr *asm.Error
+	if !errors.As(err, &asmErr) {
+		t.Fatalf("expected *asm.Error, got %v", err)
+	}
+	if asmErr.Line != 3 || len(asmErr.Expansions) != 1 || asmErr.Expansions[0].Line != 5 {
+		t.Fatalf("unexpected location line %d frames %+v", asmErr.Line, asmErr.Expansions)
+	}
+	if !strings.Contains(err.Error(), " BRA missing") {
+		t.Fatalf("expected macro body line in error, got:\n%s", err.Error())
+	}
+}
+
+func TestParseCollectsErrors(t *testing.T) {
+	src := "NOP\nFOO D0\nloop: MOVE.W D0\nDC.B 300\nBRA.S loop\n"
+
+	_, err := asm.Parse(strings.NewReader(src))
+	var list *asm.ErrorList
+	if !errors.As(err, &list) {
+		t.Fatalf("expected *asm.ErrorList, got %T: %v", err, err)
+	}
+	var lines []int
+	for _, e := range list.Errors {
+		var asmErr *asm.Error
+		if !errors.As(e, &asmErr) {
+			t.Fatalf("expected *asm.Error, got %T: %v", e, e)
+		}
+		lines = append(lines, asmErr.Line)
+	}
+	if !reflect.DeepEqual(lines, []int{2, 3, 4}) || list.Limited {
+		t.Fatalf("errors at lines %v (limited %v), want [2 3 4]", lines, list.Limited)
+	}
+
+	var first *asm.Error
+	if !errors.As(err, &first) || first.Line != 2 || first.LineText != "FOO D0" {
+		t.Fatalf("errors.As did not reach the first error: %+v", first)
+	}
+	if !strings.Contains(err.Error(), "unknown mnemonic") || !strings.Contains(err.Error(), ".byte value out of 8-bit range") {
+		t.Fatalf("expected every message in %q", err.Error())
+	}
+}
+
+func TestParseCollectsEncodeErrors(t *testing.T) {
+	src := " bra nowhere\n foo\n move.w x,d0\n move.l (a0)+,#1\n"
+
+	_, err := asm.Parse(strings.NewReader(src))
+	var list *asm.ErrorList
+	if !errors.As(err, &list) {
+		t.Fatalf("expected *asm.ErrorList, got %T: %v", err, err)
+	}
+	want := []string{"undefined label: nowhere", "unknown mnemonic", "undefined label in expression: x", "MOVE destination cannot be immediate"}
+	if len(list.Errors) != len(want) {
+		t.Fatalf("got %d errors, want %d:\n%v", len(list.Errors), len(want), err)
+	}
+	for i, w := range want {
+		var asmErr *asm.Error
+		if !errors.As(list.Errors[i], &asmErr) || asmErr.Line != i+1 || !strings.Contains(asmErr.Error(), w) {
+			t.Fatalf("error %d = %v, want %q at line %d", i, list.Errors[i], w, i+1)
+		}
+	}
+}
+
+func TestMaxErrors(t *testing.T) {
+	src := strings.Repeat("FOO\n", 25)
+	tests := []struct {
+		name    string
+		max     int
+		want    int
+		limited bool
+	}{
+		{"Default", 0, asm.DefaultMaxErrors, true},
+		{"Limit", 3, 3, true},
+		{"NoLimit", -1, 25, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := asm.ParseWithOptions(strings.NewReader(src), asm.ParseOptions{MaxErrors: tt.max})
+			var list *asm.ErrorList
+			if !errors.As(err, &list) {
+				t.Fatalf("expected *asm.ErrorList, got %T: %v", err, err)
+			}
+			if len(list.Errors) != tt.want || list.Limited != tt.limited {
+				t.Fatalf("got %d errors (limited %v), want %d (limited %v)", len(list.Errors), list.Limited, tt.want, tt.limited)
+			}
+		})
+	}
+
+	_, err := asm.ParseWithOptions(strings.NewReader(src), asm.ParseOptions{MaxErrors: 1})
+	list, ok := err.(*asm.ErrorList)
+	if !ok || len(list.Errors) != 1 || !list.Limited {
+		t.Fatalf("expected one error marked limited with MaxErrors 1, got %T: %v", err, err)
+	}
+}
+
+func TestAssembleCollectsErrors(t *testing.T) {
+	src := "BRA missing\nNOP\nMOVE.L (A0)+,#1\n"
+
+	prog, err := asm.Parse(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	_, err = asm.Assemble(prog)
+	var list *asm.ErrorList
+	if !errors.As(err, &list) || len(list.Errors) != 2 {
+		t.Fatalf("expected two errors, got %v", err)
+	}
+	for i, want := range []string{"undefined label: missing", "MOVE destination cannot be immediate"} {
+		if !strings.Contains(list.Errors[i].Error(), want) {
+			t.Fatalf("error %d = %q, want %q", i, list.Errors[i], want)
+		}
+	}
+}
+
+func TestErrorCodes(t *testing.T) {
+	tests := []struct {
+		src  string
+		opts asm.ParseOptions
+		want asm.Code
+	}{
+		{src: "@@@\n", want: asm.CodeSyntax},
+		{src: "FOOBAR D0\n", want: asm.CodeUnknownMnemonic},
+		{src: ".bogus 1\n", want: asm.CodeUnknownDirective},
+		{src: "MOVEQ.W #1,D0\n", want: asm.CodeInvalidSize},
+		{src: "MOVE.W #1,(D0)\n", want: asm.CodeInvalidOperand},
+		{src: "ADDQ #9,D0\n", want: asm.CodeOutOfRange},
+		{src: "DC.B 300\n", want: asm.CodeOutOfRange},
+		{src: "BRA.S far\nDCB.B 300,0\nfar: NOP\n", want: asm.CodeOutOfRange},
+		{src: "MOVE.W missing,D0\n", want: asm.CodeUndefinedSymbol},
+		{src: "BRA missing\n", want: asm.CodeUndefinedSymbol},
+		{src: "x EQU 1\nx EQU 2\n", want: asm.CodeRedefinedSymbol},
+		{src: "DC.W 1/0\n", want: asm.CodeInvalidExpression},
+		{src: "ALIGN 0\n", want: asm.CodeInvalidDirective},
+		{src: "IF 1\nNOP\n", want: asm.CodeUnbalancedBlock},
+		{src: "m MACRO a\nENDM\nm 1,2\n", want: asm.CodeMacro},
+		{src: ".bss\nNOP\n", want: asm.CodeSection},
+		{src: "ORG 10\nORG 4\n", want: asm.CodeLayout},
+		{src: "INCBIN \"missing.bin\"\n", want: asm.CodeFile},
+		{src: "INFORM 2,\"stop\"\n", want: asm.CodeUser},
+		{src: "MOVE.W $1001,D0\n", opts: asm.ParseOptions{WarningsAsErrors: true}, want: asm.Code(asm.WarnOddAddress)},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.want), func(t *testing.T) {
+			prog, err := asm.ParseWithOptions(strings.NewReader(tt.src), tt.opts)
+			if err == nil {
+				_, err = asm.Assemble(prog)
+			}
+			var asmErr *asm.Error
+			if !errors.As(err, &asmErr) {
+				t.Fatalf("expected *asm.Error for %q, got %v", tt.src, err)
+			}
+			if asmErr.Code != tt.want || asm.ErrorCode(err) != tt.want {
+				t.Fatalf("code of %q = %q, want %q (%v)", tt.src, asmErr.Code, tt.want, err)
+			}
+		})
+	}
+}
+
+func TestErrorColumns(t *testing.T) {
+	tests := []struct {
+		src         string
+		col, endCol int
+	}{
+		{src: "  FOOBAR D0\n", col: 3, endCol: 9},
+		{src: "MOVE.W #1,(D0)\n", col: 12, endCol: 14},
+		{src: " DC.B 1,300\n", col: 9, endCol: 12},
+	}
+	for _, tt := range tests {
+		_, err := asm.Parse(strings.NewReader(tt.src))
+		var asmErr *asm.Error
+		if !errors.As(err, &asmErr) {
+			t.Fatalf("expected *asm.Error for %q, got %v", tt.src, err)
+		}
+		if asmErr.Col != tt.col || asmErr.EndCol != tt.endCol {
+			t.Fatalf("columns of %q = %d-%d, want %d-%d", tt.src, asmErr.Col, asmErr.EndCol, tt.col, tt.endCol)
+		}
+	}
+}
+
+func TestErrorHints(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"Mnemonic", " MOVX.L D0,D1\n", "did you mean MOVE.L?"},
+		{"LowercaseMnemonic", " moev d0,d1\n", "did you mean move?"},
+		{"Macro", "clear MACRO\nENDM\n clera\n", "did you mean clear?"},
+		{"Directive", " .evn\n", "did you mean .even?"},
+		{"Label", "start: NOP\n BRA strat\n", "did you mean start?"},
+		{"ExpressionLabel", "count EQU 3\n MOVE.W #cuont,D0\n", "did you mean count?"},
+		{"NoCloseName", " XYZZY D0\n", ""},
+		{"Size", " MOVEQ.W #1,D0\n", "MOVEQ takes .L only"},
+		{"Sizes", " CLR.X D0\n", "CLR takes .B, .W or .L"},
+		{"QuickRange", " ADDQ #9,D0\n", "ADDQ takes 1 to 8; use ADDI for other values"},
+		{"MoveqRange", " MOVEQ #200,D0\n", "MOVEQ takes -128 to 127; use MOVE.L for other values"},
+		{"ShiftRange", " LSL.W #9,D0\n", "shift counts are 1 to 8; put larger counts in a data register"},
+		{"MissingHash", " MOVEQ 5,D0\n", "did you mean MOVEQ #5,D0?"},
+		{"MissingHashSecond", " LINK A6,-8\n", "did you mean LINK A6,#-8?"},
+		{"Postincrement", " ADDX.W (A0)+,(A1)+\n", "did you mean ADDX.W -(A0),-(A1)?"},
+		{"OnePostincrement", " ABCD (A0)+,-(A1)\n", "did you mean ABCD -(A0),-(A1)?"},
+		{"Predecrement", " CMPM.B -(A0),-(A1)\n", "did you mean CMPM.B (A0)+,(A1)+?"},
+		{"LowercaseSize", " moveq.w #1,d0\n", "moveq takes .l only"},
+		{"LowercaseRange", " addq #9,d0\n", "addq takes 1 to 8; use addi for other values"},
+		{"LowercasePostincrement", " abcd (a0)+,(a1)+\n", "did you mean abcd -(a0),-(a1)?"},
+		{"LowercasePredecrement", " cmpm.w -(a0),-(a1)\n", "did you mean cmpm.w (a0)+,(a1)+?"},
+		{"UppercaseDirective", " .EVN\n", "did you mean .EVEN?"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prog, err := asm.Parse(strings.NewReader(tt.src))
+			if err == nil {
+				_, err = asm.Assemble(prog)
+			}
+			var asmErr *asm.Error
+			if !errors.As(err, &asmErr) {
+				t.Fatalf("expected *asm.Error, got %v", err)
+			}
+			if asmErr.Hint != tt.want {
+				t.Fatalf("hint = %q, want %q", asmErr.Hint, tt.want)
+			}
+			if tt.want != "" && !strings.Contains(err.Error(), "\n  hint: "+tt.want) {
+				t.Fatalf("hint missing from error text:\n%s", err)
+			}
+		})
+	}
+}
@@ -1,17 +1,184 @@
 package asm
 
 import (
+	"errors"
 	"fmt"
 	"strings"
+
+	"github.com/jenska/m68kasm/internal/asm/instructions"
 )
 
 // Error wraps an underlying failure with source location information so that
 // callers can surface detailed diagnostics.
+// Errors raised in a macro expansion point into the macro body and list the
+// invocations that led there in Expansions, innermost first.
+// Code classifies the error; see the Code constants.
+// EndCol, if set, is the column just past the text the error is about.
+// Hint, if set, suggests a fix, such as the name of a mnemonic or label
+// close to a misspelt one.
 type Error struct {
-	Line     int
-	Col      int
-	LineText string
-	Err      error
+	Line       int
+	Col        int
+	EndCol     int
+	LineText   string
+	Code       Code
+	Hint       string
+	Err        error
+	Expansions []MacroFrame
+}
+
+// Code names a class of errors. Codes are stable, so tools can match on
+// them while messages are reworded; docs/syntax.md lists them. Warnings use
+// their Category as code.
+type Code string
+
+const (
+	// CodeSyntax is a statement or token the parser cannot read.
+	CodeSyntax Code = "syntax"
+	// CodeUnknownMnemonic is a statement that names no instruction, macro
+	// or directive.
+	CodeUnknownMnemonic Code = "unknown-mnemonic"
+	// CodeUnknownDirective is a dot directive that does not exist.
+	CodeUnknownDirective Code = "unknown-directive"
+	// CodeInvalidSize is a size suffix the instruction or directive does
+	// not take.
+	CodeInvalidSize Code = "invalid-size"
+	// CodeInvalidOperand is an operand or addressing mode the instruction
+	// does not take.
+	CodeInvalidOperand Code = "invalid-operand"
+	// CodeOutOfRange is a value that does not fit its field.
+	CodeOutOfRange Code = "out-of-range"
+	// CodeUndefinedSymbol is a reference to a symbol or local label that is
+	// never defined.
+	CodeUndefinedSymbol Code = "undefined-symbol"
+	// CodeRedefinedSymbol is a symbol or register alias defined twice.
+	CodeRedefinedSymbol Code = "redefined-symbol"
+	// CodeInvalidExpression is an expression that cannot be evaluated.
+	CodeInvalidExpression Code = "invalid-expression"
+	// CodeInvalidDirective is a directive with arguments it does not take.
+	CodeInvalidDirective Code = "invalid-directive"
+	// CodeUnbalancedBlock is a block directive (IF, MACRO, OBJ, .struct,
+	// .namespace) without its end, or an end without its start.
+	CodeUnbalancedBlock Code = "unbalanced-block"
+	// CodeMacro is a macro invocation that cannot be expanded.
+	CodeMacro Code = "macro"
+	// CodeSection is a statement in a section that does not allow it, or
+	// sections in the wrong order.
+	CodeSection Code = "section"
+	// CodeLayout is an address layout that cannot be built: ORG moving
+	// backwards, a program that grows too large or addresses that do not
+	// settle.
+	CodeLayout Code = "layout"
+	// CodeFile is a file that cannot be read.
+	CodeFile Code = "file"
+	// CodeUser is an error raised by the source itself, as INFORM does.
+	CodeUser Code = "user"
+	// CodeInternal is a failure of the assembler itself.
+	CodeInternal Code = "internal"
+)
+
+// Codes lists every error code.
+var Codes = []Code{
+	CodeSyntax, CodeUnknownMnemonic, CodeUnknownDirective, CodeInvalidSize,
+	CodeInvalidOperand, CodeOutOfRange, CodeUndefinedSymbol, CodeRedefinedSymbol,
+	CodeInvalidExpression, CodeInvalidDirective, CodeUnbalancedBlock, CodeMacro,
+	CodeSection, CodeLayout, CodeFile, CodeUser, CodeInternal,
+}
+
+// ErrorCode returns the code of err, the first error of an *ErrorList.
+// Errors that carry no code are CodeInternal.
+func ErrorCode(err error) Code {
+	if code := codeOf(err); code != "" {
+		return code
+	}
+	return CodeInternal
+}
+
+// codeError gives an error that has no position yet its code; the *Error
+// that later locates it takes the code over.
+type codeError struct {
+	code Code
+	err  error
+}
+
+func (e *codeError) Error() string { return e.err.Error() }
+func (e *codeError) Unwrap() error { return e.err }
+
+// withCode classifies err as code, unless it was given a more specific code
+// where it was raised.
+func withCode(code Code, err error) error {
+	if err == nil || codeOf(err) != "" {
+		return err
+	}
+	var e *Error
+	if errors.As(err, &e) {
+		e.Code = code
+		return err
+	}
+	return &codeError{code: code, err: err}
+}
+
+func codeOf(err error) Code {
+	var e *Error
+	if errors.As(err, &e) && e.Code != "" {
+		return e.Code
+	}
+	var c *codeError
+	if errors.As(err, &c) {
+		return c.code
+	}
+	if errors.Is(err, instructions.ErrOutOfRange) {
+		return CodeOutOfRange
+	}
+	return ""
+}
+
+// hintError gives an error that has no position yet its hint, in the way
+// codeError gives it its code.
+type hintError struct {
+	hint string
+	err  error
+}
+
+func (e *hintError) Error() string { return e.err.Error() }
+func (e *hintError) Unwrap() error { return e.err }
+
+// withHint adds hint to err unless it has one already. An empty hint leaves
+// err alone.
+func withHint(err error, hint string) error {
+	if err == nil || hint == "" || hintOf(err) != "" {
+		return err
+	}
+	var e *Error
+	if errors.As(err, &e) {
+		e.Hint = hint
+		return err
+	}
+	return &hintError{hint: hint, err: err}
+}
+
+func hintOf(err error) string {
+	var e *Error
+	if errors.As(err, &e) && e.Hint != "" {
+		return e.Hint
+	}
+	var h *hintError
+	if errors.As(err, &h) {
+		return h.hint
+	}
+	return ""
+}
+
+// MacroFrame is one step of a macro expansion backtrace: the macro that was
+// invoked and the position of the invocation.
+type MacroFrame struct {
+	Macro string
+	Line  int
+	Col   int
+}
+
+func (f MacroFrame) String() string {
+	return fmt.Sprintf("in expansion of macro %s at line %d", f.Macro, f.Line)
 }
 
 func (e *Error) Message() string {
@@ -26,24 +193,88 @@ func (e *Error) Error() string {
 	if e.Col > 0 {
 		loc += fmt.Sprintf(", col %d", e.Col)
 	}
+	msg := fmt.Sprintf("%s: %s", loc, e.Message())
 	if e.LineText != "" {
-		msg := fmt.Sprintf("%s: %s\n    %s", loc, e.Message(), e.LineText)
+		msg += fmt.Sprintf("\n    %s", e.LineText)
 		if e.Col > 0 {
 			msg += fmt.Sprintf("\n    %s^", strings.Repeat(" ", e.Col-1))
 		}
-		return msg
 	}
-	return fmt.Sprintf("%s: %s", loc, e.Message())
+	if e.Hint != "" {
+		msg += "\n  hint: " + e.Hint
+	}
+	for _, f := range e.Expansions {
+		msg += "\n  " + f.String()
+	}
+	return msg
 }
 
 func (e *Error) Unwrap() error { return e.Err }
 
+// DefaultMaxErrors is the number of errors reported when
+// ParseOptions.MaxErrors is 0.
+const DefaultMaxErrors = 20
+
+// ErrorList is returned when a run finds more than one error, even if
+// ParseOptions.MaxErrors leaves only one of them in. It holds the
+// errors in the order they were found, most of them *Error; errors.As
+// reaches each one through Unwrap.
+type ErrorList struct {
+	Errors []error
+	// Limited is set when there were more errors than ParseOptions.MaxErrors
+	// allows; the rest are left out.
+	Limited bool
+}
+
+func (l *ErrorList) Error() string {
+	msgs := make([]string, len(l.Errors))
+	for i, err := range l.Errors {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+func (l *ErrorList) Unwrap() []error { return l.Errors }
+
+// errorLimit returns the number of errors to report for opts; 0 means no
+// limit.
+func errorLimit(opts ParseOptions) int {
+	switch {
+	case opts.MaxErrors == 0:
+		return DefaultMaxErrors
+	case opts.MaxErrors < 0:
+		return 0
+	}
+	return opts.MaxErrors
+}
+
+// joinErrors returns nil for no errors, a single error as it is, and more
+// as an *ErrorList of at most limit errors, marked Limited if some were left
+// out.
+func joinErrors(errs []error, limit int) error {
+	switch {
+	case len(errs) == 0:
+		return nil
+	case len(errs) == 1:
+		return errs[0]
+	}
+	list := &ErrorList{Errors: errs}
+	if limit > 0 && len(errs) > limit {
+		list.Errors, list.Limited = errs[:limit], true
+	}
+	return list
+}
+
 func errorAtToken(t Token, err error) error {
-	return &Error{Line: t.Line, Col: t.Col, Err: err}
+	e := &Error{Line: t.Line, Col: t.firstCol(), Code: codeOf(err), Hint: hintOf(err), Err: err}
+	if e.Col > 0 {
+		e.EndCol = t.Col + 1
+	}
+	return t.origin.locate(e)
 }
 
 func errorAtLine(line int, err error) error {
-	return &Error{Line: line, Err: err}
+	return &Error{Line: line, Code: codeOf(err), Hint: hintOf(err), Err: err}
 }
 
 func contextualize(line int, err error) error {
@@ -57,29 +288,87 @@ func contextualizeAt(line, col int, err error) error {
 	if _, ok := err.(*Error); ok {
 		return err
 	}
-	return &Error{Line: line, Col: col, Err: err}
+	return &Error{Line: line, Col: col, Code: codeOf(err), Hint: hintOf(err), Err: err}
 }
 
+// withSourceLines completes the errors of a run for reporting: it adds their
+// source lines and end columns, and classifies the ones raised without a
+// code as CodeSyntax.
 func withSourceLines(err error, lines []string) error {
-	if err == nil || len(lines) == 0 {
+	if err == nil {
 		return err
 	}
+	if list, ok := err.(*ErrorList); ok {
+		for i := range list.Errors {
+			list.Errors[i] = withSourceLines(list.Errors[i], lines)
+		}
+		return list
+	}
 	e, ok := err.(*Error)
 	if !ok {
 		return err
 	}
-	if e.LineText != "" {
-		return e
+	if e.Code == "" {
+		e.Code = CodeSyntax
+	}
+	if e.LineText == "" {
+		e.LineText = sourceLine(e.Line, lines)
 	}
-	if line := sourceLine(e.Line, lines); line != "" {
-		e.LineText = line
+	if e.Col > 0 && e.EndCol == 0 {
+		e.EndCol = wordEnd(e.LineText, e.Col)
 	}
 	return e
 }
 
+// wordEnd returns the column just past the word that starts at col in text:
+// the run of characters up to a blank, comma or comment.
+func wordEnd(text string, col int) int {
+	i := col - 1
+	if i >= len(text) {
+		return col + 1
+	}
+	end := i + 1
+	for end < len(text) && !strings.ContainsRune(" \t,;", rune(text[end])) {
+		end++
+	}
+	return end + 1
+}
+
 func sourceLine(line int, lines []string) string {
 	if line <= 0 || line > len(lines) {
 		return ""
 	}
 	return lines[line-1]
 }
+
+// macroOrigin locates a token produced by macro expansion: its position in
+// the macro body and the invocation that expanded it.
+type macroOrigin struct {
+	line, col int
+	call      *macroCallSite
+}
+
+// macroCallSite is a macro invocation; parent is the expansion the
+// invocation itself was part of.
+type macroCallSite struct {
+	MacroFrame
+	parent *macroCallSite
+}
+
+// locate moves err from the invocation to the macro body and records the
+// expansion backtrace. Errors that already carry one are left alone.
+func (o *macroOrigin) locate(err error) error {
+	e, ok := err.(*Error)
+	if o == nil || !ok || e.Expansions != nil {
+		return err
+	}
+	e.Line = o.line
+	if e.Col > 0 {
+		e.Col = o.col
+		e.EndCol = 0
+	}
+	for c := o.call; c != nil; c = c.parent {
+		e.Expansions = append(e.Expansions, c.MacroFrame)
+	}
+	return e
+}
@@ -8,49 +8,56 @@ import (
 type exprInfo struct {
 	Value     int64
 	HasSymbol bool
+	// Expr is the expression tree, evaluated again when assembling.
+	Expr *Expr
+	// start is the first token of the expression, where errors about its
+	// value point.
+	start Token
 }
 
-func (p *Parser) parseExpr() (int64, error) {
-	info, err := p.parseExprInfoUntil(COMMA, NEWLINE, EOF)
-	if err != nil {
-		return 0, err
-	}
-	return info.Value, nil
+func (p *Parser) parseExprInfo() (exprInfo, error) {
+	return p.parseExprInfoUntil(COMMA, NEWLINE, EOF)
 }
 
-func (p *Parser) parseExprUntil(stops ...Kind) (int64, error) {
-	info, err := p.parseExprInfoUntil(stops...)
-	if err != nil {
-		return 0, err
-	}
-	return info.Value, nil
+// parseExprInfoUntil parses an expression up to one of stops. Its errors
+// are CodeInvalidExpression unless they have a code of their own.
+func (p *Parser) parseExprInfoUntil(stops ...Kind) (exprInfo, error) {
+	start := p.peek()
+	info, err := p.parseExprTokens(newKindSet(stops...))
+	info.start = start
+	return info, withCode(CodeInvalidExpression, err)
 }
 
-func (p *Parser) parseExprInfoUntil(stops ...Kind) (exprInfo, error) {
-	stop := newKindSet(stops...)
+func (p *Parser) parseExprTokens(stop kindSet) (exprInfo, error) {
 
-	out := []int64{}
+	out := []*Expr{}
 	ops := []Kind{}
 	wantValue := true
 	hasSymbol := false
 
 	apply := func(op Kind) error {
-		if isUnaryOperator(op) && (op == TILDE || op == BANG) {
+		switch op {
+		case TILDE, BANG:
 			return applyUnaryOperator(op, &out)
+		case QUESTION:
+			return fmt.Errorf("expected ':' after '?'")
+		case COLON:
+			return applyConditional(&out)
 		}
 		return applyBinaryOperator(op, &out)
 	}
 
 	pushOp := func(k Kind) error {
 		if wantValue && (k == PLUS || k == MINUS) {
-			out = append(out, 0)
+			out = append(out, &Expr{op: exprNumber, implicit: true})
 		}
 		for len(ops) > 0 {
 			top := ops[len(ops)-1]
 			if top == LPAREN {
 				break
 			}
-			if precedence(top) >= precedence(k) {
+			// The conditional operator groups right to left.
+			if precedence(top) > precedence(k) || (precedence(top) == precedence(k) && k != QUESTION) {
 				ops = ops[:len(ops)-1]
 				if err := apply(top); err != nil {
 					return err
@@ -71,32 +78,57 @@ loop:
 		}
 		switch t.Kind {
 		case NUMBER:
+			refText := t.Text + p.peekN(2).Text
 			if name, ok, err := p.consumeLocalLabelRef(); ok {
 				if err != nil {
 					return exprInfo{}, err
 				}
 				hasSymbol = true
-				if v, ok := p.labels[name]; ok {
-					out = append(out, int64(v))
-					wantValue = false
-					continue
-				}
-				if p.allowForwardRefs {
-					out = append(out, 0)
-					wantValue = false
-					continue
-				}
-				return exprInfo{}, fmt.Errorf("undefined label in expression: %s", name)
+				out = append(out, p.symbolExpr(refText, name, int64(p.labels[name])))
+				wantValue = false
+				continue
 			}
 			p.next()
-			out = append(out, t.Val)
+			out = append(out, numberExpr(t.Text, t.Val))
 			wantValue = false
 		case DOLLAR:
 			p.next()
 			hasSymbol = true
-			out = append(out, int64(p.pc))
+			out = append(out, &Expr{op: exprPC, text: "$", value: int64(p.pc), section: p.section})
+			wantValue = false
+		case STAR:
+			if !wantValue {
+				if err := pushOp(STAR); err != nil {
+					return exprInfo{}, err
+				}
+				p.next()
+				wantValue = true
+				continue
+			}
+			// '*' in operand position is the current location counter.
+			p.next()
+			hasSymbol = true
+			out = append(out, &Expr{op: exprPC, text: "*", value: int64(p.pc), section: p.section})
+			wantValue = false
+		case STRING:
+			v, err := packString(t.Text)
+			if err != nil {
+				return exprInfo{}, err
+			}
+			p.next()
+			out = append(out, &Expr{op: exprString, text: t.Text, value: v})
 			wantValue = false
 		case IDENT:
+			if fn, ok := exprFunctions[strings.ToUpper(t.Text)]; ok && p.peekN(2).Kind == LPAREN {
+				v, err := p.parseFunctionCall(t, fn)
+				if err != nil {
+					return exprInfo{}, err
+				}
+				hasSymbol = hasSymbol || v.HasSymbol
+				out = append(out, v.Expr)
+				wantValue = false
+				continue
+			}
 			p.next()
 			text := t.Text
 			if p.peek().Kind == DOT {
@@ -105,7 +137,7 @@ loop:
 					sizeTok := p.next()
 					size := strings.ToLower(sizeTok.Text)
 					if size != "w" && size != "l" {
-						return exprInfo{}, fmt.Errorf("invalid size suffix: %s", size)
+						return exprInfo{}, withCode(CodeInvalidSize, fmt.Errorf("invalid size suffix: %s", size))
 					}
 					// For PC-relative addressing, .w/.l is allowed but ignored
 				} else {
@@ -113,14 +145,16 @@ loop:
 				}
 			}
 			hasSymbol = true
-			if v, ok := p.labels[text]; ok {
-				out = append(out, int64(v))
-				wantValue = false
-			} else if p.allowForwardRefs {
-				out = append(out, 0)
+			name := p.resolveSymbol(text)
+			if strings.EqualFold(name, "__rs") {
+				// ASM68K exposes the RS counter as __rs.
+				out = append(out, &Expr{op: exprSymbol, text: text, name: name, value: p.rsCounter})
 				wantValue = false
 			} else {
-				return exprInfo{}, fmt.Errorf("undefined label in expression: %s", text)
+				// A symbol not known yet is 0 until layout evaluates the
+				// expression again.
+				out = append(out, p.symbolExpr(text, name, int64(p.labels[name])))
+				wantValue = false
 			}
 		case LPAREN:
 			p.next()
@@ -143,7 +177,29 @@ loop:
 			}
 			ops = ops[:len(ops)-1]
 			wantValue = false
-		case PLUS, MINUS, STAR, SLASH, PERCENT, LSHIFT, RSHIFT, AMP, PIPE, CARET, TILDE, BANG, LT, GT, LTE, GTE, EQEQ, NEQ, ANDAND, OROR:
+		case QUESTION:
+			p.next()
+			if err := pushOp(QUESTION); err != nil {
+				return exprInfo{}, err
+			}
+			wantValue = true
+		case COLON:
+			if stop.has(COLON) || !openConditional(ops) {
+				break loop
+			}
+			p.next()
+			// Finish the "then" operand; its '?' becomes the ':' that applies
+			// the whole conditional.
+			for ops[len(ops)-1] != QUESTION {
+				op := ops[len(ops)-1]
+				ops = ops[:len(ops)-1]
+				if err := apply(op); err != nil {
+					return exprInfo{}, err
+				}
+			}
+			ops[len(ops)-1] = COLON
+			wantValue = true
+		case PLUS, MINUS, SLASH, PERCENT, LSHIFT, RSHIFT, AMP, PIPE, CARET, TILDE, BANG, LT, GT, LTE, GTE, EQEQ, NEQ, ANDAND, OROR:
 			p.next()
 			if isUnaryOperator(t.Kind) && wantValue && t.Kind != TILDE && t.Kind != BANG {
 				if err := pushOp(t.Kind); err != nil {
@@ -174,7 +230,19 @@ loop:
 	if len(out) != 1 {
 		return exprInfo{}, fmt.Errorf("invalid expression")
 	}
-	return exprInfo{Value: out[0], HasSymbol: hasSymbol}, nil
+	return exprInfo{Value: out[0].value, HasSymbol: hasSymbol, Expr: out[0]}, nil
+}
+
+// symbolExpr builds the node for a reference to name. Whether the symbol is
+// a label comes from its definition in this pass or, for forward
+// references, the previous one.
+func (p *Parser) symbolExpr(text, name string, v int64) *Expr {
+	e := &Expr{op: exprSymbol, text: text, name: name, value: v, label: true, section: p.section}
+	if label, ok := p.lookupDefinedLabel(name); ok {
+		e.label = label.Kind == SymbolLabel
+		e.section = label.Section
+	}
+	return e
 }
 
 type kindSet map[Kind]struct{}
@@ -216,88 +284,114 @@ func precedence(k Kind) int {
 		return -1
 	case OROR:
 		return -2
-	default:
+	case QUESTION, COLON:
 		return -3
+	default:
+		return -4
 	}
 }
 
+// openConditional reports whether ops holds a '?' that is still waiting for
+// its ':' inside the innermost parentheses.
+func openConditional(ops []Kind) bool {
+	for i := len(ops) - 1; i >= 0 && ops[i] != LPAREN; i-- {
+		if ops[i] == QUESTION {
+			return true
+		}
+	}
+	return false
+}
+
+// applyConditional builds cond ? a : b from the top three values.
+func applyConditional(out *[]*Expr) error {
+	if len(*out) < 3 {
+		return fmt.Errorf("conditional operator expects three arguments")
+	}
+	n := len(*out)
+	cond, a, b := (*out)[n-3], (*out)[n-2], (*out)[n-1]
+	*out = append((*out)[:n-3], newConditionalExpr(cond, a, b))
+	return nil
+}
+
 func isUnaryOperator(k Kind) bool {
 	return k == MINUS || k == PLUS || k == TILDE || k == BANG
 }
 
-func applyUnaryOperator(op Kind, out *[]int64) error {
+func applyUnaryOperator(op Kind, out *[]*Expr) error {
 	if len(*out) < 1 {
 		return fmt.Errorf("unary operator expects one argument")
 	}
 	a := (*out)[len(*out)-1]
-	*out = (*out)[:len(*out)-1]
-
-	switch op {
-	case TILDE:
-		*out = append(*out, ^a)
-	case BANG:
-		*out = append(*out, boolToInt64(a == 0))
-	default:
-		return fmt.Errorf("unknown operator")
-	}
+	(*out)[len(*out)-1] = newUnaryExpr(op, a)
 	return nil
 }
 
-func applyBinaryOperator(op Kind, out *[]int64) error {
+func applyBinaryOperator(op Kind, out *[]*Expr) error {
 	if len(*out) < 2 {
 		return fmt.Errorf("binary operator expects two arguments")
 	}
 	b := (*out)[len(*out)-1]
 	a := (*out)[len(*out)-2]
-	*out = (*out)[:len(*out)-2]
+	*out = append((*out)[:len(*out)-2], newBinaryExpr(op, a, b))
+	return nil
+}
+
+func evalUnary(op Kind, a int64) (int64, error) {
+	switch op {
+	case TILDE:
+		return ^a, nil
+	case BANG:
+		return boolToInt64(a == 0), nil
+	}
+	return 0, fmt.Errorf("unknown operator")
+}
 
+func evalBinary(op Kind, a, b int64) (int64, error) {
 	switch op {
 	case PLUS:
-		*out = append(*out, a+b)
+		return a + b, nil
 	case MINUS:
-		*out = append(*out, a-b)
+		return a - b, nil
 	case STAR:
-		*out = append(*out, a*b)
+		return a * b, nil
 	case SLASH:
 		if b == 0 {
-			return fmt.Errorf("division by zero")
+			return 0, fmt.Errorf("division by zero")
 		}
-		*out = append(*out, a/b)
+		return a / b, nil
 	case PERCENT:
 		if b == 0 {
-			return fmt.Errorf("division by zero")
+			return 0, fmt.Errorf("division by zero")
 		}
-		*out = append(*out, a%b)
+		return a % b, nil
 	case LSHIFT:
-		*out = append(*out, a<<uint64(b))
+		return a << uint64(b), nil
 	case RSHIFT:
-		*out = append(*out, a>>uint64(b))
+		return a >> uint64(b), nil
 	case LT:
-		*out = append(*out, boolToInt64(a < b))
+		return boolToInt64(a < b), nil
 	case GT:
-		*out = append(*out, boolToInt64(a > b))
+		return boolToInt64(a > b), nil
 	case LTE:
-		*out = append(*out, boolToInt64(a <= b))
+		return boolToInt64(a <= b), nil
 	case GTE:
-		*out = append(*out, boolToInt64(a >= b))
+		return boolToInt64(a >= b), nil
 	case EQEQ:
-		*out = append(*out, boolToInt64(a == b))
+		return boolToInt64(a == b), nil
 	case NEQ:
-		*out = append(*out, boolToInt64(a != b))
+		return boolToInt64(a != b), nil
 	case AMP:
-		*out = append(*out, a&b)
+		return a & b, nil
 	case CARET:
-		*out = append(*out, a^b)
+		return a ^ b, nil
 	case PIPE:
-		*out = append(*out, a|b)
+		return a | b, nil
 	case ANDAND:
-		*out = append(*out, truthy(a)&truthy(b))
+		return truthy(a) & truthy(b), nil
 	case OROR:
-		*out = append(*out, truthy(a)|truthy(b))
-	default:
-		return fmt.Errorf("unknown operator")
+		return truthy(a) | truthy(b), nil
 	}
-	return nil
+	return 0, fmt.Errorf("unknown operator")
 }
 
 func truthy(v int64) int64 {
@@ -313,3 +407,323 @@ func boolToInt64(v bool) int64 {
 	}
 	return 0
 }
+
+// exprArg is a function argument: a string literal, a symbol or section
+// name, or a numeric expression.
+type exprArg struct {
+	str   string
+	isStr bool
+	val   int64
+}
+
+type exprFunction struct {
+	// params has one letter per parameter: 's' string, 'n' number, 'i'
+	// symbol or section name.
+	params string
+	eval   func(p *Parser, args []exprArg) (int64, error)
+	// address is set for functions that return an address rather than a
+	// plain number.
+	address bool
+}
+
+// exprFunctions are the functions callable as NAME(args) in expressions.
+var exprFunctions = map[string]exprFunction{
+	"STRLEN": {params: "s", eval: func(_ *Parser, a []exprArg) (int64, error) {
+		return int64(len(a[0].str)), nil
+	}},
+	// SUBSTR(s, start, length) with a 1-based start, as a packed value.
+	"SUBSTR": {params: "snn", eval: func(_ *Parser, a []exprArg) (int64, error) {
+		s, start, n := a[0].str, a[1].val, a[2].val
+		if start < 1 || n < 0 || start-1+n > int64(len(s)) {
+			return 0, fmt.Errorf("SUBSTR range %d,%d outside %q", start, n, s)
+		}
+		if n == 0 {
+			return 0, nil
+		}
+		return packString(s[start-1 : start-1+n])
+	}},
+	// INSTR(s, sub) is the 1-based position of sub in s, or 0.
+	"INSTR": {params: "ss", eval: func(_ *Parser, a []exprArg) (int64, error) {
+		return int64(strings.Index(a[0].str, a[1].str) + 1), nil
+	}},
+
+	// LOW and HIGH are the low and high byte of a word, UPPER the upper word
+	// of a long.
+	"LOW":   {params: "n", eval: func(_ *Parser, a []exprArg) (int64, error) { return a[0].val & 0xFF, nil }},
+	"HIGH":  {params: "n", eval: func(_ *Parser, a []exprArg) (int64, error) { return a[0].val >> 8 & 0xFF, nil }},
+	"UPPER": {params: "n", eval: func(_ *Parser, a []exprArg) (int64, error) { return a[0].val >> 16 & 0xFFFF, nil }},
+	"ABS": {params: "n", eval: func(_ *Parser, a []exprArg) (int64, error) {
+		if a[0].val < 0 {
+			return -a[0].val, nil
+		}
+		return a[0].val, nil
+	}},
+	"MIN": {params: "nn", eval: func(_ *Parser, a []exprArg) (int64, error) { return min(a[0].val, a[1].val), nil }},
+	"MAX": {params: "nn", eval: func(_ *Parser, a []exprArg) (int64, error) { return max(a[0].val, a[1].val), nil }},
+	// ALIGN(x, n) rounds x up to a multiple of n.
+	"ALIGN": {params: "nn", eval: func(_ *Parser, a []exprArg) (int64, error) {
+		x, n := a[0].val, a[1].val
+		if n < 1 {
+			return 0, fmt.Errorf("ALIGN expects alignment >= 1, got %d", n)
+		}
+		if r := x % n; r != 0 {
+			x += n - r
+			if r < 0 {
+				x -= n
+			}
+		}
+		return x, nil
+	}},
+
+	// The U variants compare 32-bit values as unsigned, the S variants as
+	// signed; the plain operators compare the full 64-bit values.
+	"ULT": unsignedCompare(func(a, b uint32) bool { return a < b }),
+	"ULE": unsignedCompare(func(a, b uint32) bool { return a <= b }),
+	"UGT": unsignedCompare(func(a, b uint32) bool { return a > b }),
+	"UGE": unsignedCompare(func(a, b uint32) bool { return a >= b }),
+	"SLT": signedCompare(func(a, b int32) bool { return a < b }),
+	"SLE": signedCompare(func(a, b int32) bool { return a <= b }),
+	"SGT": signedCompare(func(a, b int32) bool { return a > b }),
+	"SGE": signedCompare(func(a, b int32) bool { return a >= b }),
+
+	// DEF(sym) is 1 if sym has been defined earlier in the source, like IFD.
+	"DEF":     {params: "i", eval: evalDefined},
+	"DEFINED": {params: "i", eval: evalDefined},
+	"SIZEOF": {params: "i", eval: func(p *Parser, a []exprArg) (int64, error) {
+		return p.sizeOf(a[0].str)
+	}},
+	"SECTION": {params: "i", eval: func(p *Parser, a []exprArg) (int64, error) {
+		return p.symbolSection(a[0].str)
+	}},
+	"SECTSTART": {params: "i", address: true, eval: func(p *Parser, a []exprArg) (int64, error) {
+		return p.sectionBound(a[0].str, false)
+	}},
+	"SECTEND": {params: "i", address: true, eval: func(p *Parser, a []exprArg) (int64, error) {
+		return p.sectionBound(a[0].str, true)
+	}},
+}
+
+func unsignedCompare(cmp func(a, b uint32) bool) exprFunction {
+	return exprFunction{params: "nn", eval: func(_ *Parser, a []exprArg) (int64, error) {
+		return boolToInt64(cmp(uint32(a[0].val), uint32(a[1].val))), nil
+	}}
+}
+
+func signedCompare(cmp func(a, b int32) bool) exprFunction {
+	return exprFunction{params: "nn", eval: func(_ *Parser, a []exprArg) (int64, error) {
+		return boolToInt64(cmp(int32(a[0].val), int32(a[1].val))), nil
+	}}
+}
+
+func evalDefined(p *Parser, a []exprArg) (int64, error) {
+	return boolToInt64(p.defined[p.resolveSymbol(a[0].str)]), nil
+}
+
+// symbolSection returns the section number of a label (0 .text, 1 .data,
+// 2 .bss), or -1 for constants and variables.
+func (p *Parser) symbolSection(name string) (int64, error) {
+	name = p.resolveSymbol(name)
+	label, ok := p.lookupDefinedLabel(name)
+	switch {
+	case !ok && p.allowForwardRefs:
+		return 0, nil
+	case !ok:
+		return 0, withCode(CodeUndefinedSymbol, fmt.Errorf("SECTION: undefined symbol %s", name))
+	case label.Kind != SymbolLabel:
+		return -1, nil
+	}
+	return int64(label.Section), nil
+}
+
+// lookupDefinedLabel finds the definition of name in this pass or, for
+// symbols defined further down, in the previous one.
+func (p *Parser) lookupDefinedLabel(name string) (DefinedLabel, bool) {
+	if idx, ok := p.definedLabelPos[name]; ok {
+		return p.definedLabels[idx], true
+	}
+	if p.prev != nil {
+		if idx, ok := p.prev.definedLabelPos[name]; ok {
+			return p.prev.DefinedLabels[idx], true
+		}
+	}
+	return DefinedLabel{}, false
+}
+
+// sectionBound returns the start or end address of a section. Bounds not
+// known yet in this pass come from the previous one.
+func (p *Parser) sectionBound(name string, end bool) (int64, error) {
+	section, ok := parseSectionName(name)
+	if !ok {
+		return 0, fmt.Errorf("unknown section %q", name)
+	}
+	r := p.sections[section]
+	switch {
+	case end && !r.closed && p.prev != nil:
+		r = p.prev.sections[section]
+	case !end && section > p.section && p.prev != nil:
+		r = p.prev.sections[section]
+	}
+	if end {
+		return int64(r.end), nil
+	}
+	return int64(r.start), nil
+}
+
+// parseFunctionCall evaluates NAME(args). The result counts as symbolic when
+// the function returns an address or a numeric argument refers to a symbol.
+func (p *Parser) parseFunctionCall(name Token, fn exprFunction) (exprInfo, error) {
+	p.next() // name
+	p.next() // '('
+	call := &Expr{op: exprCall, text: name.Text, fn: &fn}
+	hasSymbol := fn.address
+	for i := 0; i < len(fn.params); i++ {
+		if i > 0 {
+			if _, err := p.want(COMMA); err != nil {
+				return exprInfo{}, err
+			}
+		}
+		switch fn.params[i] {
+		case 's':
+			t, err := p.want(STRING)
+			if err != nil {
+				return exprInfo{}, fmt.Errorf("%s expects a string argument", strings.ToUpper(name.Text))
+			}
+			call.args = append(call.args, &Expr{op: exprString, text: t.Text})
+			continue
+		case 'i':
+			dot := p.accept(DOT)
+			t, err := p.want(IDENT)
+			if err != nil {
+				return exprInfo{}, fmt.Errorf("%s expects a name", strings.ToUpper(name.Text))
+			}
+			if dot {
+				t.Text = "." + t.Text
+			}
+			arg := &Expr{op: exprName, text: t.Text}
+			if !fn.address {
+				// SECTSTART and SECTEND take a section rather than a symbol.
+				arg.name = p.resolveSymbol(t.Text)
+			}
+			call.args = append(call.args, arg)
+			continue
+		}
+		v, err := p.parseExprInfoUntil(COMMA, RPAREN, NEWLINE, EOF)
+		if err != nil {
+			return exprInfo{}, err
+		}
+		hasSymbol = hasSymbol || v.HasSymbol
+		call.args = append(call.args, v.Expr)
+	}
+	if _, err := p.want(RPAREN); err != nil {
+		return exprInfo{}, err
+	}
+	// The errors of the call come from layout, which evaluates it again.
+	call.value, _ = p.evaluateCall(call)
+	return exprInfo{Value: call.value, HasSymbol: hasSymbol, Expr: call}, nil
+}
+
+// evaluate computes the value of an expression the front end parsed from
+// the symbols and the location counter of this pass. The values are kept in
+// the tree, where the assembler finds those of constants.
+func (p *Parser) evaluate(info exprInfo) (exprInfo, error) {
+	if info.Expr == nil {
+		return info, nil
+	}
+	v, err := p.evaluateExpr(info.Expr)
+	if err != nil {
+		return info, withCode(CodeInvalidExpression, err)
+	}
+	info.Value = v
+	return info, nil
+}
+
+func (p *Parser) evaluateExpr(e *Expr) (int64, error) {
+	var err error
+	switch e.op {
+	case exprSymbol:
+		if strings.EqualFold(e.name, "__rs") {
+			e.value = p.rsCounter
+			break
+		}
+		v, ok := p.labels[e.name]
+		if !ok && !p.allowForwardRefs {
+			return 0, withCode(CodeUndefinedSymbol, withHint(fmt.Errorf("undefined label in expression: %s", e.name), labelHint(e.name, p.labels)))
+		}
+		*e = *p.symbolExpr(e.text, e.name, int64(v))
+	case exprPC:
+		e.value, e.section = int64(p.pc), p.section
+	case exprUnary:
+		if err := p.evaluateArgs(e); err != nil {
+			return 0, err
+		}
+		e.value, err = evalUnary(e.kind, e.args[0].value)
+	case exprBinary:
+		if err := p.evaluateArgs(e); err != nil {
+			return 0, err
+		}
+		e.value, err = evalBinary(e.kind, e.args[0].value, e.args[1].value)
+	case exprConditional:
+		if err := p.evaluateArgs(e); err != nil {
+			return 0, err
+		}
+		e.value = e.args[2].value
+		if e.args[0].value != 0 {
+			e.value = e.args[1].value
+		}
+	case exprCall:
+		if err := p.evaluateArgs(e); err != nil {
+			return 0, err
+		}
+		e.value, err = p.evaluateCall(e)
+	}
+	return e.value, err
+}
+
+// evaluateArgs evaluates the operands of a node, leaving the strings and
+// names of a call as they are.
+func (p *Parser) evaluateArgs(e *Expr) error {
+	for _, a := range e.args {
+		if a.op == exprString || a.op == exprName {
+			continue
+		}
+		if _, err := p.evaluateExpr(a); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// evaluateCall calls the function of a call node with the values its
+// arguments have.
+func (p *Parser) evaluateCall(call *Expr) (int64, error) {
+	args := make([]exprArg, len(call.args))
+	for i, a := range call.args {
+		switch a.op {
+		case exprString:
+			args[i] = exprArg{str: a.text, isStr: true}
+		case exprName:
+			// The resolved name, as the scope and the namespaces of the
+			// call are gone in layout; SECTSTART and SECTEND take a section.
+			args[i] = exprArg{str: a.name}
+			if a.name == "" {
+				args[i].str = a.text
+			}
+		default:
+			args[i] = exprArg{val: a.value}
+		}
+	}
+	return call.fn.eval(p, args)
+}
+
+// packString evaluates a string of up to four characters as a big-endian
+// value, e.g. 'ABCD' for a longword tag.
+func packString(s string) (int64, error) {
+	if len(s) == 0 || len(s) > 4 {
+		return 0, fmt.Errorf("string %q does not fit in a 32-bit value", s)
+	}
+	var v int64
+	for i := 0; i < len(s); i++ {
+		v = v<<8 | int64(s[i])
+	}
+	return v, nil
+}
@@ -30,3 +30,61 @@ DIFF: .word MID-START
 		t.Fatalf("unexpected output: got %x want %x", out, want)
 	}
 }
+
+func TestStringFunctions(t *testing.T) {
+	got := assembleSource(t, ".byte STRLEN(\"hello\"), INSTR(\"hello\", \"ll\"), INSTR(\"hello\", \"z\"), SUBSTR(\"hello\", 2, 1)\n.word SUBSTR(\"hello\", 4, 2)\n")
+	want := []byte{5, 3, 0, 'e', 'l', 'o'}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("unexpected output: got %x want %x", got, want)
+	}
+
+	for _, src := range []string{".byte STRLEN(5)\n", ".byte SUBSTR(\"ab\", 2, 2)\n"} {
+		if _, err := asm.Parse(strings.NewReader(src)); err == nil {
+			t.Fatalf("expected error for %q", src)
+		}
+	}
+}
+
+func TestExpressionFunctions(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want []byte
+	}{
+		{"ByteAndWordParts", ".byte LOW($1234), HIGH($1234)\n.word UPPER($12345678)\n", []byte{0x34, 0x12, 0x12, 0x34}},
+		{"MinMaxAbs", ".byte MIN(3,-2), MAX(3,-2), ABS(-7)\n", []byte{0xFE, 3, 7}},
+		{"Align", ".byte ALIGN(5,4), ALIGN(8,4), ALIGN(-3,4)\n", []byte{8, 8, 0}},
+		{"Defined", "a: .byte DEF(a), DEFINED(b), DEF(c)\nb: .byte 0\nc EQU 1\n", []byte{1, 0, 0, 0}},
+		{"UnsignedCompare", ".byte ULT(-1,1), UGE(-1,1), ULE(2,2), UGT(1,2)\n", []byte{0, 1, 1, 0}},
+		{"SignedCompare", ".byte SLT($FFFFFFFF,1), SGE($FFFFFFFF,1), SLE(2,2), SGT(1,2)\n", []byte{1, 0, 1, 0}},
+		{"Sections", ".org $100\nstart: .long SECTSTART(text), SECTEND(.text), SECTEND(bss)\n.byte SECTION(start), SECTION(d), SECTION(n)\n.data\nd: .word 1\nn EQU 1\n", []byte{0, 0, 1, 0, 0, 0, 1, 0x0F, 0, 0, 1, 0x11, 0, 1, 0xFF, 0, 1}},
+		{"Conditional", ".byte 1?2:3, 0?2:3, 0?1:0?5:6, 1?0?7:8:9, (1?2:3)+10, 1+1?4:5\n", []byte{2, 3, 6, 8, 12, 4}},
+		{"ConditionalOnLabels", "a: .word b-a>4 ? 1 : 2\nb:\n", []byte{0, 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := assembleSource(t, tt.src)
+			if !bytes.Equal(got, tt.want) {
+				t.Fatalf("unexpected output for %q: got %x want %x", tt.src, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpressionFunctionErrors(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{".byte ALIGN(3,0)\n", "ALIGN expects alignment >= 1"},
+		{".byte 1?2\n", "expected ':' after '?'"},
+		{".byte SECTION(nope)\n", "SECTION: undefined symbol nope"},
+		{".long SECTSTART(rodata)\n", "unknown section \"rodata\""},
+		{".byte DEF(1)\n", "DEF expects a name"},
+	}
+	for _, tt := range tests {
+		if _, err := asm.Parse(strings.NewReader(tt.src)); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Fatalf("expected error containing %q for %q, got %v", tt.want, tt.src, err)
+		}
+	}
+}
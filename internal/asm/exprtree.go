@@ -0,0 +1,318 @@
+package asm
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Expr is an operand or data expression kept as a tree. The parser builds it
+// once and evaluates it in every layout pass; the assembler evaluates it
+// again against the final symbol table, so the encoded value never depends
+// on what a forward reference looked like while parsing.
+type Expr struct {
+	op exprOp
+	// kind is the operator of unary and binary nodes.
+	kind Kind
+	// text is the source spelling of numbers, symbols and function names.
+	text string
+	// name is the resolved symbol name.
+	name string
+	// value is the value the last layout pass computed.
+	value int64
+	// label marks symbols that are addresses; constants and variables keep
+	// the value they had where the expression was parsed.
+	label   bool
+	section SectionKind
+	// implicit marks the 0 the parser adds before a unary + or -.
+	implicit bool
+	args     []*Expr
+	fn       *exprFunction
+}
+
+type exprOp uint8
+
+const (
+	exprNumber exprOp = iota
+	exprString
+	exprSymbol
+	exprPC
+	exprName
+	exprUnary
+	exprBinary
+	exprConditional
+	exprCall
+)
+
+// ExprClass tells absolute values from addresses that move with their
+// section.
+type ExprClass uint8
+
+const (
+	// ExprAbsolute is a plain number.
+	ExprAbsolute ExprClass = iota
+	// ExprRelocatable is an address in a section plus or minus a constant.
+	ExprRelocatable
+	// ExprComplex combines addresses in a way no single relocation can
+	// describe, such as the sum of two labels.
+	ExprComplex
+)
+
+func (c ExprClass) String() string {
+	switch c {
+	case ExprRelocatable:
+		return "relocatable"
+	case ExprComplex:
+		return "complex"
+	default:
+		return "absolute"
+	}
+}
+
+// DataExpr is a big-endian value of Size bytes at Offset in a DataBytes
+// block, given by Expr.
+type DataExpr struct {
+	Offset int
+	Size   int
+	Expr   *Expr
+}
+
+func numberExpr(text string, v int64) *Expr {
+	return &Expr{op: exprNumber, text: text, value: v}
+}
+
+// newUnaryExpr and newBinaryExpr leave a value that cannot be computed, such
+// as a division by a symbol not known yet, at 0; layout evaluates the tree
+// again and reports the error.
+func newUnaryExpr(op Kind, x *Expr) *Expr {
+	v, _ := evalUnary(op, x.value)
+	return &Expr{op: exprUnary, kind: op, value: v, args: []*Expr{x}}
+}
+
+func newBinaryExpr(op Kind, x, y *Expr) *Expr {
+	v, _ := evalBinary(op, x.value, y.value)
+	return &Expr{op: exprBinary, kind: op, value: v, args: []*Expr{x, y}}
+}
+
+func newConditionalExpr(cond, a, b *Expr) *Expr {
+	v := b.value
+	if cond.value != 0 {
+		v = a.value
+	}
+	return &Expr{op: exprConditional, value: v, args: []*Expr{cond, a, b}}
+}
+
+// Value is the value the last layout pass computed for the expression.
+func (e *Expr) Value() int64 {
+	return e.value
+}
+
+// Eval evaluates the expression with labels taken from the given symbol
+// table.
+func (e *Expr) Eval(labels map[string]uint32) (int64, error) {
+	switch e.op {
+	case exprSymbol:
+		if !e.label {
+			return e.value, nil
+		}
+		v, ok := labels[e.name]
+		if !ok {
+			return 0, withCode(CodeUndefinedSymbol, withHint(fmt.Errorf("undefined label in expression: %s", e.name), labelHint(e.name, labels)))
+		}
+		return int64(v), nil
+	case exprUnary:
+		x, err := e.args[0].Eval(labels)
+		if err != nil {
+			return 0, err
+		}
+		return evalUnary(e.kind, x)
+	case exprBinary:
+		x, err := e.args[0].Eval(labels)
+		if err != nil {
+			return 0, err
+		}
+		y, err := e.args[1].Eval(labels)
+		if err != nil {
+			return 0, err
+		}
+		return evalBinary(e.kind, x, y)
+	case exprConditional:
+		cond, err := e.args[0].Eval(labels)
+		if err != nil {
+			return 0, err
+		}
+		if cond != 0 {
+			return e.args[1].Eval(labels)
+		}
+		return e.args[2].Eval(labels)
+	case exprCall:
+		// Functions of names depend on parser state such as the scope and
+		// the structures seen so far, so they keep their parsed value.
+		if strings.ContainsRune(e.fn.params, 'i') {
+			return e.value, nil
+		}
+		args := make([]exprArg, len(e.args))
+		for i, a := range e.args {
+			if a.op == exprString {
+				args[i] = exprArg{str: a.text, isStr: true}
+				continue
+			}
+			v, err := a.Eval(labels)
+			if err != nil {
+				return 0, err
+			}
+			args[i] = exprArg{val: v}
+		}
+		return e.fn.eval(nil, args)
+	}
+	return e.value, nil
+}
+
+// Symbols returns the names the expression refers to, in source order and
+// without duplicates.
+func (e *Expr) Symbols() []string {
+	var names []string
+	seen := map[string]bool{}
+	e.walk(func(n *Expr) {
+		if (n.op == exprSymbol || n.op == exprName) && n.name != "" && !seen[n.name] {
+			seen[n.name] = true
+			names = append(names, n.name)
+		}
+	})
+	return names
+}
+
+func (e *Expr) walk(fn func(*Expr)) {
+	fn(e)
+	for _, a := range e.args {
+		a.walk(fn)
+	}
+}
+
+// Class reports whether the expression is absolute or relocatable, and for
+// relocatable values the section they belong to. Numeric local labels count
+// as part of the section they are referenced from.
+func (e *Expr) Class() (ExprClass, SectionKind) {
+	switch e.op {
+	case exprSymbol:
+		if e.label {
+			return ExprRelocatable, e.section
+		}
+	case exprPC:
+		return ExprRelocatable, e.section
+	case exprBinary:
+		xc, xs := e.args[0].Class()
+		yc, ys := e.args[1].Class()
+		switch {
+		case xc == ExprComplex || yc == ExprComplex:
+			return ExprComplex, SectionText
+		case xc == ExprAbsolute && yc == ExprAbsolute:
+			return ExprAbsolute, SectionText
+		case e.kind == PLUS && xc == ExprAbsolute:
+			return ExprRelocatable, ys
+		case (e.kind == PLUS || e.kind == MINUS) && yc == ExprAbsolute && !e.args[0].implicit:
+			return ExprRelocatable, xs
+		case e.kind == MINUS && xc == ExprRelocatable && yc == ExprRelocatable && xs == ys:
+			// The distance between two labels of a section does not move.
+			return ExprAbsolute, SectionText
+		}
+		return ExprComplex, SectionText
+	case exprUnary, exprConditional:
+		for _, a := range e.args {
+			if c, _ := a.Class(); c != ExprAbsolute {
+				return ExprComplex, SectionText
+			}
+		}
+	case exprCall:
+		if e.fn.address {
+			if section, ok := parseSectionName(e.args[0].text); ok {
+				return ExprRelocatable, section
+			}
+		}
+		for _, a := range e.args {
+			if c, _ := a.Class(); c != ExprAbsolute {
+				return ExprComplex, SectionText
+			}
+		}
+	}
+	return ExprAbsolute, SectionText
+}
+
+// String renders the expression in source syntax. Parentheses are emitted
+// only where precedence needs them.
+func (e *Expr) String() string {
+	var b strings.Builder
+	e.format(&b)
+	return b.String()
+}
+
+func (e *Expr) format(b *strings.Builder) {
+	switch e.op {
+	case exprNumber, exprSymbol, exprPC, exprName:
+		if e.text == "" {
+			fmt.Fprintf(b, "%d", e.value)
+			return
+		}
+		b.WriteString(e.text)
+	case exprString:
+		b.WriteString("'" + e.text + "'")
+	case exprUnary:
+		b.WriteString(operatorText[e.kind])
+		e.args[0].formatOperand(b, precedence(e.kind), false)
+	case exprBinary:
+		prec := precedence(e.kind)
+		if !e.args[0].implicit {
+			e.args[0].formatOperand(b, prec, false)
+		}
+		b.WriteString(operatorText[e.kind])
+		e.args[1].formatOperand(b, prec, true)
+	case exprConditional:
+		prec := precedence(QUESTION)
+		// A conditional as the condition needs parentheses, as it groups
+		// right to left.
+		e.args[0].formatOperand(b, prec, true)
+		b.WriteString(" ? ")
+		e.args[1].formatOperand(b, prec, false)
+		b.WriteString(" : ")
+		e.args[2].format(b)
+	case exprCall:
+		b.WriteString(e.text)
+		b.WriteByte('(')
+		for i, a := range e.args {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			a.format(b)
+		}
+		b.WriteByte(')')
+	}
+}
+
+// formatOperand writes e as an operand of an operator with precedence prec.
+// Operators group left to right, so a right operand of equal precedence
+// needs parentheses too.
+func (e *Expr) formatOperand(b *strings.Builder, prec int, right bool) {
+	own := prec + 1
+	switch e.op {
+	case exprBinary:
+		own = precedence(e.kind)
+		if e.args[0].implicit {
+			own = precedence(TILDE)
+		}
+	case exprConditional:
+		own = precedence(QUESTION)
+	}
+	if own < prec || (own == prec && right) {
+		b.WriteByte('(')
+		e.format(b)
+		b.WriteByte(')')
+		return
+	}
+	e.format(b)
+}
+
+var operatorText = map[Kind]string{
+	PLUS: "+", MINUS: "-", STAR: "*", SLASH: "/", PERCENT: "%",
+	LSHIFT: "<<", RSHIFT: ">>", AMP: "&", PIPE: "|", CARET: "^",
+	TILDE: "~", BANG: "!", LT: "<", GT: ">", LTE: "<=", GTE: ">=",
+	EQEQ: "==", NEQ: "!=", ANDAND: "&&", OROR: "||",
+}
@@ -0,0 +1,100 @@
+package asm_test
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jenska/m68kasm/internal/asm"
+)
+
+// immediateExpr parses "MOVE.L #expr,D0" between a .text label, a constant
+// and a .data label, and returns the tree behind the immediate.
+func immediateExpr(t *testing.T, expr string) *asm.Expr {
+	t.Helper()
+	src := "start: NOP\nCONST EQU 4\nMOVE.L #" + expr + ",D0\n.data\nbuf: DC.L 0\n"
+	prog, err := asm.Parse(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("parse %q: %v", expr, err)
+	}
+	ins := prog.Items[1].(*asm.Instr)
+	e, ok := ins.Args.Src.Expr.(*asm.Expr)
+	if !ok {
+		t.Fatalf("no expression recorded for %q", expr)
+	}
+	return e
+}
+
+func TestExprTree(t *testing.T) {
+	tests := []struct {
+		expr    string
+		str     string
+		symbols []string
+		class   asm.ExprClass
+		section asm.SectionKind
+	}{
+		{"buf+4", "buf+4", []string{"buf"}, asm.ExprRelocatable, asm.SectionData},
+		{"2+start", "2+start", []string{"start"}, asm.ExprRelocatable, asm.SectionText},
+		{"*-start", "*-start", []string{"start"}, asm.ExprAbsolute, asm.SectionText},
+		{"buf-start", "buf-start", []string{"buf", "start"}, asm.ExprComplex, asm.SectionText},
+		{"start+start", "start+start", []string{"start"}, asm.ExprComplex, asm.SectionText},
+		{"-start", "-start", []string{"start"}, asm.ExprComplex, asm.SectionText},
+		{"CONST*2", "CONST*2", []string{"CONST"}, asm.ExprAbsolute, asm.SectionText},
+		{"-(CONST+1)", "-(CONST+1)", []string{"CONST"}, asm.ExprAbsolute, asm.SectionText},
+		{"( 1 + 2 ) * $3", "(1+2)*$3", nil, asm.ExprAbsolute, asm.SectionText},
+		{"1-(2-3)", "1-(2-3)", nil, asm.ExprAbsolute, asm.SectionText},
+		{"(1-2)-3", "1-2-3", nil, asm.ExprAbsolute, asm.SectionText},
+		{"~(CONST|1)", "~(CONST|1)", []string{"CONST"}, asm.ExprAbsolute, asm.SectionText},
+		{"(CONST ? 1 : 2) ? 3 : 4", "(CONST ? 1 : 2) ? 3 : 4", []string{"CONST"}, asm.ExprAbsolute, asm.SectionText},
+		{"CONST>2?buf:0", "CONST>2 ? buf : 0", []string{"CONST", "buf"}, asm.ExprComplex, asm.SectionText},
+		{"low(buf)+'A'", "low(buf)+'A'", []string{"buf"}, asm.ExprComplex, asm.SectionText},
+		{"SECTSTART(.data)+2", "SECTSTART(.data)+2", nil, asm.ExprRelocatable, asm.SectionData},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			e := immediateExpr(t, tt.expr)
+			if got := e.String(); got != tt.str {
+				t.Fatalf("String() = %q, want %q", got, tt.str)
+			}
+			if got := e.Symbols(); !reflect.DeepEqual(got, tt.symbols) {
+				t.Fatalf("Symbols() = %v, want %v", got, tt.symbols)
+			}
+			if class, section := e.Class(); class != tt.class || section != tt.section {
+				t.Fatalf("Class() = %v %v, want %v %v", class, section.Name(), tt.class, tt.section.Name())
+			}
+		})
+	}
+}
+
+func TestExprTreeEval(t *testing.T) {
+	e := immediateExpr(t, "(buf+CONST)*2")
+	if got, err := e.Eval(map[string]uint32{"buf": 0x100}); err != nil || got != 0x208 {
+		t.Fatalf("Eval() = %#x, %v; want 0x208", got, err)
+	}
+	if _, err := e.Eval(map[string]uint32{}); err == nil || !strings.Contains(err.Error(), "undefined label in expression: buf") {
+		t.Fatalf("expected undefined label error, got %v", err)
+	}
+}
+
+func TestAssembleEvaluatesExpressionsAgain(t *testing.T) {
+	prog, err := asm.Parse(strings.NewReader("MOVE.L #target+1,D0\nLEA target(PC),A0\nDC.W 0,target-2\ntarget: NOP\n"))
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	// Moving the label must move every operand and data value that uses it.
+	prog.Labels["target"] = 0x1234
+	got, err := asm.Assemble(prog)
+	if err != nil {
+		t.Fatalf("assemble failed: %v", err)
+	}
+	want := []byte{
+		0x20, 0x3C, 0x00, 0x00, 0x12, 0x35, // MOVE.L #$1235,D0
+		0x41, 0xFA, 0x12, 0x2C, // LEA $1234(PC),A0
+		0x00, 0x00, 0x12, 0x32, // DC.W 0,$1232
+		0x4E, 0x71,
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("unexpected encoding: got %x want %x", got, want)
+	}
+}
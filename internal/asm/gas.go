@@ -0,0 +1,198 @@
+package asm
+
+import (
+	"strings"
+	"unicode"
+)
+
+// scanGAS handles the characters whose meaning differs in GNU as syntax:
+// '%' prefixes register names and '@' marks MIT-style addressing.
+func (lx *Lexer) scanGAS(ch rune) (Token, bool) {
+	switch {
+	case ch == '%' && unicode.IsLetter(lx.peekRune()):
+		tok := lx.scanIdent(lx.read())
+		if strings.EqualFold(tok.Text, "fp") {
+			tok.Text = "a6"
+		}
+		return tok, true
+	case ch == '@':
+		return lx.tok(AT, "@", 0), true
+	}
+	return Token{}, false
+}
+
+// gasLexer rewrites MIT addressing modes into the equivalent Motorola token
+// sequence one source line at a time, so the regular operand parser and
+// encoders are reused unchanged:
+//
+//	a0@        -> (a0)
+//	a0@+       -> (a0)+
+//	a0@-       -> -(a0)
+//	a0@(d)     -> (d,a0)
+//	a0@(d,d0:w:4) -> (d,a0,d0.w*4)
+type gasLexer struct {
+	lx      *Lexer
+	pending []Token
+}
+
+func (g *gasLexer) Next() Token {
+	if len(g.pending) == 0 {
+		var line []Token
+		for {
+			t := g.lx.Next()
+			line = append(line, t)
+			if t.Kind == NEWLINE || t.Kind == EOF {
+				break
+			}
+		}
+		g.pending = rewriteMITOperands(line)
+	}
+	t := g.pending[0]
+	g.pending = g.pending[1:]
+	return t
+}
+
+func rewriteMITOperands(line []Token) []Token {
+	out := make([]Token, 0, len(line))
+	for i := 0; i < len(line); i++ {
+		base := line[i]
+		if base.Kind != IDENT || i+1 >= len(line) || line[i+1].Kind != AT || !isMITBase(base.Text) {
+			out = append(out, base)
+			continue
+		}
+		punct := func(k Kind, text string) Token {
+			return Token{Kind: k, Text: text, Line: base.Line, Col: base.Col}
+		}
+		i++ // '@'
+		var next Token
+		if i+1 < len(line) {
+			next = line[i+1]
+		}
+		switch next.Kind {
+		case PLUS:
+			i++
+			out = append(out, punct(LPAREN, "("), base, punct(RPAREN, ")"), punct(PLUS, "+"))
+		case MINUS:
+			i++
+			out = append(out, punct(MINUS, "-"), punct(LPAREN, "("), base, punct(RPAREN, ")"))
+		case LPAREN:
+			end := matchingParen(line, i+1)
+			if end < 0 {
+				out = append(out, base, line[i])
+				continue
+			}
+			parts := splitTopLevel(line[i+2 : end])
+			i = end
+			var disp, index []Token
+			switch {
+			case len(parts) == 1 && isMITIndex(parts[0]):
+				index = parts[0]
+			case len(parts) >= 1:
+				disp = parts[0]
+				if len(parts) > 1 {
+					index = parts[1]
+				}
+			}
+			out = append(out, punct(LPAREN, "("))
+			if len(disp) > 0 {
+				out = append(out, disp...)
+				out = append(out, punct(COMMA, ","))
+			}
+			out = append(out, base)
+			if len(index) > 0 {
+				out = append(out, punct(COMMA, ","))
+				out = append(out, mitIndexTokens(index)...)
+			}
+			out = append(out, punct(RPAREN, ")"))
+		default:
+			out = append(out, punct(LPAREN, "("), base, punct(RPAREN, ")"))
+		}
+	}
+	return out
+}
+
+func isMITBase(name string) bool {
+	ok, _ := isRegAn(name)
+	return ok || isPC(name)
+}
+
+func isMITIndex(tokens []Token) bool {
+	if len(tokens) == 0 || tokens[0].Kind != IDENT {
+		return false
+	}
+	name := tokens[0].Text
+	if idx := strings.IndexByte(name, '.'); idx >= 0 {
+		name = name[:idx]
+	}
+	_, err := parseIndexRegister(name)
+	return err == nil
+}
+
+// mitIndexTokens converts an MIT index "d0:w:4" into Motorola "d0.w*4".
+func mitIndexTokens(tokens []Token) []Token {
+	if len(tokens) < 3 || tokens[1].Kind != COLON || tokens[2].Kind != IDENT {
+		return tokens
+	}
+	reg := tokens[0]
+	reg.Text += "." + tokens[2].Text
+	out := []Token{reg}
+	if len(tokens) >= 5 && tokens[3].Kind == COLON {
+		out = append(out, Token{Kind: STAR, Text: "*", Line: reg.Line, Col: reg.Col})
+		out = append(out, tokens[4:]...)
+	}
+	return out
+}
+
+func matchingParen(tokens []Token, open int) int {
+	depth := 0
+	for i := open; i < len(tokens); i++ {
+		switch tokens[i].Kind {
+		case LPAREN:
+			depth++
+		case RPAREN:
+			depth--
+			if depth == 0 {
+				return i
+			}
+		case NEWLINE, EOF:
+			return -1
+		}
+	}
+	return -1
+}
+
+func splitTopLevel(tokens []Token) [][]Token {
+	var parts [][]Token
+	depth, start := 0, 0
+	for i, t := range tokens {
+		switch t.Kind {
+		case LPAREN:
+			depth++
+		case RPAREN:
+			depth--
+		case COMMA:
+			if depth == 0 {
+				parts = append(parts, tokens[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, tokens[start:])
+}
+
+// gasSizedMnemonic splits a gas mnemonic with a trailing size letter (movel,
+// addqw, bras) into its Motorola form (MOVE.L, ADDQ.W, BRA.S).
+func (p *Parser) gasSizedMnemonic(text string) (string, bool) {
+	if p.syntax != SyntaxGAS || strings.IndexByte(text, '.') >= 0 || len(text) < 2 {
+		return "", false
+	}
+	size := strings.ToUpper(text[len(text)-1:])
+	if size != "B" && size != "W" && size != "L" && size != "S" {
+		return "", false
+	}
+	base := text[:len(text)-1]
+	if p.instrs.Lookup(strings.ToUpper(base)) == nil {
+		return "", false
+	}
+	return base + "." + text[len(text)-1:], true
+}
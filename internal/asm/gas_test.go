@@ -0,0 +1,83 @@
+package asm_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/jenska/m68kasm/internal/asm"
+)
+
+func assembleWithSyntax(t *testing.T, src string, syntax asm.Syntax) []byte {
+	t.Helper()
+	prog, err := asm.ParseWithOptions(strings.NewReader(src), asm.ParseOptions{Syntax: syntax})
+	if err != nil {
+		t.Fatalf("parse %q: %v", src, err)
+	}
+	out, err := asm.Assemble(prog)
+	if err != nil {
+		t.Fatalf("assemble %q: %v", src, err)
+	}
+	return out
+}
+
+func TestGASSyntaxMatchesMotorola(t *testing.T) {
+	tests := []struct {
+		name     string
+		gas      string
+		motorola string
+	}{
+		{"PostIncrement", "movel %a0@+,%d0\n", "MOVE.L (A0)+,D0\n"},
+		{"PreDecrement", "movel %d0,%sp@-\n", "MOVE.L D0,-(SP)\n"},
+		{"Indirect", "moveb %a1@,%d2\n", "MOVE.B (A1),D2\n"},
+		{"Displacement", "movew %a0@(4),%d1\n", "MOVE.W (4,A0),D1\n"},
+		{"IndexScaled", "moveb %a0@(2,%d0:w:4),%d2\n", "MOVE.B (2,A0,D0.W*4),D2\n"},
+		{"IndexOnly", "movel %a0@(%d1:l),%d3\n", "MOVE.L (A0,D1.L),D3\n"},
+		{"MotorolaOperandsWithPrefix", "move.l (%a0,%d1.w),%d0\n", "MOVE.L (A0,D1.W),D0\n"},
+		{"FramePointer", "movel %fp,%d0\n", "MOVE.L A6,D0\n"},
+		{"QuickSized", "addql #1,%d0\n", "ADDQ.L #1,D0\n"},
+		{"MultipleRegisters", "moveml %d0-%d1/%a0,%sp@-\n", "MOVEM.L D0-D1/A0,-(SP)\n"},
+		{"BranchShort", "1: bras 1b\n", "1: BRA.S 1b\n"},
+		{"PipeComment", "nop | idle\n", "NOP\n"},
+		{"LineMarker", "# 1 \"crt0.S\"\nrts\n", "RTS\n"},
+		{"LongDifference", "start: nop\n.long end - start\nend:\n", "start: NOP\nDC.L end-start\nend:\n"},
+		{"SectionFlags", ".section .text,\"ax\",@progbits\nnop\n", "NOP\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := assembleWithSyntax(t, tt.gas, asm.SyntaxGAS)
+			want := assembleWithSyntax(t, tt.motorola, asm.SyntaxMotorola)
+			if !bytes.Equal(got, want) {
+				t.Fatalf("gas %q assembled to %x, want %x", tt.gas, got, want)
+			}
+		})
+	}
+}
+
+func TestGASGlobalSymbols(t *testing.T) {
+	src := ".globl _start\n_start:\nnop\nhelper:\nrts\n"
+	prog, err := asm.ParseWithOptions(strings.NewReader(src), asm.ParseOptions{Syntax: asm.SyntaxGAS})
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	got := map[string]bool{}
+	for _, label := range prog.DefinedLabels {
+		got[label.Name] = label.Global
+	}
+	if !got["_start"] || got["helper"] {
+		t.Fatalf("unexpected global flags: %v", got)
+	}
+}
+
+func TestParseSyntax(t *testing.T) {
+	if s, err := asm.ParseSyntax("GAS"); err != nil || s != asm.SyntaxGAS {
+		t.Fatalf("ParseSyntax(GAS) = %v, %v", s, err)
+	}
+	if s, err := asm.ParseSyntax(""); err != nil || s != asm.SyntaxMotorola {
+		t.Fatalf("ParseSyntax(\"\") = %v, %v", s, err)
+	}
+	if _, err := asm.ParseSyntax("intel"); err == nil {
+		t.Fatalf("expected error for unknown syntax")
+	}
+}
@@ -0,0 +1,68 @@
+package asm
+
+import (
+	"fmt"
+	"strings"
+)
+
+const ihexDataBytesPerRecord = 16
+
+// Intel HEX record types.
+const (
+	ihexData               = 0x00
+	ihexEndOfFile          = 0x01
+	ihexExtendedLinearAddr = 0x04
+	ihexStartLinearAddr    = 0x05
+)
+
+// AssembleIntelHex assembles the given program and returns its Intel HEX
+// representation.
+func AssembleIntelHex(p *Program) ([]byte, error) {
+	_, listing, _, err := assemble(nil, nil, p, true)
+	if err != nil {
+		return nil, err
+	}
+
+	return FormatIntelHex(listing, p.Origin), nil
+}
+
+// FormatIntelHex converts listing metadata into Intel HEX text with 32-bit
+// linear addresses. Data records hold the bytes of each segment, an extended
+// linear address record precedes the first one above each 64 KiB boundary,
+// and a start linear address record gives origin as the entry point.
+func FormatIntelHex(entries []ListingEntry, origin uint32) []byte {
+	var lines []string
+	var upper uint32
+	for _, seg := range listingSegments(entries) {
+		addr, data := seg.Addr, seg.Data
+		for len(data) > 0 {
+			if addr>>16 != upper {
+				upper = addr >> 16
+				lines = append(lines, ihexRecord(ihexExtendedLinearAddr, 0, []byte{byte(upper >> 8), byte(upper)}))
+			}
+			// Records do not cross a 64 KiB boundary, as their address is
+			// the low half only.
+			n := min(len(data), ihexDataBytesPerRecord, int(0x10000-addr&0xFFFF))
+			lines = append(lines, ihexRecord(ihexData, uint16(addr), data[:n]))
+			addr += uint32(n)
+			data = data[n:]
+		}
+	}
+	lines = append(lines, ihexRecord(ihexStartLinearAddr, 0, []byte{byte(origin >> 24), byte(origin >> 16), byte(origin >> 8), byte(origin)}))
+	lines = append(lines, ihexRecord(ihexEndOfFile, 0, nil))
+
+	return []byte(strings.Join(lines, "\n") + "\n")
+}
+
+func ihexRecord(kind byte, addr uint16, data []byte) string {
+	sum := byte(len(data)) + byte(addr>>8) + byte(addr) + kind
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, ":%02X%04X%02X", len(data), addr, kind)
+	for _, b := range data {
+		sum += b
+		fmt.Fprintf(&sb, "%02X", b)
+	}
+	fmt.Fprintf(&sb, "%02X", -sum)
+	return sb.String()
+}
@@ -0,0 +1,83 @@
+package asm
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func formatIntelHexSource(t *testing.T, src string) string {
+	t.Helper()
+	prog, err := Parse(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+
+	_, listing, err := AssembleWithListing(prog)
+	if err != nil {
+		t.Fatalf("assemble failed: %v", err)
+	}
+	return string(FormatIntelHex(listing, prog.Origin))
+}
+
+func TestFormatIntelHex_OriginAndChecksums(t *testing.T) {
+	got := formatIntelHexSource(t, ".org 0x1000\n.byte 0x11,0x22,0x33\n")
+	want := ":0310000011223387\n:0400000500001000E7\n:00000001FF\n"
+	if got != want {
+		t.Fatalf("unexpected Intel HEX output:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestFormatIntelHex_SplitsIntoChunks(t *testing.T) {
+	values := make([]string, 20)
+	for i := range values {
+		values[i] = fmt.Sprintf("%d", i)
+	}
+	got := formatIntelHexSource(t, ".org 0\n.byte "+strings.Join(values, ",")+"\n")
+	want := strings.Join([]string{
+		":10000000000102030405060708090A0B0C0D0E0F78",
+		":0400100010111213A6",
+		":0400000500000000F7",
+		":00000001FF",
+		"",
+	}, "\n")
+	if got != want {
+		t.Fatalf("unexpected multi-record output:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestFormatIntelHex_ObjBlockAtLoadAddress(t *testing.T) {
+	// The bytes of an OBJ block stay in sequence, as in the binary output.
+	got := formatIntelHexSource(t, "ORG $1000\nNOP\nOBJ $1010\nRTS\nOBJEND\nNOP\n")
+	want := ":061000004E714E754E71A9\n:0400000500001000E7\n:00000001FF\n"
+	if got != want {
+		t.Fatalf("unexpected output for an OBJ block:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestFormatIntelHex_KeepsGaps(t *testing.T) {
+	entries := []ListingEntry{
+		{Line: 1, Load: 0, Bytes: []byte{1}},
+		{Line: 2, Load: 0x20000, Bytes: []byte{2}},
+	}
+	got := string(FormatIntelHex(entries, 0))
+	want := ":0100000001FE\n:020000040002F8\n:0100000002FD\n:0400000500000000F7\n:00000001FF\n"
+	if got != want {
+		t.Fatalf("unexpected output for a gap:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestFormatIntelHex_ExtendedLinearAddress(t *testing.T) {
+	got := formatIntelHexSource(t, ".org $FFF8\nDC.L 1,2,3\nDC.W $4E75\n")
+	want := strings.Join([]string{
+		":08FFF8000000000100000002FE",
+		":020000040001F9",
+		":06000000000000034E7534",
+		":040000050000FFF800",
+		":00000001FF",
+		"",
+	}, "\n")
+	if got != want {
+		t.Fatalf("unexpected output across 64 KiB:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -140,7 +140,7 @@ func validateAddSubToDn(name string, a *Args) error {
 		return fmt.Errorf("%s.B does not allow address register source", name)
 	}
 	if a.Src.Kind == EAkImm {
-		if err := checkImmediateRange(a.Src.Imm, a.Size); err != nil {
+		if err := checkImmediateRange(a, a.Size); err != nil {
 			return err
 		}
 	}
@@ -160,7 +160,7 @@ func validateAddSubAddr(name string, a *Args) error {
 		return fmt.Errorf("%s does not support byte size for address destination", name)
 	}
 	if a.Src.Kind == EAkImm {
-		if err := checkImmediateRange(a.Src.Imm, a.Size); err != nil {
+		if err := checkImmediateRange(a, a.Size); err != nil {
 			return err
 		}
 	}
@@ -169,7 +169,7 @@ func validateAddSubAddr(name string, a *Args) error {
 
 func validateAddSubQuick(name string, a *Args) error {
 	if a.Src.Imm < 1 || a.Src.Imm > 8 {
-		return fmt.Errorf("%s immediate out of range: %d", name, a.Src.Imm)
+		return rangeErrorf("%s immediate out of range: %d", name, a.Src.Imm)
 	}
 	if isPCRelativeKind(a.Dst.Kind) || a.Dst.Kind == EAkImm {
 		return fmt.Errorf("%s requires alterable destination", name)
@@ -181,7 +181,7 @@ func validateAddSubQuick(name string, a *Args) error {
 }
 
 func validateAddSubImm(name string, a *Args) error {
-	if err := checkImmediateRange(a.Src.Imm, a.Size); err != nil {
+	if err := checkImmediateRange(a, a.Size); err != nil {
 		return err
 	}
 	if !isDataAlterable(a.Dst.Kind) {
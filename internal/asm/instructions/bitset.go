@@ -76,7 +76,7 @@ func validateBitReg(name string, a *Args) error {
 }
 
 func validateBitImm(name string, a *Args) error {
-	if err := checkImmediateRange(a.Src.Imm, ByteSize); err != nil {
+	if err := checkImmediateRange(a, ByteSize); err != nil {
 		return err
 	}
 	switch a.Dst.Kind {
@@ -101,7 +101,7 @@ func validateBitTestReg(a *Args) error {
 }
 
 func validateBitTestImm(a *Args) error {
-	if err := checkImmediateRange(a.Src.Imm, ByteSize); err != nil {
+	if err := checkImmediateRange(a, ByteSize); err != nil {
 		return err
 	}
 	switch a.Dst.Kind {
@@ -75,7 +75,7 @@ var defCMPA = InstrDef{
 
 func validateCMP(a *Args) error {
 	if a.Src.Kind == EAkImm {
-		if err := checkImmediateRange(a.Src.Imm, a.Size); err != nil {
+		if err := checkImmediateRange(a, a.Size); err != nil {
 			return err
 		}
 	}
@@ -90,7 +90,7 @@ func validateCMPM(a *Args) error {
 }
 
 func validateCMPI(a *Args) error {
-	if err := checkImmediateRange(a.Src.Imm, a.Size); err != nil {
+	if err := checkImmediateRange(a, a.Size); err != nil {
 		return err
 	}
 	if !isDataAlterable(a.Dst.Kind) {
@@ -107,7 +107,7 @@ func validateCMPA(a *Args) error {
 		return fmt.Errorf("CMPA does not support byte size")
 	}
 	if a.Src.Kind == EAkImm {
-		return checkImmediateRange(a.Src.Imm, a.Size)
+		return checkImmediateRange(a, a.Size)
 	}
 	return nil
 }
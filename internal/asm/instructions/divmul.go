@@ -20,7 +20,7 @@ func newDivMulDef(name string, wordBits uint16) *InstrDef {
 				Validate:    func(a *Args) error { return validateDivMul(name, a) },
 				Steps: []EmitStep{
 					{WordBits: wordBits, Fields: []FieldRef{FDnReg, FSrcEA}},
-					{Trailer: []TrailerItem{TSrcEAExt}},
+					{Trailer: []TrailerItem{TSrcEAExt, TSrcImm}},
 				},
 			},
 		},
@@ -34,5 +34,8 @@ func validateDivMul(name string, a *Args) error {
 	if a.Src.Kind == EAkAn {
 		return fmt.Errorf("%s does not allow address register source", name)
 	}
+	if a.Src.Kind == EAkImm {
+		return checkImmediateRange(a, WordSize)
+	}
 	return nil
 }
@@ -45,7 +45,7 @@ func validateLINK(a *Args) error {
 	if a.Src.Kind != EAkImm || a.Dst.Kind != EAkAn {
 		return fmt.Errorf("LINK requires address register and immediate displacement")
 	}
-	return checkImmediateRange(a.Src.Imm, WordSize)
+	return checkImmediateRange(a, WordSize)
 }
 
 func validateUNLK(a *Args) error {
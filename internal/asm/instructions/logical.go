@@ -78,7 +78,7 @@ func validateLogicToDn(name string, a *Args) error {
 		return fmt.Errorf("%s does not allow address register source", name)
 	}
 	if a.Src.Kind == EAkImm {
-		if err := checkImmediateRange(a.Src.Imm, a.Size); err != nil {
+		if err := checkImmediateRange(a, a.Size); err != nil {
 			return err
 		}
 	}
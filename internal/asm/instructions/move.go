@@ -88,7 +88,7 @@ func validateMOVE(a *Args) error {
 		}
 	}
 	if a.Src.Kind == EAkImm {
-		if err := checkImmediateRange(a.Src.Imm, a.Size); err != nil {
+		if err := checkImmediateRange(a, a.Size); err != nil {
 			return err
 		}
 	}
@@ -103,7 +103,7 @@ func validateMoveToSR(a *Args) error {
 		return fmt.Errorf("MOVE to SR requires data addressing source")
 	}
 	if a.Src.Kind == EAkImm {
-		return checkImmediateRange(a.Src.Imm, WordSize)
+		return checkImmediateRange(a, WordSize)
 	}
 	return nil
 }
@@ -133,7 +133,7 @@ func validateMoveToCCR(a *Args) error {
 		return fmt.Errorf("MOVE to CCR requires data addressing source")
 	}
 	if a.Src.Kind == EAkImm {
-		return checkImmediateRange(a.Src.Imm, WordSize)
+		return checkImmediateRange(a, WordSize)
 	}
 	return nil
 }
@@ -143,7 +143,7 @@ func validateMOVEA(a *Args) error {
 		return fmt.Errorf("MOVEA does not support byte size")
 	}
 	if a.Src.Kind == EAkImm {
-		return checkImmediateRange(a.Src.Imm, a.Size)
+		return checkImmediateRange(a, a.Size)
 	}
 	return nil
 }
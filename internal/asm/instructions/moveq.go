@@ -26,7 +26,7 @@ func validateMOVEQ(a *Args) error {
 		return fmt.Errorf("MOVEQ needs immediate")
 	}
 	if a.Src.Imm < -128 || a.Src.Imm > 127 {
-		return fmt.Errorf("immediate out of range for .b: %d", a.Src.Imm)
+		return rangeErrorf("MOVEQ immediate out of range (-128 to 127): %d", a.Src.Imm)
 	}
 	return nil
 }
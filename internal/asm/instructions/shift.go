@@ -54,7 +54,7 @@ func validateShiftImmediate(a *Args) error {
 		return fmt.Errorf("shift requires immediate count")
 	}
 	if a.Src.Imm < 1 || a.Src.Imm > 8 {
-		return fmt.Errorf("shift count out of range: %d", a.Src.Imm)
+		return rangeErrorf("shift count out of range: %d", a.Src.Imm)
 	}
 	if a.Dst.Kind != EAkDn {
 		return fmt.Errorf("shift destination must be data register")
@@ -50,18 +50,18 @@ func validateSRImmediate(a *Args) error {
 	if a.Dst.Kind != EAkSR {
 		return fmt.Errorf("requires SR destination")
 	}
-	return checkImmediateRange(a.Src.Imm, WordSize)
+	return checkImmediateRange(a, WordSize)
 }
 
 func validateCCRImmediate(a *Args) error {
 	if a.Dst.Kind != EAkCCR {
 		return fmt.Errorf("requires CCR destination")
 	}
-	return checkImmediateRange(a.Src.Imm, ByteSize)
+	return checkImmediateRange(a, ByteSize)
 }
 
 func validateImmediateLogicEA(a *Args) error {
-	if err := checkImmediateRange(a.Src.Imm, a.Size); err != nil {
+	if err := checkImmediateRange(a, a.Size); err != nil {
 		return err
 	}
 	if !isDataAlterable(a.Dst.Kind) {
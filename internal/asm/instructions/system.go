@@ -136,7 +136,7 @@ var defTRAP = InstrDef{
 
 func validateTRAP(a *Args) error {
 	if a.Src.Imm < 0 || a.Src.Imm > 15 {
-		return fmt.Errorf("TRAP vector out of range: %d", a.Src.Imm)
+		return rangeErrorf("TRAP vector out of range: %d", a.Src.Imm)
 	}
 	return nil
 }
@@ -145,5 +145,5 @@ func validateSTOP(a *Args) error {
 	if a.Src.Kind != EAkImm {
 		return fmt.Errorf("STOP requires immediate operand")
 	}
-	return checkImmediateRange(a.Src.Imm, WordSize)
+	return checkImmediateRange(a, WordSize)
 }
@@ -1,6 +1,10 @@
 package instructions
 
-import "sync"
+import (
+	"maps"
+	"slices"
+	"sync"
+)
 
 // Table is a read-only lookup structure for instruction definitions.
 //
@@ -35,6 +39,11 @@ func (t *Table) Lookup(mnemonic string) *InstrDef {
 	return t.defs[mnemonic]
 }
 
+// Mnemonics returns the mnemonics of the table in sorted order.
+func (t *Table) Mnemonics() []string {
+	return slices.Sorted(maps.Keys(t.defs))
+}
+
 func cloneDefs(src map[string]*InstrDef) map[string]*InstrDef {
 	dst := make(map[string]*InstrDef, len(src))
 	for k, v := range src {
@@ -117,16 +117,28 @@ type EmitStep struct {
 	Trailer  []TrailerItem
 }
 
+// Expr is the source expression behind an operand value. The assembler
+// evaluates it again against the final symbol table before encoding.
+type Expr interface {
+	Eval(labels map[string]uint32) (int64, error)
+	String() string
+}
+
 type Args struct {
 	Target        string
 	TargetAddr    int64
 	HasTargetAddr bool
-	Src, Dst      EAExpr
-	Size          Size
+	// TargetExpr is the expression behind TargetAddr.
+	TargetExpr Expr
+	Src, Dst   EAExpr
+	Size       Size
 
 	HasImmQuick bool
 	RegMaskSrc  uint16
 	RegMaskDst  uint16
+	// AllowTruncation skips the range checks of immediates, which are then
+	// encoded with their low bits.
+	AllowTruncation bool
 }
 
 type EAExprKind uint16
@@ -158,6 +170,12 @@ type EAExpr struct {
 	Index  EAIndex
 	Abs16  uint16
 	Abs32  uint32
+	// Expr is the expression behind Imm, Disp16, Index.Disp8, Abs16 or
+	// Abs32, depending on Kind.
+	Expr Expr
+	// AutoSize marks an absolute address whose .W or .L size the assembler
+	// chose because the source gave none.
+	AutoSize bool
 }
 
 type EAIndex struct {
@@ -1,6 +1,22 @@
 package instructions
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrOutOfRange is matched by errors.Is on the errors of values that do not
+// fit their field.
+var ErrOutOfRange = errors.New("value out of range")
+
+type rangeError struct{ msg string }
+
+func (e *rangeError) Error() string        { return e.msg }
+func (e *rangeError) Is(target error) bool { return target == ErrOutOfRange }
+
+func rangeErrorf(format string, args ...any) error {
+	return &rangeError{fmt.Sprintf(format, args...)}
+}
 
 // swapSrcDstIfDstNone swaps Src and Dst if Dst is empty.
 // Handles single-operand instructions parsed with operand in source position.
@@ -33,19 +49,25 @@ func validateControlEA(name string, a *Args) error {
 	return nil
 }
 
-func checkImmediateRange(v int64, sz Size) error {
+// checkImmediateRange reports a source immediate that does not fit sz as a
+// signed or unsigned value.
+func checkImmediateRange(a *Args, sz Size) error {
+	if a.AllowTruncation {
+		return nil
+	}
+	v := a.Src.Imm
 	switch sz {
 	case ByteSize:
 		if v < -128 || v > 255 {
-			return fmt.Errorf("immediate out of range for .b: %d", v)
+			return rangeErrorf("immediate out of range for .b: %d", v)
 		}
 	case WordSize:
 		if v < -32768 || v > 65535 {
-			return fmt.Errorf("immediate out of range for .w: %d", v)
+			return rangeErrorf("immediate out of range for .w: %d", v)
 		}
 	case LongSize:
 		if v < -0x80000000 || v > 0xFFFFFFFF {
-			return fmt.Errorf("immediate out of range for .l: %d", v)
+			return rangeErrorf("immediate out of range for .l: %d", v)
 		}
 	}
 	return nil
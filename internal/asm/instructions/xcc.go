@@ -15,49 +15,62 @@ var sccConditions = []string{
 	"SVC", "SVS", "SPL", "SMI", "SGE", "SLT", "SGT", "SLE",
 }
 
+// conditionAliases maps the alternative Motorola condition spellings onto the
+// condition code shared with the canonical mnemonic (CC = HS, CS = LO).
+var conditionAliases = []struct {
+	name string
+	cond int
+}{
+	{"CC", 4},
+	{"CS", 5},
+}
+
 func init() {
 	for c, b := range branchConditions {
-		sz := ByteSize
-		if b == "BSR" {
-			sz = WordSize
-		}
-		registerInstrDef(&InstrDef{
-			Mnemonic: b,
-			Forms: []FormDef{
-				{
-					DefaultSize: sz,
-					Sizes:       []Size{ByteSize, WordSize},
-					OperKinds:   []OperandKind{OpkDispRel},
-					Validate:    nil,
-					Steps: []EmitStep{
-						{WordBits: 0x6000 | uint16(c)<<8, Fields: []FieldRef{FBranchLow8}},
-						{Trailer: []TrailerItem{TBranchWordIfNeeded}},
-					},
-				},
-			},
-		})
+		registerInstrDef(newBranchDef(b, c))
 	}
-
 	for c, m := range dbConditions {
-		registerInstrDef(&InstrDef{
-			Mnemonic: m,
-			Forms: []FormDef{
-				{
-					DefaultSize: WordSize,
-					Sizes:       []Size{WordSize},
-					OperKinds:   []OperandKind{OpkDn, OpkDispRel},
-					Validate:    nil,
-					Steps: []EmitStep{
-						{WordBits: 0x50C8 | uint16(c)<<8, Fields: []FieldRef{FSrcDnReg}},
-						{Trailer: []TrailerItem{TBranchWordIfNeeded}},
-					},
+		registerInstrDef(newDBccDef(m, c))
+	}
+	// DBF is an alias for DBRA (condition code 1)
+	registerInstrDef(newDBccDef("DBF", 1))
+
+	for c, s := range sccConditions {
+		registerInstrDef(newSccDef(s, c))
+	}
+
+	for _, alias := range conditionAliases {
+		registerInstrDef(newBranchDef("B"+alias.name, alias.cond))
+		registerInstrDef(newDBccDef("DB"+alias.name, alias.cond))
+		registerInstrDef(newSccDef("S"+alias.name, alias.cond))
+	}
+}
+
+func newBranchDef(name string, c int) *InstrDef {
+	sz := ByteSize
+	if name == "BSR" {
+		sz = WordSize
+	}
+	return &InstrDef{
+		Mnemonic: name,
+		Forms: []FormDef{
+			{
+				DefaultSize: sz,
+				Sizes:       []Size{ByteSize, WordSize},
+				OperKinds:   []OperandKind{OpkDispRel},
+				Validate:    nil,
+				Steps: []EmitStep{
+					{WordBits: 0x6000 | uint16(c)<<8, Fields: []FieldRef{FBranchLow8}},
+					{Trailer: []TrailerItem{TBranchWordIfNeeded}},
 				},
 			},
-		})
+		},
 	}
-	// DBF is an alias for DBRA (condition code 1)
-	registerInstrDef(&InstrDef{
-		Mnemonic: "DBF",
+}
+
+func newDBccDef(name string, c int) *InstrDef {
+	return &InstrDef{
+		Mnemonic: name,
 		Forms: []FormDef{
 			{
 				DefaultSize: WordSize,
@@ -65,29 +78,28 @@ func init() {
 				OperKinds:   []OperandKind{OpkDn, OpkDispRel},
 				Validate:    nil,
 				Steps: []EmitStep{
-					{WordBits: 0x51C8, Fields: []FieldRef{FSrcDnReg}},
+					{WordBits: 0x50C8 | uint16(c)<<8, Fields: []FieldRef{FSrcDnReg}},
 					{Trailer: []TrailerItem{TBranchWordIfNeeded}},
 				},
 			},
 		},
-	})
+	}
+}
 
-	for i, c := range sccConditions {
-		registerInstrDef(
-			&InstrDef{
-				Mnemonic: c,
-				Forms: []FormDef{
-					{
-						DefaultSize: ByteSize,
-						Sizes:       []Size{ByteSize},
-						OperKinds:   []OperandKind{OpkEA},
-						Validate:    validateDataAlterable(c),
-						Steps: []EmitStep{
-							{WordBits: 0x50C0 | (uint16(i) << 8), Fields: []FieldRef{FDstEA}},
-							{Trailer: []TrailerItem{TDstEAExt}},
-						},
-					},
+func newSccDef(name string, c int) *InstrDef {
+	return &InstrDef{
+		Mnemonic: name,
+		Forms: []FormDef{
+			{
+				DefaultSize: ByteSize,
+				Sizes:       []Size{ByteSize},
+				OperKinds:   []OperandKind{OpkEA},
+				Validate:    validateDataAlterable(name),
+				Steps: []EmitStep{
+					{WordBits: 0x50C0 | (uint16(c) << 8), Fields: []FieldRef{FDstEA}},
+					{Trailer: []TrailerItem{TDstEAExt}},
 				},
-			})
+			},
+		},
 	}
 }
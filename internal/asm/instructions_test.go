@@ -50,7 +50,9 @@ func TestAssembleCoreInstructions(t *testing.T) {
 		{"MoveImmediateByteToMem", "MOVE.B #$12,(A0)\n", []byte{0x10, 0xBC, 0x00, 0x12}},
 		{"MoveQuickSigned", "MOVEQ #-1,D0\n", []byte{0x70, 0xFF}},
 		{"MoveAddressMnemonic", "MOVEA.W (A0),A1\n", []byte{0x32, 0x50}},
-		{"MoveAddressAbsLong", "MOVEA.W $100,A1\n", []byte{0x32, 0x79, 0x00, 0x00, 0x01, 0x00}},
+		{"MoveAddressAbsLong", "MOVEA.W $100.l,A1\n", []byte{0x32, 0x79, 0x00, 0x00, 0x01, 0x00}},
+		{"MoveAddressAbsAuto", "MOVEA.W $100,A1\n", []byte{0x32, 0x78, 0x01, 0x00}},
+		{"MoveAddressAbsAutoLong", "MOVEA.W $12345,A1\n", []byte{0x32, 0x79, 0x00, 0x01, 0x23, 0x45}},
 		{"MoveAddressAbsWord", "MOVEA.W $100.w,A1\n", []byte{0x32, 0x78, 0x01, 0x00}},
 		{"AddWord", "ADD.W D1,D0\n", []byte{0xD0, 0x41}},
 		{"AddImmediate", "ADD.W #1,D0\n", []byte{0xD0, 0x7C, 0x00, 0x01}},
@@ -78,6 +80,8 @@ func TestAssembleCoreInstructions(t *testing.T) {
 		{"MultiplyWordSigned", "MULS (A1),D0\n", []byte{0xC1, 0xD1}},
 		{"DivideWordUnsigned", "DIVU (A2),D1\n", []byte{0x82, 0xD2}},
 		{"DivideWordSigned", "DIVS (A2),D1\n", []byte{0x83, 0xD2}},
+		{"MultiplyImmediate", "MULU #$1234,D0\n", []byte{0xC0, 0xFC, 0x12, 0x34}},
+		{"DivideImmediate", "DIVS #-2,D1\n", []byte{0x83, 0xFC, 0xFF, 0xFE}},
 		{"MoveToSRImmediate", "MOVE #$2700,SR\n", []byte{0x46, 0xFC, 0x27, 0x00}},
 		{"MoveFromSRToDn", "MOVE SR,D0\n", []byte{0x40, 0xC0}},
 		{"MoveUSPToA0", "MOVE USP,A0\n", []byte{0x4E, 0x68}},
@@ -89,7 +93,7 @@ func TestAssembleCoreInstructions(t *testing.T) {
 		{"JumpAddressIndirect", "JMP (A0)\n", []byte{0x4E, 0xD0}},
 		{"ORIToCCR", "ORI #1,CCR\n", []byte{0x00, 0x3C, 0x00, 0x01}},
 		{"ANDIToSR", "ANDI #$FF00,SR\n", []byte{0x02, 0x7C, 0xFF, 0x00}},
-		{"MoveByteLabel", "label:\n.WORD 0\nMOVE.B label,D0\n", []byte{0x00, 0x00, 0x10, 0x39, 0x00, 0x00, 0x00, 0x00}},
+		{"MoveByteLabel", "label:\n.WORD 0\nMOVE.B label,D0\n", []byte{0x00, 0x00, 0x10, 0x38, 0x00, 0x00}},
 		{"BitSetImmediateToDn", "BSET #1,D0\n", []byte{0x08, 0xC0, 0x00, 0x01}},
 		{"BitClearImmediateToMem", "BCLR #7,(A1)\n", []byte{0x08, 0x91, 0x00, 0x07}},
 		{"BitChangeRegisterSource", "BCHG D2,(A3)\n", []byte{0x05, 0x53}},
@@ -160,7 +164,10 @@ func TestAssembleCoreInstructions(t *testing.T) {
 		{"JmpIndex", "JMP (4,A0,D1.W)\n", []byte{0x4E, 0xF0, 0x10, 0x04}},
 		{"JsrAbsLong", "JSR $123456.L\n", []byte{0x4E, 0xB9, 0x00, 0x12, 0x34, 0x56}},
 		{"LeaPCRel", "LEA (10,PC),A0\n", []byte{0x41, 0xFA, 0x00, 0x08}},
-		{"LeaPCRelLabel", "LEA target(PC),A0\n.WORD 0\ntarget:\n", []byte{0x41, 0xFA, 0x00, 0x02, 0x00, 0x00}},
+		{"LeaPCRelLabel", "LEA target(PC),A0\n.WORD 0\ntarget:\n", []byte{0x41, 0xFA, 0x00, 0x04, 0x00, 0x00}},
+		{"LeaPCRelBackLabel", "target:\n.WORD 0\nLEA target(PC),A0\n", []byte{0x00, 0x00, 0x41, 0xFA, 0xFF, 0xFC}},
+		{"LeaPCRelLabelOrigin", ".ORG $1000\nLEA target(PC),A0\n.WORD 0\ntarget:\n", []byte{0x41, 0xFA, 0x00, 0x04, 0x00, 0x00}},
+		{"MovePCIndexLabel", "MOVE.W table(PC,D1.W),D0\n.WORD 0\ntable:\n", []byte{0x30, 0x3B, 0x10, 0x04, 0x00, 0x00}},
 		{"PeaPCIndex", "PEA (4,PC,D1.W)\n", []byte{0x48, 0x7B, 0x10, 0x02}},
 		{"BSetPostInc", "BSET #0,(A0)+\n", []byte{0x08, 0xD8, 0x00, 0x00}},
 		{"BSetRegPostInc", "BSET D1,(A0)+\n", []byte{0x03, 0xD8}},
@@ -236,6 +243,37 @@ func TestBranchPCIncludesExtensionWords(t *testing.T) {
 	}
 }
 
+func TestSingleOperandPCIncludesExtensionWords(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want int
+	}{
+		{"JsrAbsLong", "JSR $123456.L\ntarget:\n", 6},
+		{"PeaDisp", "PEA (16,A0)\ntarget:\n", 4},
+		{"ClrAbsShort", "CLR.W $1234.W\ntarget:\n", 4},
+		{"JmpIndex", "JMP (8,A0,D1.W)\ntarget:\n", 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prog, err := asm.Parse(strings.NewReader(tt.src))
+			if err != nil {
+				t.Fatalf("parse failed: %v", err)
+			}
+			if got := int(prog.Labels["target"] - prog.Origin); got != tt.want {
+				t.Fatalf("unexpected target address: got %d want %d", got, tt.want)
+			}
+			out, err := asm.Assemble(prog)
+			if err != nil {
+				t.Fatalf("assemble failed: %v", err)
+			}
+			if len(out) != tt.want {
+				t.Fatalf("unexpected size: got %d want %d", len(out), tt.want)
+			}
+		})
+	}
+}
+
 func TestImmediateToEAExtensionWordOrder(t *testing.T) {
 	tests := []struct {
 		name string
@@ -275,11 +313,13 @@ func TestAddSubQuickAndMoveQValidation(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
+			// Immediates out of range fail the parse; other operand
+			// checks fail the assembly.
 			prog, err := asm.Parse(strings.NewReader(tt.src))
-			if err != nil {
-				t.Fatalf("parse failed: %v", err)
+			if err == nil {
+				_, err = asm.Assemble(prog)
 			}
-			if _, err := asm.Assemble(prog); err == nil {
+			if err == nil {
 				t.Fatalf("expected error but assembly succeeded")
 			} else if !strings.Contains(err.Error(), tt.wantErr) {
 				t.Fatalf("unexpected error: %v", err)
@@ -310,11 +350,13 @@ func TestNewInstructionValidation(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
+			// Immediates out of range fail the parse; other operand
+			// checks fail the assembly.
 			prog, err := asm.Parse(strings.NewReader(tt.src))
-			if err != nil {
-				t.Fatalf("parse failed: %v", err)
+			if err == nil {
+				_, err = asm.Assemble(prog)
 			}
-			if _, err := asm.Assemble(prog); err == nil {
+			if err == nil {
 				t.Fatalf("expected error but assembly succeeded")
 			} else if !strings.Contains(err.Error(), tt.wantErr) {
 				t.Fatalf("unexpected error: %v", err)
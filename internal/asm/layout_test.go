@@ -0,0 +1,120 @@
+package asm_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/jenska/m68kasm/internal/asm"
+)
+
+func TestLayoutPasses(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want []byte
+	}{
+		{
+			name: "QuickFormForLaterConstant",
+			src:  "ADD.W #N,(A0)\nSUB.L #N,(A1)\nN EQU 2\n",
+			want: []byte{0x54, 0x50, 0x55, 0x91},
+		},
+		{
+			name: "AbsoluteWordForLaterConstant",
+			src:  "MOVE.W fwd,D0\nfwd EQU 4\n",
+			want: []byte{0x30, 0x38, 0x00, 0x04},
+		},
+		{
+			name: "AbsoluteLongForFarLabel",
+			src:  "JMP fwd\n.ALIGN 32768\nfwd: NOP\n",
+			want: append(append([]byte{0x4E, 0xF9, 0x00, 0x00, 0x80, 0x00}, make([]byte, 32762)...), 0x4E, 0x71),
+		},
+		{
+			name: "AbsoluteWordForHighAddress",
+			src:  "io EQU $FFFF8240\nMOVE.W D0,io\nCLR.B -2\n",
+			want: []byte{0x31, 0xC0, 0x82, 0x40, 0x42, 0x38, 0xFF, 0xFE},
+		},
+		{
+			// The jump shrinks, which drops the conditional word, which
+			// brings the target next to the jump, which needs a .W branch.
+			name: "ConditionalOnLaterLabel",
+			src:  "start: JRA fin\n.if fin-start > 4\nDC.W 1\n.endif\nfin: NOP\n",
+			want: []byte{0x60, 0x00, 0x00, 0x02, 0x4E, 0x71},
+		},
+		{
+			// The jumps shrink in the second pass, which drops the block in
+			// the third; the decisions of the block stay with it instead of
+			// passing to the statements after it.
+			name: "ConditionalBlockDropped",
+			src:  "start: JRA fin\nJRA fin\n.if fin-start > 8\nADD.W #N,(A0)\n.endif\nfin: JMP fwd\nADD.W #2,(A1)\nN EQU 100\nfwd EQU 4\n",
+			want: []byte{0x60, 0x04, 0x60, 0x00, 0x00, 0x02, 0x4E, 0xF8, 0x00, 0x04, 0x54, 0x51},
+		},
+		{
+			// The second pass checks DC.B against the address of fin before
+			// the jump shrank.
+			name: "ValueInRangeOnceSettled",
+			src:  "start: JRA fin\nDCB.B 250,0\nfin: DC.B fin-start\n",
+			want: append(append([]byte{0x60, 0x00, 0x00, 0xFC}, make([]byte, 250)...), 0xFE),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := assembleSource(t, tt.src)
+			if !bytes.Equal(got, tt.want) {
+				t.Fatalf("unexpected encoding: got %x want %x", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLayoutPhaseError(t *testing.T) {
+	_, err := asm.Parse(strings.NewReader("start: DS.B 2-(end-start)\nend:\n"))
+	if err == nil || !strings.Contains(err.Error(), "phase error: end does not settle") {
+		t.Fatalf("expected phase error, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "line 2") {
+		t.Fatalf("expected the error at the definition of end, got %v", err)
+	}
+}
+
+func TestLayoutErrorsOnceSettled(t *testing.T) {
+	// The first strict pass sees fin-start before the jump shrinks; the
+	// errors reported are those of the pass on the settled layout.
+	src := "start: JRA fin\nDCB.B 300,0\nMOVE.W D0\nfin: DC.B fin-start\n"
+	_, err := asm.Parse(strings.NewReader(src))
+	var list *asm.ErrorList
+	if !errors.As(err, &list) || len(list.Errors) != 2 {
+		t.Fatalf("expected two errors, got %v", err)
+	}
+	if !strings.Contains(list.Errors[1].Error(), ".byte value out of 8-bit range: 304") {
+		t.Fatalf("expected the range error on the settled layout, got %v", list.Errors[1])
+	}
+}
+
+func TestLayoutPassesPrintMessagesOnce(t *testing.T) {
+	var messages bytes.Buffer
+	src := "start: JRA fin\nDCB.B 250,0\nfin: NOP\nINFORM 0,\"fin at %d\",fin\n"
+	if _, err := asm.ParseWithOptions(strings.NewReader(src), asm.ParseOptions{Messages: &messages}); err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if got, want := messages.String(), "line 4: fin at 254\n"; got != want {
+		t.Fatalf("messages = %q, want %q", got, want)
+	}
+}
+
+func TestLongAbsolute(t *testing.T) {
+	src := "MOVE.W fwd,D0\nMOVE.W $100,D1\nMOVE.W $200.w,D2\nfwd EQU 4\n"
+	prog, err := asm.ParseWithOptions(strings.NewReader(src), asm.ParseOptions{LongAbsolute: true})
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	got, err := asm.Assemble(prog)
+	if err != nil {
+		t.Fatalf("assemble failed: %v", err)
+	}
+	want := []byte{0x30, 0x39, 0x00, 0x00, 0x00, 0x04, 0x32, 0x39, 0x00, 0x00, 0x01, 0x00, 0x34, 0x38, 0x02, 0x00}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("unexpected encoding: got %x want %x", got, want)
+	}
+}
@@ -45,6 +45,11 @@ const (
 	TILDE
 	DOLLAR
 	NEWLINE
+	AT       // @ (gas MIT addressing)
+	QUESTION // ? (conditional expression)
+	// MACROEND marks the end of a macro expansion in the token stream; it is
+	// produced by the parser, never by the lexer.
+	MACROEND
 )
 
 type (
@@ -53,17 +58,43 @@ type (
 		Text string
 		Val  int64
 		Line int
-		Col  int
+		// Col is the column of the last character of the token, start that
+		// of the first; tokens made up by the parser have no start.
+		Col   int
+		start int
+		// Raw is the source text of a string literal, quotes included, so
+		// macro expansion can substitute parameters inside it.
+		Raw string
+		// origin is set on tokens produced by macro expansion, whose Line
+		// and Col are those of the invocation.
+		origin *macroOrigin
 	}
 
 	Lexer struct {
-		r    *bufio.Reader
-		line int
-		col  int
-		peek *Token
+		r      *bufio.Reader
+		line   int
+		col    int
+		peek   *Token
+		syntax Syntax
+		// lineStart is true until the first token of the current line.
+		lineStart bool
+		// startCol is the column of the first character of the last token.
+		startCol int
+		// generatedNames lets identifiers continue with the '$' of the
+		// names macro expansions generate, in expanded macro text and in
+		// preprocessed output, which starts with a line marker.
+		generatedNames bool
 	}
 )
 
+// firstCol returns the column of the first character of t.
+func (t Token) firstCol() int {
+	if t.start > 0 {
+		return t.start
+	}
+	return t.Col
+}
+
 func (t *Token) String() string {
 	return fmt.Sprintf("(%d, %d) token '%s'", t.Line, t.Col, t.Text)
 }
@@ -134,12 +165,24 @@ func (k Kind) String() string {
 		return "tilde"
 	case NEWLINE:
 		return "newline"
+	case AT:
+		return "at"
+	case QUESTION:
+		return "question mark"
+	case MACROEND:
+		return "end of macro"
 	default:
 		return fmt.Sprintf("Kind(%d)", int(k))
 	}
 }
 
-func NewLexer(r io.Reader) *Lexer { return &Lexer{r: bufio.NewReader(r), line: 1, col: 0} }
+func NewLexer(r io.Reader) *Lexer { return NewLexerWithSyntax(r, SyntaxMotorola) }
+
+// NewLexerWithSyntax returns a lexer that applies the lexical conventions of
+// the given source dialect (comment characters, register prefixes, ...).
+func NewLexerWithSyntax(r io.Reader, syntax Syntax) *Lexer {
+	return &Lexer{r: bufio.NewReader(r), line: 1, col: 0, syntax: syntax, lineStart: true}
+}
 
 func (lx *Lexer) Next() Token {
 	if lx.peek != nil {
@@ -160,8 +203,15 @@ func (lx *Lexer) Peek() Token {
 }
 
 func (lx *Lexer) next() Token {
+	t := lx.scan()
+	lx.lineStart = t.Kind == NEWLINE
+	return t
+}
+
+func (lx *Lexer) scan() Token {
 	for {
 		ch := lx.read()
+		lx.startCol = lx.col
 		if ch == eof {
 			return lx.tok(EOF, "", 0)
 		}
@@ -174,18 +224,29 @@ func (lx *Lexer) next() Token {
 		if ch == '\n' {
 			return lx.tok(NEWLINE, "\n", 0)
 		}
-		if ch == ';' {
+		if ch == '#' && lx.lineStart && lx.peekByte(0) == ' ' && lx.peekByte(1) >= '0' && lx.peekByte(1) <= '9' {
+			lx.lineMarker()
+			return lx.tok(NEWLINE, "\n", 0)
+		}
+		if ch == ';' || lx.isDialectComment(ch) {
 			lx.skipUntilNewline()
 			return lx.tok(NEWLINE, "\n", 0)
 		}
 		if unicode.IsSpace(ch) {
 			continue
 		}
+		if lx.syntax == SyntaxGAS {
+			if tok, ok := lx.scanGAS(ch); ok {
+				return tok
+			}
+		}
 		switch ch {
 		case ',':
 			return lx.tok(COMMA, ",", 0)
 		case ':':
 			return lx.tok(COLON, ":", 0)
+		case '?':
+			return lx.tok(QUESTION, "?", 0)
 		case '=':
 			if lx.peekRune() == '=' {
 				lx.read()
@@ -255,7 +316,21 @@ func (lx *Lexer) next() Token {
 		case '"':
 			return lx.scanString()
 		case '\'':
+			if lx.quotedStrings() {
+				return lx.scanQuoted('\'')
+			}
 			return lx.scanChar()
+		case '\\':
+			return lx.scanMacroParam()
+		case '@':
+			if lx.syntax == SyntaxASM68K {
+				if next := lx.peekRune(); unicode.IsLetter(next) || next == '_' {
+					return lx.scanIdent(ch)
+				} else if !isOctal(next) {
+					return lx.tok(AT, "@", 0)
+				}
+			}
+			return lx.scanNumber(ch)
 		case '$':
 			if isHex(lx.peekRune()) {
 				return lx.scanNumber('$')
@@ -266,7 +341,7 @@ func (lx *Lexer) next() Token {
 			if isIdentStart(ch) {
 				return lx.scanIdent(ch)
 			}
-			if unicode.IsDigit(ch) || ch == '%' || ch == '@' {
+			if unicode.IsDigit(ch) || ch == '%' {
 				return lx.scanNumber(ch)
 			}
 			return lx.errToken(fmt.Errorf("unexpected char: %q", ch))
@@ -275,20 +350,53 @@ func (lx *Lexer) next() Token {
 }
 
 func (lx *Lexer) scanIdent(first rune) Token {
+	return lx.scanIdentFrom(string(first))
+}
+
+// scanIdentFrom continues an identifier that starts with prefix. A macro
+// unique-label marker \@ is kept as part of the identifier ("loop\@").
+func (lx *Lexer) scanIdentFrom(prefix string) Token {
 	var b strings.Builder
-	b.WriteRune(first)
+	b.WriteString(prefix)
 	for {
 		ch := lx.peekRune()
-		if isIdentContinue(ch) {
+		if isIdentContinue(ch) || (ch == generatedMark && lx.generatedNames) {
 			lx.read()
 			b.WriteRune(ch)
 			continue
 		}
+		if ch == ':' && lx.peekByte(1) == ':' && isIdentStart(rune(lx.peekByte(2))) {
+			// A namespace-qualified name ("gfx::init").
+			b.WriteRune(lx.read())
+			b.WriteRune(lx.read())
+			continue
+		}
+		if ch == '\\' {
+			if ref := lx.macroRefLen(); ref > 0 {
+				for i := 0; i < ref; i++ {
+					b.WriteRune(lx.read())
+				}
+				continue
+			}
+		}
 		break
 	}
 	return lx.tok(IDENT, b.String(), 0)
 }
 
+// macroRefLen returns the length of a macro reference that continues an
+// identifier at the current '\\': \@, \0..\9, \() or the start of \name.
+func (lx *Lexer) macroRefLen() int {
+	switch next := lx.peekByte(1); {
+	case next == '@', next >= '0' && next <= '9', next == '_',
+		next >= 'a' && next <= 'z', next >= 'A' && next <= 'Z':
+		return 2
+	case next == '(' && lx.peekByte(2) == ')':
+		return 3
+	}
+	return 0
+}
+
 func (lx *Lexer) scanNumber(first rune) Token {
 	var b strings.Builder
 	b.WriteRune(first)
@@ -316,14 +424,18 @@ func (lx *Lexer) scanNumber(first rune) Token {
 }
 
 func (lx *Lexer) scanString() Token {
-	var b strings.Builder
+	var b, raw strings.Builder
+	raw.WriteByte('"')
 	for {
 		ch := lx.read()
 		if ch == eof || ch == '\n' || ch == '\r' {
 			return lx.errToken(fmt.Errorf("unterminated string"))
 		}
+		raw.WriteRune(ch)
 		if ch == '\\' {
+			esc := lx.peekRune()
 			b.WriteRune(lx.readEscapedRune())
+			raw.WriteRune(esc)
 			continue
 		}
 		if ch == '"' {
@@ -331,7 +443,55 @@ func (lx *Lexer) scanString() Token {
 		}
 		b.WriteRune(ch)
 	}
-	return lx.tok(STRING, b.String(), 0)
+	tok := lx.tok(STRING, b.String(), 0)
+	tok.Raw = raw.String()
+	return tok
+}
+
+// scanQuoted reads a Devpac-style string delimited by quote, where a doubled
+// quote stands for the quote character itself. Single characters stay numeric
+// so that 'A' keeps working as an immediate value.
+func (lx *Lexer) scanQuoted(quote rune) Token {
+	var b, raw strings.Builder
+	raw.WriteRune(quote)
+	for {
+		ch := lx.read()
+		if ch == eof || ch == '\n' || ch == '\r' {
+			return lx.errToken(fmt.Errorf("unterminated string"))
+		}
+		raw.WriteRune(ch)
+		if ch == quote {
+			if lx.peekRune() != quote {
+				break
+			}
+			raw.WriteRune(lx.read())
+		}
+		b.WriteRune(ch)
+	}
+	text := b.String()
+	if r := []rune(text); len(r) == 1 {
+		return lx.tok(NUMBER, fmt.Sprintf("'%c'", r[0]), int64(r[0]))
+	}
+	tok := lx.tok(STRING, text, 0)
+	tok.Raw = raw.String()
+	return tok
+}
+
+// scanMacroParam reads a macro parameter reference such as \1 or \name, \0
+// for the invocation size, \* for the whole argument list, \# for the
+// argument count, or a \@ unique-label marker. References other than \* and
+// \# may continue as an identifier ("\1_end").
+func (lx *Lexer) scanMacroParam() Token {
+	ch := lx.peekRune()
+	if ch == '@' || ch == '_' || unicode.IsLetter(ch) || unicode.IsDigit(ch) {
+		lx.read()
+		return lx.scanIdentFrom("\\" + string(ch))
+	}
+	if ch != '*' && ch != '#' {
+		return lx.errToken(fmt.Errorf("unexpected char after '\\': %q", ch))
+	}
+	lx.read()
+	return lx.tok(IDENT, "\\"+string(ch), 0)
 }
 
 func (lx *Lexer) scanChar() Token {
@@ -390,6 +550,22 @@ func (lx *Lexer) readEscapedRune() rune {
 	}
 }
 
+// lineMarker reads the rest of a "# line file" marker, as written by
+// Preprocess and the C preprocessor, and numbers the following line
+// accordingly.
+func (lx *Lexer) lineMarker() {
+	var b strings.Builder
+	b.WriteByte('#')
+	for ch := lx.peekRune(); ch != eof && ch != '\n' && ch != '\r'; ch = lx.peekRune() {
+		b.WriteRune(lx.read())
+	}
+	if n, ok := lineMarker(b.String()); ok {
+		// The newline that ends the marker advances to line n.
+		lx.line = n - 1
+		lx.generatedNames = true
+	}
+}
+
 func (lx *Lexer) skipUntilNewline() {
 	for {
 		ch := lx.peekRune()
@@ -416,6 +592,15 @@ func (lx *Lexer) read() rune {
 	return ch
 }
 
+// peekByte returns the byte n positions ahead without consuming input.
+func (lx *Lexer) peekByte(n int) byte {
+	buf, err := lx.r.Peek(n + 1)
+	if err != nil {
+		return 0
+	}
+	return buf[n]
+}
+
 func (lx *Lexer) peekRune() rune {
 	ch, _, err := lx.r.ReadRune()
 	if err != nil {
@@ -426,7 +611,7 @@ func (lx *Lexer) peekRune() rune {
 }
 
 func (lx *Lexer) tok(k Kind, text string, val int64) Token {
-	return Token{Kind: k, Text: text, Val: val, Line: lx.line, Col: lx.col}
+	return Token{Kind: k, Text: text, Val: val, Line: lx.line, Col: lx.col, start: lx.startCol}
 }
 
 func (lx *Lexer) errToken(err error) Token {
@@ -63,3 +63,151 @@ DOUBLE 1
 		t.Fatalf("unexpected output: got %x want %x", out, want)
 	}
 }
+
+func TestMacroUniqueLabels(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want []byte
+	}{
+		{
+			"UniqueSuffix",
+			".macro WAIT n\nMOVEQ #n,D0\nwait\\@: DBF D0,wait\\@\n.endmacro\nWAIT 1\nWAIT 2\n",
+			[]byte{0x70, 0x01, 0x51, 0xC8, 0xFF, 0xFE, 0x70, 0x02, 0x51, 0xC8, 0xFF, 0xFE},
+		},
+		{
+			"LocalDeclaration",
+			".macro SKIP\n.local over, done\nBRA.S over\nNOP\nover: BRA.S done\ndone:\n.endmacro\nSKIP\nSKIP\n",
+			[]byte{0x60, 0x02, 0x4E, 0x71, 0x60, 0x00, 0x60, 0x02, 0x4E, 0x71, 0x60, 0x00},
+		},
+		{
+			"UniqueSuffixBesideSourceLabels",
+			".macro WAIT\nwait\\@: BRA.S wait_001\n\\@: BRA.S __local_001\n.endmacro\nwait_001: NOP\n__local_001: NOP\nWAIT\n",
+			[]byte{0x4E, 0x71, 0x4E, 0x71, 0x60, 0xFA, 0x60, 0xFA},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := assembleSource(t, tt.src)
+			if !bytes.Equal(got, tt.want) {
+				t.Fatalf("unexpected output: got %x want %x", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMacroUniqueLabelNamesInResults(t *testing.T) {
+	prog, err := asm.Parse(strings.NewReader(".macro M\n.local here\nhere: NOP\nx\\@: NOP\n.endmacro\nM\nM\n"))
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	var names []string
+	for _, l := range prog.DefinedLabels {
+		names = append(names, l.Name)
+	}
+	if got, want := strings.Join(names, ","), "here_$001,x_$001,here_$002,x_$002"; got != want {
+		t.Fatalf("unexpected labels %s, want %s", got, want)
+	}
+
+	_, err = asm.Parse(strings.NewReader(".macro M\nDC.W missing\\@\n.endmacro\nM\n"))
+	if err == nil || !strings.Contains(err.Error(), "missing_$001") {
+		t.Fatalf("expected error naming missing_$001, got %v", err)
+	}
+
+	if _, err = asm.Parse(strings.NewReader("x_$001: NOP\n")); err == nil {
+		t.Fatalf("expected a generated name in the source to fail")
+	}
+
+	_, err = asm.Parse(strings.NewReader(".local x\n"))
+	if err == nil || !strings.Contains(err.Error(), "only allowed inside a macro") {
+		t.Fatalf("expected .local error, got %v", err)
+	}
+}
+
+func TestMacroParameterBinding(t *testing.T) {
+	const pushMacro = ".macro PUSH reg=D0, n=1\nMOVE.L reg,-(SP)\n.byte n, 0\n.endmacro\n"
+	tests := []struct {
+		name string
+		src  string
+		want []byte
+	}{
+		{"Defaults", pushMacro + "PUSH\n", []byte{0x2F, 0x00, 0x01, 0x00}},
+		{"Positional", pushMacro + "PUSH A1,2\n", []byte{0x2F, 0x09, 0x02, 0x00}},
+		{"Keyword", pushMacro + "PUSH n=3\n", []byte{0x2F, 0x00, 0x03, 0x00}},
+		{"EmptyUsesDefault", pushMacro + "PUSH ,4\n", []byte{0x2F, 0x00, 0x04, 0x00}},
+		{
+			"Vararg",
+			".macro LIST first, rest:vararg\n.byte first\n.if NARG > 1\nLIST rest\n.endif\n.endmacro\nLIST 1,2,3\n",
+			[]byte{0x01, 0x02, 0x03},
+		},
+		{"ArgumentCount", ".macro COUNT\n.byte \\#, NARG\n.endmacro\nCOUNT a,b,c\n", []byte{0x03, 0x03}},
+		{
+			"ExitM",
+			".macro POS v\n.if v == 0\n.exitm\n.endif\n.byte v\n.endmacro\nPOS 0\nPOS 5\n.byte 9\n",
+			[]byte{0x05, 0x09},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := assembleSource(t, tt.src)
+			if !bytes.Equal(got, tt.want) {
+				t.Fatalf("unexpected output: got %x want %x", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMacroParameterErrors(t *testing.T) {
+	const one = ".macro ONE a\n.byte a\n.endmacro\n"
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"Missing", one + "ONE\n", "missing argument a"},
+		{"TooMany", one + "ONE 1,2\n", "expects 1 args, got 2"},
+		{"UnknownKeyword", one + "ONE b=1\n", "has no parameter b"},
+		{"Duplicate", one + "ONE 1,a=2\n", "given twice"},
+		{"VarargNotLast", ".macro BAD a:vararg, b\n.endmacro\n", "vararg parameter must be last"},
+		{"ExitOutsideMacro", ".exitm\n", "outside of a macro"},
+		{"Recursion", ".macro R\nR\n.endmacro\nR\n", "macro expansion depth exceeded"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := asm.Parse(strings.NewReader(tt.src))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestMacroTextSubstitution(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want []byte
+	}{
+		{"SizeSuffix", ".macro CLR2 r\nCLR.\\0 r\n.endmacro\nCLR2.L D1\nCLR2 D2\n", []byte{0x42, 0x81, 0x42, 0x42}},
+		{"Concatenation", ".macro DEF name\n.byte 1\nname\\()_end:\n.byte name\\()_end\n.endmacro\nDEF foo\n", []byte{0x01, 0x01}},
+		{"GasStyleReference", ".macro NEG1 \\reg\nNEG.W \\reg\n.endmacro\nNEG1 D3\n", []byte{0x44, 0x43}},
+		{"Stringify", ".macro NAME s\n.byte \"\\s\", 0\n.endmacro\nNAME hi\n", []byte{'h', 'i', 0}},
+		{"EscapesSurvive", ".macro S a\n.byte \"\\1\\n\\0\"\n.endmacro\nS x\n", []byte{'x', '\n', 0}},
+		{"EscapeNamedLikeParameter", ".macro S n, t\n.byte \"a\\n\\t\\\\n\", 0\n.endmacro\nS 5, 6\n", []byte{'a', '\n', '\t', '\\', 'n', 0}},
+		{"BracedReference", ".macro S n\n.byte \"\\{n}\\n\\{1}\"\n.endmacro\nS 5\n", []byte{'5', '\n', '5'}},
+		{"StringifyQuotes", ".macro S\n.byte \"\\1\"\n.endmacro\nS \"q\\\\\"\n", []byte{'"', 'q', '\\', '\\', '"'}},
+		{
+			"StringFunctions",
+			".macro ISADDR reg\n.if INSTR(\"\\reg\", \"a\") == 1\n.byte STRLEN(\"\\reg\")\n.else\n.byte 0\n.endif\n.endmacro\nISADDR a0\nISADDR d0\n",
+			[]byte{0x02, 0x00},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := assembleSource(t, tt.src)
+			if !bytes.Equal(got, tt.want) {
+				t.Fatalf("unexpected output: got %x want %x", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package asm
+
+import (
+	"fmt"
+	"strings"
+)
+
+// namespaceFrame is an open .namespace block.
+type namespaceFrame struct {
+	name string
+	line int
+}
+
+// .namespace name (or .module name) prefixes the symbols defined up to the
+// matching .endnamespace with "name::". Blocks nest.
+func parseNAMESPACE(p *Parser) error {
+	nameTok, err := p.want(IDENT)
+	if err != nil {
+		return err
+	}
+	p.namespaces = append(p.namespaces, namespaceFrame{name: nameTok.Text, line: nameTok.Line})
+	return nil
+}
+
+func parseENDNAMESPACE(p *Parser) error {
+	if len(p.namespaces) == 0 {
+		return contextualizeAt(p.line, p.col, withCode(CodeUnbalancedBlock, fmt.Errorf(".endnamespace without .namespace")))
+	}
+	p.namespaces = p.namespaces[:len(p.namespaces)-1]
+	return nil
+}
+
+func (p *Parser) ensureNamespacesClosed() error {
+	if len(p.namespaces) == 0 {
+		return nil
+	}
+	ns := p.namespaces[len(p.namespaces)-1]
+	return errorAtLine(ns.line, withCode(CodeUnbalancedBlock, fmt.Errorf(".namespace %s without matching .endnamespace", ns.name)))
+}
+
+// namespacePrefix returns the "outer::inner::" prefix of the first n open
+// namespaces.
+func (p *Parser) namespacePrefix(n int) string {
+	var b strings.Builder
+	for _, ns := range p.namespaces[:n] {
+		b.WriteString(ns.name)
+		b.WriteString("::")
+	}
+	return b.String()
+}
+
+// namespaced returns the full name of a symbol defined as name. Names that
+// are already qualified ("gfx::init", or local labels of a namespaced label)
+// are kept.
+func (p *Parser) namespaced(name string) string {
+	if len(p.namespaces) == 0 || strings.Contains(name, "::") {
+		return name
+	}
+	return p.namespacePrefix(len(p.namespaces)) + name
+}
+
+// resolveSymbol returns the symbol a reference to name means. Local labels
+// are qualified with their scope; other names are looked up in the open
+// namespaces, innermost first, and then globally. Symbols defined further
+// down are known from the previous pass. The symbol counts as used.
+func (p *Parser) resolveSymbol(name string) string {
+	full := p.lookupSymbol(name)
+	p.used[full] = true
+	return full
+}
+
+func (p *Parser) lookupSymbol(name string) string {
+	if isLocalName(name) {
+		return p.qualifyLocal(name)
+	}
+	if len(p.namespaces) == 0 {
+		return name
+	}
+	l := symbolLookup{name: name}
+	for n := len(p.namespaces); n > 0; n-- {
+		l.candidates = append(l.candidates, p.namespacePrefix(n)+name)
+	}
+	l.result = l.in(p.labels)
+	p.lookups = append(p.lookups, l)
+	return l.result
+}
+
+// symbolLookup is a name looked up in open namespaces: the full names tried,
+// innermost first, and the symbol the name turned out to mean.
+type symbolLookup struct {
+	name       string
+	candidates []string
+	result     string
+}
+
+func (l symbolLookup) in(labels map[string]uint32) string {
+	for _, full := range l.candidates {
+		if _, ok := labels[full]; ok {
+			return full
+		}
+	}
+	return l.name
+}
+
+// checkLookups marks the pass stale if a name the front end looked up in
+// open namespaces means another symbol on its layout, as a namespaced symbol
+// came or went with a conditional block.
+func (p *Parser) checkLookups(lookups []symbolLookup) {
+	for _, l := range lookups {
+		if l.in(p.labels) != l.result {
+			p.stale = true
+			return
+		}
+	}
+}
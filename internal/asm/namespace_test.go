@@ -0,0 +1,68 @@
+package asm_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/jenska/m68kasm/internal/asm"
+)
+
+func TestNamespaces(t *testing.T) {
+	tests := []struct {
+		name   string
+		src    string
+		syntax asm.Syntax
+		want   []byte
+	}{
+		{"CurrentNamespaceFirst", "n EQU 1\n.namespace gfx\nn EQU 2\n.byte n\n.endnamespace\n.byte n,gfx::n\n", asm.SyntaxMotorola, []byte{2, 1, 2}},
+		{"FallsBackToGlobal", "n EQU 1\n.namespace gfx\n.byte n\n.endnamespace\n", asm.SyntaxMotorola, []byte{1}},
+		{"ForwardReference", ".namespace gfx\nBRA.S init\nNOP\ninit: RTS\n.endnamespace\ninit: NOP\n", asm.SyntaxMotorola, []byte{0x60, 0x02, 0x4E, 0x71, 0x4E, 0x75, 0x4E, 0x71}},
+		{"Nested", ".namespace a\n.namespace b\nn EQU 3\n.endnamespace\n.byte b::n\n.endnamespace\n.byte a::b::n\n", asm.SyntaxMotorola, []byte{3, 3}},
+		{"QualifiedLocal", ".module gfx\ninit:\n.loop: NOP\n.endmodule\nBRA.S gfx::init.loop\n", asm.SyntaxMotorola, []byte{0x4E, 0x71, 0x60, 0xFC}},
+		{"Devpac", "\tnamespace gfx\ninit\tmoveq #0,d0\n\tendnamespace\n\tbsr.s gfx::init\n", asm.SyntaxDevpac, []byte{0x70, 0x00, 0x61, 0xFC}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := assembleWithSyntax(t, tt.src, tt.syntax)
+			if !bytes.Equal(got, tt.want) {
+				t.Fatalf("unexpected encoding for %q: got %x want %x", tt.src, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNamespaceDefinedLabels(t *testing.T) {
+	prog, err := asm.Parse(strings.NewReader("init: NOP\n.namespace gfx\ninit: NOP\nsize EQU 4\n.endnamespace\n"))
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	var names []string
+	for _, l := range prog.DefinedLabels {
+		names = append(names, l.Name)
+	}
+	if got, want := strings.Join(names, " "), "init gfx::init gfx::size"; got != want {
+		t.Fatalf("unexpected symbols: got %q want %q", got, want)
+	}
+}
+
+func TestNamespaceErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"Unterminated", ".namespace gfx\nNOP\n", ".namespace gfx without matching .endnamespace"},
+		{"StrayEnd", ".endnamespace\n", ".endnamespace without .namespace"},
+		{"Duplicate", ".namespace gfx\ninit: NOP\n.endnamespace\ngfx::init: NOP\n", "gfx::init already defined"},
+		{"NotVisibleOutside", ".namespace gfx\nn EQU 1\n.endnamespace\n.byte n\n", "undefined label in expression: n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := asm.Parse(strings.NewReader(tt.src))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
@@ -2,10 +2,14 @@ package asm
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
+	"maps"
 	"math"
 	"os"
+	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/jenska/m68kasm/internal/asm/instructions"
@@ -27,6 +31,8 @@ type (
 		Line    int
 		Col     int
 		Section SectionKind
+		// Exprs are the values in Bytes that refer to symbols.
+		Exprs []DataExpr
 	}
 
 	Parser struct {
@@ -39,24 +45,130 @@ type (
 		allowForwardRefs bool
 		macros           map[string]macroDef
 		instrs           *instructions.Table
-		pc               uint32
-		origin           uint32
-		hasOrg           bool
-		section          SectionKind
-		items            []any
-		line             int
-		col              int
-
-		macroDepth int
+		syntax           Syntax
+		globals          map[string]bool
+		// defined holds the symbols defined so far in the current pass; labels
+		// is seeded with the previous pass and cannot answer IFD.
+		defined map[string]bool
+		// scope is the last non-local label, which qualifies ".name" labels.
+		scope      string
+		regAliases map[string]regAlias
+		conds      []condFrame
+		rsCounter  int64
+		// strct is the open .struct block; structs holds the names of the
+		// structures defined so far.
+		strct   *structBlock
+		structs map[string]bool
+		// namespaces are the open .namespace blocks, outermost first.
+		namespaces []namespaceFrame
+		ended      bool
+		// obj is the open OBJ block, which assembles at a logical address
+		// while the bytes stay in sequence.
+		obj         *objBlock
+		includeDirs []string
+		messages    io.Writer
+		// allowTruncation turns off the range checks of values.
+		allowTruncation bool
+		// longAbsolute turns off the .W choice for unsized absolute addresses.
+		longAbsolute bool
+		// pcRelative is set by ParseOptions.PCRelative and ".opt pcrel".
+		pcRelative bool
+		// warnings holds the enabled warning categories and diagnostics the
+		// warnings raised in this pass.
+		warnings    map[Category]bool
+		diagnostics []Diagnostic
+		// used holds the symbols referred to and usedMacros the macros
+		// invoked in this pass; expanded the symbols defined by macro
+		// expansion. They answer the unused warning.
+		used       map[string]bool
+		usedMacros map[string]bool
+		expanded   map[string]bool
+		pc         uint32
+		origin     uint32
+		hasOrg     bool
+		section    SectionKind
+		items      []any
+		line       int
+		col        int
+		// lineOrigin is the macro origin of the last token consumed.
+		lineOrigin *macroOrigin
+		lastKind   Kind
+
+		// pp records the accepted statements for Preprocess.
+		pp *preprocessor
+		// prev is the result of the previous pass, which answers questions
+		// about symbols and sections defined further down.
+		prev *Program
+		// sections holds the address range of each section seen so far.
+		sections [sectionCount]sectionRange
+
+		// macroSerial numbers macro expansions for \@ and .local names.
+		macroSerial int
+
+		// pass counts the passes over the source, starting at 1.
+		pass int
+		// errs collects the errors of this pass.
+		errs []error
+		// layoutChoices records the size decisions of this pass, which the
+		// next pass must not shrink; choiceCounts counts the decisions made
+		// for each statement, which stmtKey names.
+		layoutChoices map[choiceKey]int
+		choiceCounts  map[string]int
+		stmtKey       string
+
+		// stmts are the statements the front end parsed, which every pass
+		// lays out again. A layout pass reads them from front, which also
+		// resolved the names they refer to.
+		stmts []statement
+		front *Parser
+		// fixups complete the operands of the form being parsed in layout;
+		// operand is the position of the operand being parsed.
+		fixups  []operandFixup
+		operand int
+		// lookups are the names the front end resolved in open namespaces.
+		lookups []symbolLookup
+		// stale is set by a layout pass on which a decision of the front end
+		// comes out differently, so that the source has to be parsed again.
+		stale bool
 
 		buf          []Token // N-Token Lookahead
 		tokenScratch []Token // reusable buffer for operand collection
 		formScratch  []Token // reusable buffer for form parsing
 	}
 
+	// regAlias is an EQUR register or REG register list symbol.
+	regAlias struct {
+		tokens []Token
+		line   int
+	}
+
 	macroDef struct {
-		params []string
+		name   string
+		line   int
+		params []macroParam
 		body   []Token
+		// locals are the .local names renamed on every expansion.
+		locals []string
+	}
+
+	macroParam struct {
+		name   string
+		def    []Token // default value ("reg=d0"), used when omitted
+		vararg bool    // collects the remaining arguments ("args:vararg")
+	}
+
+	// macroCall holds the arguments of one macro invocation.
+	macroCall struct {
+		args   [][]Token // as written, for NARG, \# and \*
+		values [][]Token // bound to each parameter; args for parameterless macros
+		size   string    // size suffix of the invocation ("MOVEX.L"), for \0
+		unique string    // \@ suffix of this expansion
+	}
+
+	objBlock struct {
+		physical uint32 // pc where the block started
+		logical  uint32 // address given to OBJ
+		line     int
 	}
 )
 
@@ -75,6 +187,20 @@ func localLabelName(num, idx int) string {
 	return fmt.Sprintf("__local_%d_%d", num, idx)
 }
 
+// qualifyLocal expands a ".name" (or ASM68K "@name") local label to
+// "scope.name", where scope is the last non-local label. The qualified name
+// refers to the label from any scope.
+func (p *Parser) qualifyLocal(name string) string {
+	if isLocalName(name) && p.scope != "" {
+		return p.scope + "." + name[1:]
+	}
+	return name
+}
+
+func isLocalName(name string) bool {
+	return len(name) > 1 && (name[0] == '.' || name[0] == '@')
+}
+
 func (p *Parser) defineLocalLabel(tok Token) error {
 	num := int(tok.Val)
 	if num < 0 {
@@ -83,7 +209,10 @@ func (p *Parser) defineLocalLabel(tok Token) error {
 	idx := p.locals[num] + 1
 	name := localLabelName(num, idx)
 	p.locals[num] = idx
-	p.labels[name] = p.pc
+	p.place(func(p *Parser) error {
+		p.labels[name] = p.pc
+		return nil
+	})
 	return nil
 }
 
@@ -99,7 +228,7 @@ func (p *Parser) resolveLocalLabel(num int, forward bool) (string, error) {
 		return localLabelName(num, idx), nil
 	}
 	if p.locals[num] == 0 {
-		return "", fmt.Errorf("no previous local label %d", num)
+		return "", withCode(CodeUndefinedSymbol, fmt.Errorf("no previous local label %d", num))
 	}
 	return localLabelName(num, p.locals[num]), nil
 }
@@ -107,7 +236,7 @@ func (p *Parser) resolveLocalLabel(num int, forward bool) (string, error) {
 func (p *Parser) ensureLocalForwardsResolved() error {
 	for num, needed := range p.localForwards {
 		if p.locals[num] < needed {
-			return fmt.Errorf("no forward definition for local label %d", num)
+			return withCode(CodeUndefinedSymbol, fmt.Errorf("no forward definition for local label %d", num))
 		}
 	}
 	return nil
@@ -116,6 +245,38 @@ func (p *Parser) ensureLocalForwardsResolved() error {
 type ParseOptions struct {
 	Symbols    map[string]uint32
 	InstrTable *instructions.Table
+	// Syntax selects the source dialect; the zero value is Motorola syntax.
+	Syntax Syntax
+	// IncludeDirs are searched for INCBIN files; ParseFile adds the source
+	// file's directory first.
+	IncludeDirs []string
+	// Messages receives INFORM messages of severity 0; nil discards them.
+	Messages io.Writer
+	// AllowTruncation keeps the low bits of data values, immediates and
+	// displacements that do not fit their field instead of reporting an
+	// error.
+	AllowTruncation bool
+	// LongAbsolute keeps absolute addresses without a size suffix at .L
+	// instead of picking .W for addresses a sign-extended word reaches.
+	LongAbsolute bool
+	// PCRelative turns absolute source operands without a size suffix that
+	// name a .text label into label(PC) where the instruction and the
+	// distance allow it, like ".opt pcrel".
+	PCRelative bool
+	// AuditPIC warns about every absolute reference to a .text label, to
+	// check that code is position independent. It turns on WarnPIC.
+	AuditPIC bool
+	// Warnings turns warning categories on (true) or off (false) by name;
+	// "all" switches every category. Categories not listed keep their
+	// default; see WarningCategories.
+	Warnings map[string]bool
+	// WarningsAsErrors reports warnings as errors, which fails the assembly.
+	WarningsAsErrors bool
+	// MaxErrors is the number of errors reported before the rest are left
+	// out; 0 means DefaultMaxErrors and a negative value no limit. More
+	// than one error comes back as an *ErrorList, marked Limited if some
+	// were left out.
+	MaxErrors int
 }
 
 func Parse(r io.Reader) (*Program, error) {
@@ -127,24 +288,180 @@ func ParseWithOptions(r io.Reader, opts ParseOptions) (*Program, error) {
 	if err != nil {
 		return nil, err
 	}
-	lines := splitSourceLines(src)
+	return parseSource(src, opts, nil)
+}
+
+// maxPasses bounds the number of passes. Sizes only grow from the third pass
+// on, so a source that has not settled by then has a symbol that depends on
+// its own address.
+const maxPasses = 16
+
+// parseSource parses src once into statements and lays them out until the
+// addresses of a pass match those of the pass before. pp, if not nil,
+// records the statements of the final parse.
+func parseSource(src []byte, opts ParseOptions, pp *preprocessor) (*Program, error) {
+	lines := markedSourceLines(splitSourceLines(src))
 
-	table := opts.InstrTable
-	if table == nil {
-		table = instructions.DefaultTable()
+	if opts.InstrTable == nil {
+		opts.InstrTable = instructions.DefaultTable()
 	}
 
-	firstPass, err := parseWithLexer(NewLexer(bytes.NewReader(src)), table, opts.Symbols, true)
-	if err != nil {
-		return nil, withSourceLines(err, lines)
+	tokens := lexSource(src, opts.Syntax)
+	// The front end lays the statements out leniently as it parses them,
+	// which is the first pass. A conditional or a namespace lookup that a
+	// later layout decides differently has the source parsed again on that
+	// layout, which counts as a pass too.
+	record := pp.fresh()
+	fe, prev := parseStatements(&sliceLexer{tokens: tokens}, opts, nil, record)
+	reparse := func(after *Program) *Program {
+		record = pp.fresh()
+		var prog *Program
+		fe, prog = parseStatements(&sliceLexer{tokens: tokens}, opts, after, record)
+		return prog
+	}
+	var before *Program
+	var lastErr error
+	settled := false
+	for prev.pass < maxPasses {
+		// Messages are kept until the pass turns out to be the final one.
+		passOpts, messages := opts, new(bytes.Buffer)
+		if opts.Messages != nil {
+			passOpts.Messages = messages
+		}
+		prog, err := layoutStatements(fe, passOpts, prev, false)
+		if prog.stale {
+			before, prev, lastErr = prev, reparse(prog), nil
+			continue
+		}
+		if err != nil {
+			// Values checked against addresses that are still moving may be
+			// out of range for now. Lenient passes, which skip those checks,
+			// settle the layout once; the errors of the strict pass on the
+			// settled layout stand.
+			if !settled {
+				settled = true
+				moved := false
+				for prev.pass < maxPasses {
+					next, _ := layoutStatements(fe, opts, prev, true)
+					if next.stale {
+						next = reparse(next)
+					} else if next.sameLayout(prev) {
+						break
+					}
+					before, prev, moved = prev, next, true
+				}
+				if moved {
+					lastErr = err
+					continue
+				}
+			}
+			writeMessages(opts.Messages, messages)
+			return nil, reportErrors(withEncodeErrors(err, prog), opts, lines)
+		}
+		if prog.sameLayout(prev) {
+			writeMessages(opts.Messages, messages)
+			for i := range prog.Warnings {
+				prog.Warnings[i].LineText = sourceLine(prog.Warnings[i].Line, lines)
+			}
+			if opts.WarningsAsErrors && len(prog.Warnings) > 0 {
+				return nil, reportErrors(warningErrors(prog), opts, nil)
+			}
+			pp.adopt(record)
+			prog.SourceLines = append([]string(nil), lines...)
+			prog.maxErrors = errorLimit(opts)
+			return prog, nil
+		}
+		before, prev, lastErr = prev, prog, nil
+	}
+	if lastErr != nil {
+		// Errors in the source can keep the layout from settling; they are
+		// the more useful report.
+		return nil, reportErrors(lastErr, opts, lines)
 	}
+	return nil, withSourceLines(phaseError(prev, before), lines)
+}
 
-	prog, err := parseWithLexer(NewLexer(bytes.NewReader(src)), table, firstPass.Labels, false)
-	if err != nil {
-		return nil, withSourceLines(err, lines)
+// reportErrors limits the errors of a pass to opts.MaxErrors and adds their
+// source lines.
+func reportErrors(err error, opts ParseOptions, lines []string) error {
+	if list, ok := err.(*ErrorList); ok {
+		err = joinErrors(list.Errors, errorLimit(opts))
+	}
+	return withSourceLines(err, lines)
+}
+
+// withEncodeErrors adds the errors found by encoding prog, the statements
+// of a failed pass that did parse, to err, so that undefined branch targets
+// and rejected operand combinations are reported along with the errors of
+// the source. The errors are sorted by the line they come from.
+func withEncodeErrors(err error, prog *Program) error {
+	var errs []error
+	if list, ok := err.(*ErrorList); ok {
+		errs = append(errs, list.Errors...)
+	} else {
+		errs = append(errs, err)
+	}
+	parsed := len(errs)
+	for _, it := range prog.Items {
+		if _, encErr := assembleItem(nil, it, prog.Labels); encErr != nil {
+			errs = append(errs, encErr)
+		}
+	}
+	if len(errs) == parsed {
+		return err
+	}
+	slices.SortStableFunc(errs, func(a, b error) int { return sourceLineOf(a) - sourceLineOf(b) })
+	return joinErrors(errs, 0)
+}
+
+// sourceLineOf returns the line of the source an error is reported for: the
+// outermost macro call for errors in an expansion. Errors without a line
+// sort last.
+func sourceLineOf(err error) int {
+	var e *Error
+	if !errors.As(err, &e) {
+		return math.MaxInt
+	}
+	if n := len(e.Expansions); n > 0 {
+		return e.Expansions[n-1].Line
+	}
+	return e.Line
+}
+
+// sameLayout reports whether prog put every symbol and section where prev
+// did.
+func (prog *Program) sameLayout(prev *Program) bool {
+	return maps.Equal(prog.Labels, prev.Labels) && prog.sections == prev.sections
+}
+
+// phaseError names the first symbol whose value still differed between the
+// last two passes.
+func phaseError(prog, before *Program) error {
+	for _, l := range prog.DefinedLabels {
+		if addr, ok := before.Labels[l.Name]; !ok || addr != prog.Labels[l.Name] {
+			return errorAtLine(l.Line, withCode(CodeLayout, fmt.Errorf("phase error: %s does not settle after %d passes", l.Name, prog.pass)))
+		}
+	}
+	return withCode(CodeLayout, fmt.Errorf("phase error: layout does not settle after %d passes", prog.pass))
+}
+
+// lexSource splits src into tokens, ending with the EOF token.
+func lexSource(src []byte, syntax Syntax) []Token {
+	lx := newSyntaxLexer(bytes.NewReader(src), syntax)
+	var tokens []Token
+	for {
+		t := lx.Next()
+		tokens = append(tokens, t)
+		if t.Kind == EOF {
+			return tokens
+		}
+	}
+}
+
+func writeMessages(w io.Writer, messages *bytes.Buffer) {
+	if w != nil {
+		w.Write(messages.Bytes())
 	}
-	prog.SourceLines = append([]string(nil), lines...)
-	return prog, nil
 }
 
 func splitSourceLines(src []byte) []string {
@@ -156,55 +473,201 @@ func splitSourceLines(src []byte) []string {
 	return strings.Split(text, "\n")
 }
 
-func parseWithLexer(lx lexer, table *instructions.Table, symbols map[string]uint32, allowForward bool) (*Program, error) {
+// statement is a statement as the front end parsed it. layout does what the
+// statement does to the layout of a pass: it evaluates the expressions
+// against the addresses of the pass, checks the values and emits the bytes.
+// A statement that did not parse has only its error, reported by every pass.
+type statement struct {
+	line   int
+	col    int
+	origin *macroOrigin
+	// key names the statement for layoutChoice.
+	key    string
+	code   Code
+	layout func(p *Parser) error
+	err    error
+}
+
+// newParser returns the parser of a pass after prev, or of the first pass if
+// prev is nil. A lenient pass lets unknown symbols evaluate to 0 and skips
+// the checks that depend on final addresses.
+func newParser(opts ParseOptions, prev *Program, lenient bool) *Parser {
+	symbols, pass := opts.Symbols, 1
+	if prev != nil {
+		symbols, pass = prev.Labels, prev.pass+1
+	}
 	p := &Parser{
-		lx:               lx,
+		prev:             prev,
+		pass:             pass,
 		labels:           copySymbols(symbols),
 		definedLabelPos:  map[string]int{},
 		locals:           map[int]int{},
 		localForwards:    map[int]int{},
-		allowForwardRefs: allowForward,
+		allowForwardRefs: lenient,
 		macros:           map[string]macroDef{},
-		instrs:           table,
+		instrs:           opts.InstrTable,
+		syntax:           opts.Syntax,
+		globals:          map[string]bool{},
+		defined:          map[string]bool{},
+		regAliases:       map[string]regAlias{},
+		structs:          map[string]bool{},
 		section:          SectionText,
-	}
+		includeDirs:      opts.IncludeDirs,
+		messages:         opts.Messages,
+		allowTruncation:  opts.AllowTruncation,
+		longAbsolute:     opts.LongAbsolute,
+		pcRelative:       opts.PCRelative,
+		warnings:         enabledWarnings(opts),
+		used:             map[string]bool{},
+		usedMacros:       map[string]bool{},
+		expanded:         map[string]bool{},
+		layoutChoices:    map[choiceKey]int{},
+		choiceCounts:     map[string]int{},
+	}
+	for name := range opts.Symbols {
+		p.defined[name] = true
+	}
+	return p
+}
+
+// parseStatements is the front end: it parses the tokens into statements,
+// expanding macros and resolving conditionals on the way. prev is the layout
+// the conditionals are decided on, or nil at first. The front end lays each
+// statement out as soon as it is parsed, leniently, so that the statements
+// after it see its symbols; that layout is the program it returns.
+func parseStatements(lx lexer, opts ParseOptions, prev *Program, pp *preprocessor) (*Parser, *Program) {
+	p := newParser(opts, prev, true)
+	p.lx, p.pp = lx, pp
 	for {
 		t := p.peek()
 		if t.Kind == EOF {
+			if t.Text != "" {
+				// The lexer reports malformed input as an EOF token.
+				p.stmts = append(p.stmts, statement{err: errorAtToken(t, errors.New(t.Text))})
+			}
 			break
 		}
-		if t.Kind == NEWLINE {
+		if t.Kind == NEWLINE || t.Kind == MACROEND {
 			p.next()
 			continue
 		}
+		if p.ended {
+			p.releaseTokens(p.consumeUntilEOL())
+			continue
+		}
+
+		p.pp.begin()
+		p.stmtKey = statementKey(t)
+		handled, err := p.parseConditional()
+		if err != nil {
+			p.skipStatement(err)
+			continue
+		}
+		if !handled && p.skipping() {
+			p.releaseTokens(p.consumeUntilEOL())
+			continue
+		}
+		if handled {
+			if err := p.expectEndOfStatement(); err != nil {
+				p.skipStatement(err)
+				continue
+			}
+			continue
+		}
+
+		if p.strct != nil {
+			member, err := p.parseStructMember()
+			if err != nil {
+				p.skipStatement(err)
+				continue
+			}
+			if member {
+				if err := p.expectEndOfStatement(); err != nil {
+					p.skipStatement(err)
+					continue
+				}
+				p.pp.emit(false)
+				continue
+			}
+		}
 
 		// Try parsing a label definition
 		didLabel, err := p.parseLabelDefinition()
 		if err != nil {
-			return nil, err
+			p.skipStatement(err)
+			continue
 		}
+		p.pp.markLabel()
 		if didLabel && (p.peek().Kind == NEWLINE || p.peek().Kind == EOF) {
+			p.pp.emit(false)
 			continue
 		}
 
 		expanded, err := p.parseStmt()
 		if err != nil {
-			return nil, err
+			p.skipStatement(err)
+			continue
 		}
 		if expanded {
+			p.pp.emit(true)
 			continue
 		}
 
-		if t := p.peek(); t.Kind != NEWLINE && t.Kind != EOF {
-			return nil, errorAtToken(t, fmt.Errorf("unexpected token: %s", t.Text))
+		if err := p.expectEndOfStatement(); err != nil {
+			p.skipStatement(err)
+			continue
 		}
-		if p.peek().Kind == NEWLINE {
-			p.next()
+		p.pp.emit(false)
+	}
+
+	for _, check := range []func() error{p.ensureConditionalsClosed, p.ensureStructClosed, p.ensureNamespacesClosed, p.ensureLocalForwardsResolved} {
+		if err := check(); err != nil {
+			p.stmts = append(p.stmts, statement{err: err})
 		}
 	}
+	prog, _ := p.finish()
+	return p, prog
+}
 
-	if err := p.ensureLocalForwardsResolved(); err != nil {
-		return nil, err
+// place adds a statement with the given layout at the current position. The
+// front end runs the layout right away and leaves its errors to the passes.
+func (p *Parser) place(layout func(p *Parser) error) {
+	p.stmts = append(p.stmts, statement{line: p.line, col: p.col, origin: p.lineOrigin, key: p.stmtKey, layout: layout})
+	layout(p)
+}
+
+// layoutStatements runs a pass over the statements of the front end fe,
+// after prev. The program comes back along with the errors, so that its
+// layout can still be compared.
+func layoutStatements(fe *Parser, opts ParseOptions, prev *Program, lenient bool) (*Program, error) {
+	p := newParser(opts, prev, lenient)
+	// The names were resolved by the front end, which also knows the
+	// macros and the exported symbols.
+	p.front = fe
+	p.used, p.globals, p.macros, p.usedMacros = fe.used, fe.globals, fe.macros, fe.usedMacros
+	for _, s := range fe.stmts {
+		if s.err != nil {
+			p.errs = append(p.errs, s.err)
+			continue
+		}
+		p.line, p.col, p.lineOrigin, p.stmtKey = s.line, s.col, s.origin, s.key
+		if err := s.layout(p); err != nil {
+			if s.code != "" {
+				err = withCode(s.code, err)
+			}
+			p.errs = append(p.errs, p.statementError(err))
+		}
+	}
+	return p.finish()
+}
+
+// finish ends the pass and returns its program along with its errors.
+func (p *Parser) finish() (*Program, error) {
+	if p.obj != nil {
+		p.errs = append(p.errs, errorAtLine(p.obj.line, withCode(CodeUnbalancedBlock, fmt.Errorf("OBJ without matching OBJEND"))))
+	}
+	if p.front != nil {
+		p.checkLookups(p.front.lookups)
 	}
 
 	origin := p.origin
@@ -213,7 +676,85 @@ func parseWithLexer(lx lexer, table *instructions.Table, symbols map[string]uint
 	}
 
 	definedLabels := append([]DefinedLabel(nil), p.definedLabels...)
-	return &Program{Items: p.items, Labels: p.labels, DefinedLabels: definedLabels, Origin: origin}, nil
+	for i := range definedLabels {
+		definedLabels[i].Global = p.globals[definedLabels[i].Name]
+	}
+	p.closeSections()
+	p.checkUnused()
+	prog := &Program{Items: p.items, Labels: p.labels, DefinedLabels: definedLabels, Origin: origin, Warnings: p.diagnostics, pass: p.pass, layoutChoices: p.layoutChoices, sections: p.sections, definedLabelPos: p.definedLabelPos, stale: p.stale}
+	return prog, joinErrors(p.errs, 0)
+}
+
+// skipStatement records the error of a statement and skips the rest of its
+// line, so that parsing goes on with the next one.
+func (p *Parser) skipStatement(err error) {
+	p.stmts = append(p.stmts, statement{err: p.statementError(err)})
+	p.releaseTokens(p.consumeUntilEOL())
+}
+
+// statementError gives err the current line and places errors raised on
+// expanded tokens in the macro body. Only the line is known there, so the
+// column is dropped.
+func (p *Parser) statementError(err error) error {
+	e, ok := contextualize(p.line, err).(*Error)
+	if !ok {
+		return err
+	}
+	if p.lineOrigin == nil || e.Line != p.line {
+		return e
+	}
+	if e.Expansions == nil {
+		e.Col = 0
+	}
+	return p.lineOrigin.locate(e)
+}
+
+func (p *Parser) expectEndOfStatement() error {
+	if t := p.peek(); t.Kind != NEWLINE && t.Kind != EOF {
+		return errorAtToken(t, fmt.Errorf("unexpected token: %s", t.Text))
+	}
+	if p.peek().Kind == NEWLINE {
+		p.next()
+	}
+	return nil
+}
+
+// choiceKey identifies a size decision across passes: the statement it
+// belongs to and how many decisions that statement made before it.
+type choiceKey struct {
+	stmt string
+	n    int
+}
+
+// layoutChoice returns the next size decision of the current statement,
+// where larger choices give larger code. From the third pass on a decision
+// never shrinks, so a statement whose size moves its own target cannot flip
+// between two encodings forever. Decisions are matched by statement rather
+// than by position, so a conditional block that comes and goes between
+// passes does not hand its decisions to the statements after it.
+func (p *Parser) layoutChoice(want int) int {
+	key := choiceKey{p.stmtKey, p.choiceCounts[p.stmtKey]}
+	p.choiceCounts[p.stmtKey]++
+	if p.pass > 2 && p.prev.layoutChoices[key] > want {
+		want = p.prev.layoutChoices[key]
+	}
+	p.layoutChoices[key] = want
+	return want
+}
+
+// statementKey names the statement starting with t the same way in every
+// pass: by its position and, for expanded lines, by its place in the macro
+// body and the chain of invocations.
+func statementKey(t Token) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d:%d", t.Line, t.Col)
+	if o := t.origin; o != nil {
+		fmt.Fprintf(&b, "@%d:%d", o.line, o.col)
+		for c := o.call; c != nil; c = c.parent {
+			fmt.Fprintf(&b, "<%s:%d:%d", c.Macro, c.Line, c.Col)
+		}
+	}
+	return b.String()
 }
 
 func ParseFile(path string) (*Program, error) {
@@ -223,10 +764,12 @@ func ParseFile(path string) (*Program, error) {
 func ParseFileWithOptions(path string, opts ParseOptions) (*Program, error) {
 	f, err := os.Open(path)
 	if err != nil {
-		return nil, err
+		return nil, withCode(CodeFile, err)
 	}
 	defer f.Close()
 
+	opts.IncludeDirs = append([]string{filepath.Dir(path)}, opts.IncludeDirs...)
+
 	return ParseWithOptions(f, opts)
 }
 
@@ -239,11 +782,27 @@ func parserError(t Token, msg string) error {
 func (p *Parser) parseLabelDefinition() (bool, error) {
 	t := p.peek()
 	if (t.Kind == IDENT || t.Kind == NUMBER) && p.peekN(2).Kind == COLON {
+		if t.Kind == IDENT && p.namesSymbolAfterColon() {
+			// "name: EQU 1" or "name: RS.B 1" define name through the directive.
+			p.buf = append(p.buf[:1], p.buf[2:]...)
+			return false, nil
+		}
 		lbl := p.next()
 		p.next() // consume ':'
 		if lbl.Kind == IDENT {
-			p.labels[lbl.Text] = p.pc
-			p.recordDefinedLabel(lbl.Text, p.pc, lbl.Line)
+			if !isLocalName(lbl.Text) {
+				p.scope = p.namespaced(lbl.Text)
+			}
+			local, full, err := p.symbolName(lbl.Text)
+			if err != nil {
+				return true, errorAtToken(lbl, err)
+			}
+			p.place(func(p *Parser) error {
+				if err := p.defineSymbol(local, full, p.pc, SymbolLabel, lbl.Line); err != nil {
+					return errorAtToken(lbl, err)
+				}
+				return nil
+			})
 		} else {
 			if err := p.defineLocalLabel(lbl); err != nil {
 				return true, err
@@ -254,7 +813,21 @@ func (p *Parser) parseLabelDefinition() (bool, error) {
 	return false, nil
 }
 
-func (p *Parser) recordDefinedLabel(name string, addr uint32, line int) {
+// namesSymbolAfterColon reports whether the "label:" at the start of the line
+// is followed by a directive that assigns the label its own value.
+func (p *Parser) namesSymbolAfterColon() bool {
+	next := p.peekN(3)
+	switch next.Kind {
+	case EQUAL:
+		return true
+	case IDENT:
+		base, _ := splitMnemonic(next.Text)
+		return isEquToken(next) || namingDirectives[base] != nil
+	}
+	return false
+}
+
+func (p *Parser) recordDefinedLabel(name string, addr uint32, line int, kind SymbolKind) {
 	if idx, ok := p.definedLabelPos[name]; ok {
 		p.definedLabels[idx].Addr = addr
 		p.definedLabels[idx].Line = line
@@ -262,7 +835,7 @@ func (p *Parser) recordDefinedLabel(name string, addr uint32, line int) {
 		return
 	}
 	p.definedLabelPos[name] = len(p.definedLabels)
-	p.definedLabels = append(p.definedLabels, DefinedLabel{Name: name, Addr: addr, Line: line, Section: p.section})
+	p.definedLabels = append(p.definedLabels, DefinedLabel{Name: name, Addr: addr, Line: line, Section: p.section, Kind: kind})
 }
 
 func (p *Parser) consumeLocalLabelRef() (string, bool, error) {
@@ -283,7 +856,7 @@ func (p *Parser) consumeLocalLabelRef() (string, bool, error) {
 
 func (p *Parser) parseLabelReference() (string, error) {
 	if p.peek().Kind == IDENT {
-		return p.next().Text, nil
+		return p.resolveSymbol(p.next().Text), nil
 	}
 	if name, ok, err := p.consumeLocalLabelRef(); ok {
 		return name, err
@@ -294,29 +867,52 @@ func (p *Parser) parseLabelReference() (string, error) {
 
 func (p *Parser) parseStmt() (bool, error) {
 	t := p.peek()
+	// coded gives the errors of the statement code, including those its
+	// layout raises in the passes.
+	placed := len(p.stmts)
+	coded := func(code Code, err error) error {
+		for i := placed; i < len(p.stmts); i++ {
+			p.stmts[i].code = code
+		}
+		return withCode(code, err)
+	}
 
 	if t.Kind == IDENT {
-		if def, ok := p.macros[t.Text]; ok {
-			return p.invokeMacro(def)
+		if def, size, ok := p.lookupMacro(t.Text); ok {
+			return p.invokeMacro(def, size)
 		}
 		if p.isConstDefinitionStart() {
-			return false, p.parseConstDefinition(t)
+			return false, coded(CodeInvalidDirective, p.parseConstDefinition(t))
+		}
+		if directive, ok := p.namingDirective(); ok {
+			return false, coded(CodeInvalidDirective, directive(p, p.next()))
 		}
 		base, suffix := splitMnemonic(t.Text)
 		if instrDef := p.instrs.Lookup(base); instrDef != nil {
-			return false, p.parseInstruction(instrDef)
+			return false, coded(CodeInvalidOperand, p.parseInstruction(instrDef))
+		}
+		if branch, ok := jumpBranchMnemonic(base); ok {
+			return false, coded(CodeInvalidOperand, p.parseJumpPseudo(branch))
+		}
+		if sized, ok := p.gasSizedMnemonic(t.Text); ok {
+			p.buf[0].Text = sized
+			return false, coded(CodeInvalidOperand, p.parseInstruction(p.instrs.Lookup(strings.ToUpper(sized[:len(sized)-2]))))
 		}
 
 		if base == "DC" && suffix != "" {
 			_ = p.next()
-			return false, parseDC(p, suffix)
+			return false, coded(CodeInvalidDirective, parseDC(p, suffix))
+		}
+		if sized, ok := sizedDirectives[base]; ok {
+			_ = p.next()
+			return false, coded(CodeInvalidDirective, sized(p, suffix))
 		}
 
 		if pseudo, ok := lookupPseudo(t.Text); ok {
 			_ = p.next()
-			return false, pseudo(p)
+			return false, coded(CodeInvalidDirective, pseudo(p))
 		}
-		return false, parserError(t, "unknown mnemonic")
+		return false, withCode(CodeUnknownMnemonic, withHint(parserError(t, "unknown mnemonic"), p.mnemonicHint(t.Text)))
 	}
 
 	if t.Kind == DOT {
@@ -327,18 +923,25 @@ func (p *Parser) parseStmt() (bool, error) {
 		}
 		name := "." + strings.ToUpper(id.Text)
 		if pseudo, ok := pseudoMap[name]; ok {
-			return false, pseudo(p)
+			return false, coded(CodeInvalidDirective, pseudo(p))
 		}
-		return false, parserError(t, "unknown pseudo op")
+		return false, withCode(CodeUnknownDirective, withHint(parserError(t, "unknown pseudo op"), directiveHint("."+id.Text)))
 	}
 	return false, parserError(t, "unexpected token")
 }
 
 func (p *Parser) isConstDefinitionStart() bool {
-	if p.peekN(2).Kind == EQUAL {
+	switch next := p.peekN(2); next.Kind {
+	case EQUAL:
 		return true
+	case DOT:
+		return isEquToken(p.peekN(3))
+	case IDENT:
+		// Bare "NAME EQU expr"; an instruction operand never reads EQU/SET.
+		base, _ := splitMnemonic(p.peek().Text)
+		return isEquToken(next) && p.instrs.Lookup(base) == nil
 	}
-	return p.peekN(2).Kind == DOT && isEquToken(p.peekN(3))
+	return false
 }
 
 func splitMnemonic(text string) (base, suffix string) {
@@ -355,12 +958,19 @@ func lookupPseudo(text string) (func(*Parser) error, bool) {
 }
 
 func isEquToken(tok Token) bool {
-	return tok.Kind == IDENT && strings.EqualFold(tok.Text, "equ")
+	return tok.Kind == IDENT && (strings.EqualFold(tok.Text, "equ") || isSetToken(tok))
+}
+
+func isSetToken(tok Token) bool {
+	return tok.Kind == IDENT && strings.EqualFold(tok.Text, "set")
 }
 
 func (p *Parser) parseConstDefinition(nameTok Token) error {
 	_ = p.next() // consume name
 
+	// SET and, as in GNU as, '=' in gas syntax define variables that may be
+	// assigned again; EQU and '=' elsewhere define constants.
+	kind := SymbolConstant
 	if p.peek().Kind == DOT {
 		p.next()
 		eqTok, err := p.want(IDENT)
@@ -370,27 +980,84 @@ func (p *Parser) parseConstDefinition(nameTok Token) error {
 		if !isEquToken(eqTok) {
 			return parserError(eqTok, "expected EQU")
 		}
+		if isSetToken(eqTok) {
+			kind = SymbolVariable
+		}
+	} else if isEquToken(p.peek()) {
+		if isSetToken(p.next()) {
+			kind = SymbolVariable
+		}
 	} else {
 		if _, err := p.want(EQUAL); err != nil {
 			return err
 		}
+		if p.syntax == SyntaxGAS {
+			kind = SymbolVariable
+		}
+	}
+
+	val, err := p.parseExprInfo()
+	if err != nil {
+		return err
+	}
+	local, full, err := p.symbolName(nameTok.Text)
+	if err != nil {
+		return errorAtToken(nameTok, err)
 	}
+	p.place(func(p *Parser) error {
+		return p.defineValue(nameTok, local, full, val, kind)
+	})
+	return nil
+}
 
-	val, err := p.parseExpr()
+// defineValue assigns the value of an EQU, SET or "=" to the symbol nameTok
+// names, whose local and full names symbolName returned.
+func (p *Parser) defineValue(nameTok Token, local, full string, val exprInfo, kind SymbolKind) error {
+	val, err := p.evaluate(val)
 	if err != nil {
 		return err
 	}
-	if val < 0 || val > math.MaxUint32 {
-		return errorAtLine(nameTok.Line, fmt.Errorf("constant out of 32-bit range: %d", val))
+	if val.Value < 0 || val.Value > math.MaxUint32 {
+		return errorAtLine(nameTok.Line, withCode(CodeOutOfRange, fmt.Errorf("constant out of 32-bit range: %d", val.Value)))
+	}
+	if err := p.defineSymbol(local, full, uint32(val.Value), kind, nameTok.Line); err != nil {
+		return errorAtToken(nameTok, err)
 	}
+	return nil
+}
 
-	p.labels[nameTok.Text] = uint32(val)
+// symbolName returns the names a definition of name gives a symbol: name
+// qualified as a local label, and that in the open namespaces.
+func (p *Parser) symbolName(name string) (local, full string, err error) {
+	local = p.qualifyLocal(name)
+	full = p.namespaced(local)
+	if alias, ok := p.regAliases[full]; ok {
+		return "", "", fmt.Errorf("%s shadows the register alias defined at line %d", full, alias.line)
+	}
+	return local, full, nil
+}
+
+// defineSymbol assigns a value to the symbol symbolName named full. Only
+// variables may be assigned again, and only as variables.
+func (p *Parser) defineSymbol(local, full string, val uint32, kind SymbolKind, line int) error {
+	p.checkShadowedSymbol(local, full, line)
+	if p.lineOrigin != nil {
+		p.expanded[full] = true
+	}
+	if idx, ok := p.definedLabelPos[full]; ok {
+		if prev := p.definedLabels[idx]; prev.Kind != SymbolVariable || kind != SymbolVariable {
+			return withCode(CodeRedefinedSymbol, fmt.Errorf("%s already defined as a %s at line %d", full, prev.Kind, prev.Line))
+		}
+	}
+	p.labels[full] = val
+	p.defined[full] = true
+	p.recordDefinedLabel(full, val, line, kind)
 	return nil
 }
 
 func (p *Parser) parseInstruction(instrDef *instructions.InstrDef) error {
-	if p.section == SectionBSS {
-		return errorAtLine(p.line, fmt.Errorf("instructions are not allowed in %s", p.section.Name()))
+	if err := p.checkCodeSection(); err != nil {
+		return err
 	}
 
 	mn, err := p.want(IDENT)
@@ -400,6 +1067,161 @@ func (p *Parser) parseInstruction(instrDef *instructions.InstrDef) error {
 
 	operandTokens := p.consumeUntilEOL()
 	defer p.releaseTokens(operandTokens)
+
+	ins, err := p.parseOperands(mn, instrDef, operandTokens)
+	if err != nil {
+		return err
+	}
+	p.place(ins.layout)
+	return nil
+}
+
+// checkCodeSection reports an instruction in a section that holds no code.
+func (p *Parser) checkCodeSection() error {
+	if p.section == SectionBSS {
+		return errorAtLine(p.line, withCode(CodeSection, fmt.Errorf("instructions are not allowed in %s", p.section.Name())))
+	}
+	return nil
+}
+
+// instruction is an instruction as the front end parsed it. The values of
+// its operands, and with them its size, are settled in layout.
+type instruction struct {
+	mn        Token
+	def       *instructions.InstrDef
+	parsed    parsedForm
+	immediate []Token
+	// implicit are the dedicated instructions that take over from def, in
+	// order. err is the error of def, which stands if none of them does.
+	implicit []implicitCandidate
+	err      error
+}
+
+// parseOperands parses the operands of the instruction mn against the forms
+// of def and of the dedicated instructions that may take over from it.
+func (p *Parser) parseOperands(mn Token, def *instructions.InstrDef, operandTokens []Token) (*instruction, error) {
+	parsed, err := p.parseForms(mn, def, operandTokens)
+	ins := &instruction{mn: mn, def: def, parsed: parsed, immediate: immediateOperand(operandTokens)}
+	ins.implicit = p.implicitInstruction(mn, def, operandTokens, parsed.args, err == nil)
+	if err != nil && !ins.decided() {
+		err = withHint(err, p.instructionHint(mn, def, operandTokens, err))
+		if len(ins.implicit) == 0 {
+			return nil, err
+		}
+		ins.err = err
+	}
+	return ins, nil
+}
+
+// decided reports whether a dedicated instruction takes over whatever the
+// layout.
+func (ins *instruction) decided() bool {
+	n := len(ins.implicit)
+	return n > 0 && !ins.implicit[n-1].layoutDependent
+}
+
+// layout completes the operands for the pass and emits the instruction.
+func (ins *instruction) layout(p *Parser) error {
+	mn, def, parsed, err := ins.mn, ins.def, ins.parsed, ins.err
+	var args instructions.Args
+	done := false
+	for _, alt := range ins.implicit {
+		if alt.layoutDependent {
+			mark := len(p.diagnostics)
+			altArgs, altErr := p.layoutOperands(mn, alt.parsed)
+			// 0 keeps the quick form, 1 the longer immediate one.
+			if p.layoutChoice(boolChoice(altErr != nil || !alt.applies(&altArgs))) != 0 {
+				p.diagnostics = p.diagnostics[:mark]
+				continue
+			}
+			args, done = altArgs, true
+		}
+		def, parsed, err = alt.def, alt.parsed, nil
+		break
+	}
+	if err != nil {
+		return err
+	}
+	if !done {
+		if args, err = p.layoutOperands(mn, parsed); err != nil {
+			return withHint(err, p.instructionHint(mn, def, nil, err))
+		}
+	}
+	form := parsed.form
+	args = p.pcRelativeSource(form, args)
+	p.auditOperands(mn.Line, &args)
+	args.AllowTruncation = p.allowTruncation
+	if err := p.checkImmediate(mn, def, form, args, ins.immediate); err != nil {
+		return err
+	}
+
+	instr := &Instr{Def: def, Form: form, Args: args, PC: p.pc, Line: mn.Line, Col: mn.firstCol(), Section: p.section, origin: mn.origin, mnemonic: mn.Text, immediate: ins.immediate}
+	p.items = append(p.items, instr)
+	words, err := instructionWords(form, args)
+	if err != nil {
+		return err
+	}
+	p.pc += uint32(words * 2)
+	p.checkInstruction(mn, def, form, &args, p.pc)
+	return nil
+}
+
+// parsedForm is the form of an instruction that accepted its operands. The
+// values in args are completed by fixups in layout.
+type parsedForm struct {
+	form   *instructions.FormDef
+	args   instructions.Args
+	fixups []operandFixup
+}
+
+// operandFixup evaluates an expression of an operand for the pass and sets
+// the value in the operands.
+type operandFixup struct {
+	expr exprInfo
+	set  func(p *Parser, args *instructions.Args, v exprInfo) error
+}
+
+// fixup registers the layout of an expression of the operand being parsed.
+func (p *Parser) fixup(expr exprInfo, set func(p *Parser, args *instructions.Args, v exprInfo) error) {
+	p.fixups = append(p.fixups, operandFixup{expr: expr, set: set})
+}
+
+// fixupEA registers the layout of an expression of the effective address
+// being parsed.
+func (p *Parser) fixupEA(expr exprInfo, set func(p *Parser, ea *instructions.EAExpr, v exprInfo) error) {
+	operand := p.operand
+	p.fixup(expr, func(p *Parser, args *instructions.Args, v exprInfo) error {
+		if operand == 0 {
+			return set(p, &args.Src, v)
+		}
+		return set(p, &args.Dst, v)
+	})
+}
+
+func setImmediate(_ *Parser, ea *instructions.EAExpr, v exprInfo) error {
+	ea.Imm = v.Value
+	return nil
+}
+
+// layoutOperands returns the operands of a parsed form with the values of
+// this pass. Its errors are placed like those of parseForms.
+func (p *Parser) layoutOperands(mn Token, parsed parsedForm) (instructions.Args, error) {
+	args := parsed.args
+	for _, f := range parsed.fixups {
+		v, err := p.evaluate(f.expr)
+		if err == nil {
+			err = f.set(p, &args, v)
+		}
+		if err != nil {
+			return args, mn.origin.locate(contextualizeAt(mn.Line, mn.firstCol(), err))
+		}
+	}
+	return args, nil
+}
+
+// parseForms parses the operand tokens against each form of instrDef in turn
+// and returns the first form that accepts them.
+func (p *Parser) parseForms(mn Token, instrDef *instructions.InstrDef, operandTokens []Token) (parsedForm, error) {
 	var lastErr error
 	for i := range instrDef.Forms {
 		form := &instrDef.Forms[i]
@@ -408,20 +1230,13 @@ func (p *Parser) parseInstruction(instrDef *instructions.InstrDef) error {
 			lastErr = err
 			continue
 		}
-		ins := &Instr{Def: instrDef, Form: form, Args: args, PC: p.pc, Line: mn.Line, Col: mn.Col, Section: p.section}
-		p.items = append(p.items, ins)
-		words, err := instructionWords(form, args)
-		if err != nil {
-			return err
-		}
-		p.pc += uint32(words * 2)
-		return nil
+		return parsedForm{form: form, args: args, fixups: p.fixups}, nil
 	}
 
 	if lastErr != nil {
-		return contextualizeAt(mn.Line, mn.Col, lastErr)
+		return parsedForm{}, mn.origin.locate(contextualizeAt(mn.Line, mn.firstCol(), lastErr))
 	}
-	return &Error{Line: mn.Line, Col: mn.Col, Err: fmt.Errorf("no form matches operands")}
+	return parsedForm{}, errorAtToken(mn, fmt.Errorf("no form matches operands"))
 }
 
 func instructionWords(form *instructions.FormDef, args instructions.Args) (int, error) {
@@ -441,6 +1256,10 @@ func instructionWords(form *instructions.FormDef, args instructions.Args) (int,
 		if err != nil {
 			return 0, err
 		}
+	} else if len(form.OperKinds) == 1 {
+		// Single-operand forms park their operand in Src until Validate moves
+		// it to Dst, so their destination extension words come from Src.
+		dstEA = srcEA
 	}
 
 	for _, step := range form.Steps {
@@ -501,54 +1320,397 @@ func (p *Parser) prependTokens(tokens []Token) {
 	if len(tokens) == 0 {
 		return
 	}
-	buf := make([]Token, len(tokens)+len(p.buf))
-	copy(buf, tokens)
-	copy(buf[len(tokens):], p.buf)
-	p.buf = buf
+	buf := make([]Token, 0, len(tokens)+len(p.buf))
+	prev := p.lastKind
+	for _, t := range tokens {
+		if alias, ok := p.registerAlias(t, prev); ok {
+			buf = append(buf, alias...)
+		} else {
+			buf = append(buf, t)
+		}
+		prev = t.Kind
+	}
+	p.buf = append(buf, p.buf...)
+}
+
+// lookupMacro finds the macro invoked by a statement mnemonic, which may
+// carry a size suffix ("name.l") that the body reads as \0.
+func (p *Parser) lookupMacro(text string) (macroDef, string, bool) {
+	if def, ok := p.macros[text]; ok {
+		return def, "", true
+	}
+	if idx := strings.IndexByte(text, '.'); idx > 0 {
+		if def, ok := p.macros[text[:idx]]; ok {
+			return def, text[idx+1:], true
+		}
+	}
+	return macroDef{}, "", false
 }
 
-func (p *Parser) invokeMacro(def macroDef) (bool, error) {
+func (p *Parser) invokeMacro(def macroDef, size string) (bool, error) {
+	p.usedMacros[def.name] = true
 	nameTok := p.next()
 	rawArgs := p.consumeUntilEOL()
 	defer p.releaseTokens(rawArgs)
 
 	args, err := splitMacroArgs(rawArgs)
 	if err != nil {
-		return false, errorAtLine(nameTok.Line, err)
+		return false, nameTok.origin.locate(errorAtLine(nameTok.Line, withCode(CodeMacro, err)))
 	}
-	if len(args) != len(def.params) {
-		return false, errorAtLine(nameTok.Line, fmt.Errorf("macro %s expects %d args, got %d", nameTok.Text, len(def.params), len(args)))
+	values, err := bindMacroArgs(nameTok.Text, def.params, args)
+	if err != nil {
+		return false, nameTok.origin.locate(errorAtLine(nameTok.Line, withCode(CodeMacro, err)))
 	}
 
-	if p.macroDepth > 64 {
-		return false, errorAtLine(nameTok.Line, fmt.Errorf("macro expansion depth exceeded"))
+	// The expansion is parsed after this call returns, so the nesting depth
+	// is the number of expansions still pending in the token buffer.
+	if p.pendingExpansions() > 64 {
+		return false, nameTok.origin.locate(errorAtLine(nameTok.Line, withCode(CodeMacro, fmt.Errorf("macro expansion depth exceeded"))))
 	}
-	p.macroDepth++
-	defer func() { p.macroDepth-- }()
 
-	expanded := expandMacroBody(def, args, nameTok)
+	p.macroSerial++
+	call := macroCall{args: args, values: values, size: size, unique: macroUniqueSuffix(p.macroSerial)}
+	expanded, err := p.expandMacroBody(def, call, nameTok)
+	if err != nil {
+		return false, withCode(CodeMacro, err)
+	}
+	// The end marker remembers the conditional depth so .exitm can unwind.
+	expanded = append(expanded, Token{Kind: MACROEND, Line: nameTok.Line, Col: nameTok.Col, Val: int64(len(p.conds)), origin: nameTok.origin})
 	p.prependTokens(expanded)
 	return true, nil
 }
 
-func expandMacroBody(def macroDef, args [][]Token, origin Token) []Token {
-	expanded := make([]Token, 0, len(def.body)+len(args))
+func (p *Parser) pendingExpansions() int {
+	n := 0
+	for _, t := range p.buf {
+		if t.Kind == MACROEND {
+			n++
+		}
+	}
+	return n
+}
+
+// bindMacroArgs assigns the invocation arguments to the macro parameters.
+// Arguments are positional unless written as "param=value"; omitted
+// parameters take their default, and a vararg parameter collects the rest.
+// Macros without named parameters take any number of positional arguments,
+// referenced as \1..\9.
+func bindMacroArgs(macro string, params []macroParam, args [][]Token) ([][]Token, error) {
+	if len(params) == 0 {
+		return args, nil
+	}
+	values := make([][]Token, len(params))
+	given := make([]bool, len(params))
+	next := 0
+	for _, arg := range args {
+		idx, keyword := -1, len(arg) >= 2 && arg[0].Kind == IDENT && arg[1].Kind == EQUAL
+		if keyword {
+			for i, param := range params {
+				if param.name == arg[0].Text {
+					idx = i
+				}
+			}
+			if idx < 0 {
+				return nil, fmt.Errorf("macro %s has no parameter %s", macro, arg[0].Text)
+			}
+			arg = arg[2:]
+		} else {
+			if next >= len(params) {
+				return nil, fmt.Errorf("macro %s expects %d args, got %d", macro, len(params), len(args))
+			}
+			idx = next
+			if !params[idx].vararg {
+				next++
+			}
+		}
+		switch {
+		case params[idx].vararg && given[idx]:
+			values[idx] = append(values[idx], Token{Kind: COMMA, Text: ","})
+			values[idx] = append(values[idx], arg...)
+		case given[idx]:
+			return nil, fmt.Errorf("macro %s: argument %s given twice", macro, params[idx].name)
+		default:
+			values[idx] = arg
+		}
+		given[idx] = true
+	}
+	for i, param := range params {
+		if len(values[i]) > 0 {
+			continue
+		}
+		if param.def != nil {
+			values[i] = param.def
+		} else if !given[i] && !param.vararg {
+			return nil, fmt.Errorf("macro %s: missing argument %s", macro, param.name)
+		}
+	}
+	return values, nil
+}
+
+// generatedMark marks the names generated for macro expansions. A '$'
+// cannot continue an identifier written in the source, so the names never
+// meet a label defined there.
+const generatedMark = '$'
+
+// macroUniqueSuffix is the text substituted for \@ in the n-th expansion.
+func macroUniqueSuffix(n int) string {
+	return fmt.Sprintf("_%c%03d", generatedMark, n)
+}
+
+func (p *Parser) expandMacroBody(def macroDef, call macroCall, invocation Token) ([]Token, error) {
+	site := &macroCallSite{MacroFrame: MacroFrame{Macro: def.name, Line: invocation.Line, Col: invocation.firstCol()}}
+	if o := invocation.origin; o != nil {
+		site.Line, site.Col, site.parent = o.line, o.col, o.call
+	}
+	expanded := make([]Token, 0, len(def.body)+len(call.args))
 	for _, t := range def.body {
-		if repl, ok := macroArgumentTokens(def.params, args, t.Text); ok && t.Kind == IDENT {
+		// Expanded tokens keep the invocation's position for listings and
+		// remember their place in the body for diagnostics.
+		origin := invocation
+		origin.origin = &macroOrigin{line: t.Line, col: t.firstCol(), call: site}
+		if t.origin != nil {
+			origin.origin.line, origin.origin.col = t.origin.line, t.origin.col
+		}
+		if t.Kind == IDENT {
+			t.Text = uniqueLabelText(t.Text, def.locals, call.unique)
+		}
+		if t.Kind == IDENT && (strings.EqualFold(t.Text, "NARG") || t.Text == `\#`) {
+			// NARG and \# are the number of arguments passed to the invocation.
+			narg := relocatedToken(t, origin)
+			narg.Kind, narg.Text, narg.Val = NUMBER, fmt.Sprint(len(call.args)), int64(len(call.args))
+			expanded = append(expanded, narg)
+			continue
+		}
+		if repl, ok := macroArgumentTokens(def.params, call, t.Text); ok && t.Kind == IDENT {
 			for _, at := range repl {
 				expanded = append(expanded, relocatedToken(at, origin))
 			}
 			continue
 		}
+		if text, ok := macroSourceText(t); ok {
+			// Composite identifiers ("move.\0", "label\()_end") and strings
+			// with references ("\1") are substituted as text and re-lexed.
+			toks, err := p.lexFragment(substituteMacroText(text, def.params, call, t.Kind == STRING && text[0] == '"'), origin)
+			if err != nil {
+				return nil, err
+			}
+			expanded = append(expanded, toks...)
+			continue
+		}
 		expanded = append(expanded, relocatedToken(t, origin))
 	}
-	return expanded
+	return expanded, nil
+}
+
+// macroSourceText returns the source text of a body token that contains macro
+// references.
+func macroSourceText(t Token) (string, bool) {
+	switch t.Kind {
+	case IDENT:
+		return t.Text, strings.IndexByte(t.Text, '\\') >= 0
+	case STRING:
+		return t.Raw, strings.IndexByte(t.Raw, '\\') >= 0
+	}
+	return "", false
+}
+
+// substituteMacroText replaces the macro references in text. "\()" separates
+// a bare parameter name from adjacent text. In double-quoted strings the
+// escapes \0, \n, \r, \t and \\ keep their meaning, \{name} names a
+// parameter explicitly, and arguments are inserted with their quotes and
+// backslashes escaped, so that the string stays whole.
+func substituteMacroText(text string, params []macroParam, call macroCall, quoted bool) string {
+	pieces := strings.Split(text, `\()`)
+	for i, piece := range pieces {
+		if value, ok := macroParamText(params, call, piece); ok {
+			pieces[i] = value
+			continue
+		}
+		var b strings.Builder
+		arg := func(s string) {
+			if quoted {
+				s = stringEscaper.Replace(s)
+			}
+			b.WriteString(s)
+		}
+		for j := 0; j < len(piece); j++ {
+			if piece[j] != '\\' || j+1 >= len(piece) {
+				b.WriteByte(piece[j])
+				continue
+			}
+			switch c := piece[j+1]; {
+			case c == '@':
+				b.WriteString(call.unique)
+				j++
+			case c == '#':
+				fmt.Fprint(&b, len(call.args))
+				j++
+			case c == '*':
+				arg(tokensText(joinMacroArgs(call.args)))
+				j++
+			case quoted && (c == '0' || c == '\\'):
+				b.WriteString(piece[j : j+2])
+				j++
+			case c >= '0' && c <= '9':
+				arg(macroPositionalText(call, int(c-'0')))
+				j++
+			case quoted && c == '{':
+				end := strings.IndexByte(piece[j:], '}')
+				if end < 0 {
+					b.WriteByte('\\')
+					continue
+				}
+				name := piece[j+2 : j+end]
+				if value, ok := macroParamText(params, call, name); ok {
+					arg(value)
+				} else if len(name) == 1 && name[0] >= '0' && name[0] <= '9' {
+					arg(macroPositionalText(call, int(name[0]-'0')))
+				} else {
+					b.WriteString(piece[j : j+end+1])
+				}
+				j += end
+			default:
+				end := j + 1
+				for end < len(piece) && isMacroNameByte(piece[end]) {
+					end++
+				}
+				name := piece[j+1 : end]
+				if quoted && isStringEscape(name) {
+					b.WriteByte('\\')
+					continue
+				}
+				if value, ok := macroParamText(params, call, name); ok && end > j+1 {
+					arg(value)
+					j = end - 1
+				} else {
+					b.WriteByte('\\')
+				}
+			}
+		}
+		pieces[i] = b.String()
+	}
+	return strings.Join(pieces, "")
+}
+
+// stringEscaper escapes argument text inserted into a double-quoted string.
+var stringEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+// isStringEscape reports whether name, the text after a backslash in a
+// double-quoted string, is an escape letter rather than a parameter.
+func isStringEscape(name string) bool {
+	return name == "n" || name == "r" || name == "t"
+}
+
+func isMacroNameByte(c byte) bool {
+	return c == '_' || (c >= '0' && c <= '9') || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func macroParamText(params []macroParam, call macroCall, name string) (string, bool) {
+	for i, param := range params {
+		if name == param.name {
+			return tokensText(call.values[i]), true
+		}
+	}
+	return "", false
+}
+
+// macroPositionalText is \n: the n-th argument, or the invocation size for
+// \0 (W when the invocation has none).
+func macroPositionalText(call macroCall, n int) string {
+	if n == 0 {
+		if call.size == "" {
+			return "W"
+		}
+		return call.size
+	}
+	if n <= len(call.values) {
+		return tokensText(call.values[n-1])
+	}
+	return ""
+}
+
+// tokensText renders tokens back to source text.
+func tokensText(tokens []Token) string {
+	var b strings.Builder
+	for i, t := range tokens {
+		if i > 0 && isWordToken(tokens[i-1]) && isWordToken(t) {
+			b.WriteByte(' ')
+		}
+		if t.Raw != "" {
+			b.WriteString(t.Raw)
+		} else {
+			b.WriteString(t.Text)
+		}
+	}
+	return b.String()
+}
+
+func isWordToken(t Token) bool {
+	return t.Kind == IDENT || t.Kind == NUMBER
+}
+
+func joinMacroArgs(args [][]Token) []Token {
+	var all []Token
+	for i, arg := range args {
+		if i > 0 {
+			all = append(all, Token{Kind: COMMA, Text: ","})
+		}
+		all = append(all, arg...)
+	}
+	return all
 }
 
-func macroArgumentTokens(params []string, args [][]Token, name string) ([]Token, bool) {
+// lexFragment tokenizes substituted macro text in the source dialect.
+func (p *Parser) lexFragment(text string, origin Token) ([]Token, error) {
+	lx := NewLexerWithSyntax(strings.NewReader(text), p.syntax)
+	lx.lineStart = false
+	lx.generatedNames = true
+	var out []Token
+	for {
+		t := lx.Next()
+		if t.Kind == EOF {
+			if t.Text != "" {
+				return nil, errorAtToken(origin, fmt.Errorf("in macro text %q: %s", text, t.Text))
+			}
+			return out, nil
+		}
+		out = append(out, relocatedToken(t, origin))
+	}
+}
+
+// uniqueLabelText replaces \@ with the expansion's unique suffix and renames
+// .local names, so every expansion defines its own labels.
+func uniqueLabelText(text string, locals []string, unique string) string {
+	if strings.Contains(text, `\@`) {
+		return strings.ReplaceAll(text, `\@`, unique)
+	}
+	for _, name := range locals {
+		if text == name {
+			return name + unique
+		}
+	}
+	return text
+}
+
+func macroArgumentTokens(params []macroParam, call macroCall, name string) ([]Token, bool) {
+	if name == "\\*" {
+		// \* passes the whole argument list through, e.g. to another macro.
+		return joinMacroArgs(call.args), true
+	}
+	if name == `\0` {
+		return []Token{{Kind: IDENT, Text: macroPositionalText(call, 0)}}, true
+	}
+	if len(name) == 2 && name[0] == '\\' && name[1] >= '1' && name[1] <= '9' {
+		if i := int(name[1] - '1'); i < len(call.values) {
+			return call.values[i], true
+		}
+		return nil, true
+	}
+	// Named parameters are written bare ("reg") or gas-style ("\reg").
+	name = strings.TrimPrefix(name, `\`)
 	for i, param := range params {
-		if name == param {
-			return args[i], true
+		if name == param.name {
+			return call.values[i], true
 		}
 	}
 	return nil, false
@@ -556,7 +1718,8 @@ func macroArgumentTokens(params []string, args [][]Token, name string) ([]Token,
 
 func relocatedToken(tok Token, origin Token) Token {
 	tok.Line = origin.Line
-	tok.Col = origin.Col
+	tok.Col, tok.start = origin.Col, origin.start
+	tok.origin = origin.origin
 	return tok
 }
 
@@ -600,9 +1763,12 @@ func withSliceLexer(tokens []Token, line int, scratch []Token) (*sliceLexer, []T
 
 func (p *Parser) tryParseForm(mn Token, form *instructions.FormDef, tokens []Token) (instructions.Args, error) {
 	args := instructions.Args{}
-	origLX, origBuf, origLine, origCol := p.lx, p.buf, p.line, p.col
+	p.fixups = nil
+	// The operands were recorded for Preprocess when they were collected.
+	origLX, origBuf, origLine, origCol, origPP := p.lx, p.buf, p.line, p.col, p.pp
+	p.pp = nil
 	defer func() {
-		p.lx, p.buf, p.line, p.col = origLX, origBuf, origLine, origCol
+		p.lx, p.buf, p.line, p.col, p.pp = origLX, origBuf, origLine, origCol, origPP
 		p.formScratch = p.formScratch[:0]
 	}()
 
@@ -625,6 +1791,7 @@ func (p *Parser) tryParseForm(mn Token, form *instructions.FormDef, tokens []Tok
 			}
 		}
 
+		p.operand = i
 		eaExpr, err := p.parseOperand(operandKind, mn, &args, i)
 		if err != nil {
 			return args, err
@@ -652,23 +1819,25 @@ func (p *Parser) parseOperand(kind instructions.OperandKind, mn Token, args *ins
 		if _, err := p.want(HASH); err != nil {
 			return eaExpr, err
 		}
-		imm, err := p.parseExpr()
+		imm, err := p.parseExprInfo()
 		if err != nil {
 			return eaExpr, err
 		}
+		p.fixupEA(imm, setImmediate)
 		eaExpr.Kind = instructions.EAkImm
-		eaExpr.Imm = imm
+		eaExpr.Expr = imm.Expr
 
 	case instructions.OpkImmQuick:
 		if _, err := p.want(HASH); err != nil {
 			return eaExpr, err
 		}
-		imm, err := p.parseExpr()
+		imm, err := p.parseExprInfo()
 		if err != nil {
 			return eaExpr, err
 		}
+		p.fixupEA(imm, setImmediate)
 		eaExpr.Kind = instructions.EAkNone
-		eaExpr.Imm = imm
+		eaExpr.Expr = imm.Expr
 		args.HasImmQuick = true
 
 	case instructions.OpkDn:
@@ -753,15 +1922,19 @@ func (p *Parser) parseOperand(kind instructions.OperandKind, mn Token, args *ins
 			return eaExpr, nil
 		}
 		if p.peek().Kind == IDENT && (p.peekN(2).Kind == EOF || p.peekN(2).Kind == NEWLINE) {
-			args.Target = p.next().Text
+			args.Target = p.resolveSymbol(p.next().Text)
 			return eaExpr, nil
 		}
-		target, err := p.parseExpr()
+		target, err := p.parseExprInfo()
 		if err != nil {
 			return eaExpr, err
 		}
-		args.TargetAddr = target
+		p.fixup(target, func(_ *Parser, args *instructions.Args, v exprInfo) error {
+			args.TargetAddr = v.Value
+			return nil
+		})
 		args.HasTargetAddr = true
+		args.TargetExpr = target.Expr
 
 	default:
 		return eaExpr, errorAtLine(mn.Line, fmt.Errorf("unknown identifier %s", mn.Text))
@@ -778,7 +1951,7 @@ func (p *Parser) emitPaddingBytes(count uint32, fill byte) error {
 		return nil
 	}
 	if count > maxProgramSize || p.pc > maxProgramSize-count {
-		return errorAtLine(p.line, fmt.Errorf("padding would exceed maximum program size of %d bytes", maxProgramSize))
+		return errorAtLine(p.line, withCode(CodeLayout, fmt.Errorf("padding would exceed maximum program size of %d bytes", maxProgramSize)))
 	}
 
 	buf := make([]byte, int(count))
@@ -792,14 +1965,38 @@ func (p *Parser) emitPaddingBytes(count uint32, fill byte) error {
 	return nil
 }
 
+// switchSection places a switch to section.
+func (p *Parser) switchSection(section SectionKind) {
+	p.place(func(p *Parser) error {
+		return p.setSection(section)
+	})
+}
+
 func (p *Parser) setSection(section SectionKind) error {
 	if section < p.section {
-		return errorAtLine(p.line, fmt.Errorf("sections must stay in .text -> .data -> .bss order"))
+		return errorAtLine(p.line, withCode(CodeSection, fmt.Errorf("sections must stay in .text -> .data -> .bss order")))
+	}
+	for s := p.section; s < section; s++ {
+		p.sections[s].end = p.pc
+		p.sections[s].closed = true
+		p.sections[s+1].start = p.pc
 	}
 	p.section = section
 	return nil
 }
 
+// closeSections ends the current section and leaves the later ones empty at
+// the final address.
+func (p *Parser) closeSections() {
+	for s := p.section; s < sectionCount; s++ {
+		if s > p.section {
+			p.sections[s].start = p.pc
+		}
+		p.sections[s].end = p.pc
+		p.sections[s].closed = true
+	}
+}
+
 func sizeAllowedList(sz instructions.Size, allowed []instructions.Size) bool {
 	if len(allowed) == 0 {
 		return true
@@ -823,14 +2020,14 @@ func (p *Parser) parseSizeSpec(mn Token, def instructions.Size, allowed []instru
 	if idx := strings.IndexRune(mn.Text, '.'); idx > 0 {
 		suf := mn.Text[idx+1:]
 		if suf == "" {
-			return 0, parserError(mn, "unknown size suffix")
+			return 0, withCode(CodeInvalidSize, parserError(mn, "unknown size suffix"))
 		}
 		sz, ok := sizeFromIdent(suf)
 		if !ok {
-			return 0, parserError(mn, "unknown size suffix "+suf)
+			return 0, withCode(CodeInvalidSize, parserError(mn, "unknown size suffix "+suf))
 		}
 		if !sizeAllowedList(sz, allowed) {
-			return 0, parserError(mn, "illegal size for instruction")
+			return 0, withCode(CodeInvalidSize, parserError(mn, "illegal size for instruction"))
 		}
 		return sz, nil
 	}
@@ -850,12 +2047,12 @@ func (p *Parser) parseSizeSuffix(def instructions.Size, allowed []instructions.S
 		}
 		val, ok := sizeFromIdent(id.Text)
 		if !ok {
-			return 0, parserError(id, "unknown size suffix")
+			return 0, withCode(CodeInvalidSize, parserError(id, "unknown size suffix"))
 		}
 		sz = val
 	}
 	if !sizeAllowedList(sz, allowed) {
-		return 0, contextualizeAt(p.line, p.col, fmt.Errorf("illegal size for instruction"))
+		return 0, contextualizeAt(p.line, p.col, withCode(CodeInvalidSize, fmt.Errorf("illegal size for instruction")))
 	}
 	return sz, nil
 }
@@ -1012,11 +2209,28 @@ func (p *Parser) parseExpectedSpecialRegister(name string, kind instructions.EAE
 	return instructions.EAExpr{Kind: kind}, nil
 }
 
-func parseAbsoluteEA(kind instructions.EAExprKind, value int64) instructions.EAExpr {
+// parseAbsoluteEA returns the absolute address operand expr of the given
+// kind, whose address is checked and set in layout.
+func (p *Parser) parseAbsoluteEA(kind instructions.EAExprKind, expr exprInfo) instructions.EAExpr {
+	p.fixupEA(expr, func(p *Parser, ea *instructions.EAExpr, v exprInfo) error {
+		abs, err := p.absoluteEA(kind, v)
+		*ea = abs
+		return err
+	})
+	return instructions.EAExpr{Kind: kind, Expr: expr.Expr}
+}
+
+func (p *Parser) absoluteEA(kind instructions.EAExprKind, expr exprInfo) (instructions.EAExpr, error) {
 	if kind == instructions.EAkAbsW {
-		return instructions.EAExpr{Kind: kind, Abs16: uint16(value)}
+		if err := p.checkAbsoluteWord(expr); err != nil {
+			return instructions.EAExpr{}, err
+		}
+		return instructions.EAExpr{Kind: kind, Abs16: uint16(expr.Value), Expr: expr.Expr}, nil
+	}
+	if err := p.checkFit(expr.Value, 32, "absolute address"); err != nil {
+		return instructions.EAExpr{}, p.operandError(expr, err)
 	}
-	return instructions.EAExpr{Kind: kind, Abs32: uint32(value)}
+	return instructions.EAExpr{Kind: kind, Abs32: uint32(expr.Value), Expr: expr.Expr}, nil
 }
 
 func (p *Parser) parseAbsoluteSuffix(defaultKind instructions.EAExprKind, invalidMsg string) (instructions.EAExprKind, error) {
@@ -1048,36 +2262,37 @@ func parseEABaseRegister(text string) (reg int, pcRelative bool, ok bool) {
 	return 0, false, false
 }
 
-func displacementEA(base Token, disp int64) (instructions.EAExpr, error) {
+func displacementEA(base Token, expr *Expr) (instructions.EAExpr, error) {
 	if reg, isPC, ok := parseEABaseRegister(base.Text); ok {
 		if isPC {
-			return instructions.EAExpr{Kind: instructions.EAkPCDisp16, Disp16: int32(disp)}, nil
+			return instructions.EAExpr{Kind: instructions.EAkPCDisp16, Expr: expr}, nil
 		}
-		return instructions.EAExpr{Kind: instructions.EAkAddrDisp16, Reg: reg, Disp16: int32(disp)}, nil
+		return instructions.EAExpr{Kind: instructions.EAkAddrDisp16, Reg: reg, Expr: expr}, nil
 	}
 	return instructions.EAExpr{}, parserError(base, "base must be An or PC for displacement addressing")
 }
 
-func indexedEA(base Token, disp int64, ix instructions.EAIndex) (instructions.EAExpr, error) {
-	ix.Disp8 = int8(disp)
+func indexedEA(base Token, ix instructions.EAIndex, expr *Expr) (instructions.EAExpr, error) {
 	if reg, isPC, ok := parseEABaseRegister(base.Text); ok {
 		if isPC {
-			return instructions.EAExpr{Kind: instructions.EAkIdxPCBrief, Index: ix}, nil
+			return instructions.EAExpr{Kind: instructions.EAkIdxPCBrief, Index: ix, Expr: expr}, nil
 		}
-		return instructions.EAExpr{Kind: instructions.EAkIdxAnBrief, Reg: reg, Index: ix}, nil
+		return instructions.EAExpr{Kind: instructions.EAkIdxAnBrief, Reg: reg, Index: ix, Expr: expr}, nil
 	}
 	return instructions.EAExpr{}, parserError(base, "base must be An or PC for indexed addressing")
 }
 
+// pcRelativeDisp checks that the target of a PC-relative operand is within
+// reach of the extension word.
 func (p *Parser) pcRelativeDisp(expr exprInfo, min, max int64) (int64, error) {
-	if !expr.HasSymbol {
-		return expr.Value, nil
-	}
 	disp := expr.Value - int64(p.pc) - 2
-	if disp < min || disp > max {
-		return 0, errorAtLine(p.line, fmt.Errorf("PC-relative displacement out of range: %d", disp))
+	if (disp < min || disp > max) && !p.allowForwardRefs {
+		if err := p.truncated(fmt.Errorf("PC-relative displacement out of range: %d", disp)); err != nil {
+			return 0, p.operandError(expr, err)
+		}
 	}
-	return disp, nil
+	// The encoder subtracts the extension word address from the target.
+	return expr.Value, nil
 }
 
 // ---------- EA parsing helpers ----------
@@ -1101,11 +2316,12 @@ func (p *Parser) parseEAPreDecrement() (instructions.EAExpr, error) {
 
 func (p *Parser) parseEAImmediate() (instructions.EAExpr, error) {
 	p.next() // '#'
-	v, err := p.parseExpr()
+	v, err := p.parseExprInfo()
 	if err != nil {
 		return instructions.EAExpr{}, err
 	}
-	return instructions.EAExpr{Kind: instructions.EAkImm, Imm: v}, nil
+	p.fixupEA(v, setImmediate)
+	return instructions.EAExpr{Kind: instructions.EAkImm, Expr: v.Expr}, nil
 }
 
 func (p *Parser) parseEADisplacementOrAbsolute() (instructions.EAExpr, error) {
@@ -1122,11 +2338,57 @@ func (p *Parser) parseEADisplacementOrAbsolute() (instructions.EAExpr, error) {
 		return p.parseEADisplacementBody(expr)
 	}
 
-	kind, err := p.parseAbsoluteSuffix(instructions.EAkAbsL, "unknown size suffix .%s")
+	kind, err := p.parseAbsoluteSuffix(0, "unknown size suffix .%s")
 	if err != nil {
 		return instructions.EAExpr{}, err
 	}
-	return parseAbsoluteEA(kind, expr.Value), nil
+	if kind == 0 {
+		return p.autoAbsoluteEA(expr), nil
+	}
+	return p.parseAbsoluteEA(kind, expr), nil
+}
+
+// Size choices of absolute addresses, ordered by code size for layoutChoice.
+const (
+	absWord = iota
+	absLong
+)
+
+// autoAbsoluteEA returns an address without a size suffix, which layout
+// makes xxx.W when the sign-extended word reaches it and xxx.L otherwise.
+// The first pass treats symbols defined further down as far away.
+func (p *Parser) autoAbsoluteEA(expr exprInfo) instructions.EAExpr {
+	p.fixupEA(expr, func(p *Parser, ea *instructions.EAExpr, v exprInfo) error {
+		kind := instructions.EAkAbsL
+		if !p.longAbsolute {
+			choice := absLong
+			if fitsAbsoluteWord(v.Value) && p.symbolsKnown(v.Expr) {
+				choice = absWord
+			}
+			if p.layoutChoice(choice) == absWord {
+				kind = instructions.EAkAbsW
+			}
+		}
+		abs, err := p.absoluteEA(kind, v)
+		abs.AutoSize = true
+		*ea = abs
+		return err
+	})
+	return instructions.EAExpr{Kind: instructions.EAkAbsL, Expr: expr.Expr, AutoSize: true}
+}
+
+// symbolsKnown reports whether every symbol in e has a value in this pass or
+// the previous one.
+func (p *Parser) symbolsKnown(e *Expr) bool {
+	if e == nil || p.prev != nil {
+		return true
+	}
+	for _, name := range e.Symbols() {
+		if _, ok := p.labels[name]; !ok {
+			return false
+		}
+	}
+	return true
 }
 
 func (p *Parser) parseEAIndirect() (instructions.EAExpr, error) {
@@ -1182,7 +2444,7 @@ func (p *Parser) parseEAIndirect() (instructions.EAExpr, error) {
 	}
 	kind, err := p.parseAbsoluteSuffix(0, "expected .W or .L after (absolute address)")
 	if err == nil && kind != 0 {
-		return parseAbsoluteEA(kind, expr.Value), nil
+		return p.parseAbsoluteEA(kind, expr), nil
 	}
 	return instructions.EAExpr{}, errorAtLine(p.line, fmt.Errorf("invalid effective address form, expected (abs).W or (abs).L"))
 }
@@ -1193,31 +2455,7 @@ func (p *Parser) parseEADisplacementBody(expr exprInfo) (instructions.EAExpr, er
 	if err != nil {
 		return instructions.EAExpr{}, err
 	}
-	disp := expr.Value
-	if isPC(base.Text) {
-		if p.accept(COMMA) {
-			ix, err := p.parseEAIndex()
-			if err != nil {
-				return instructions.EAExpr{}, err
-			}
-			if _, err := p.want(RPAREN); err != nil {
-				return instructions.EAExpr{}, err
-			}
-			disp, err = p.pcRelativeDisp(expr, -128, 127)
-			if err != nil {
-				return instructions.EAExpr{}, err
-			}
-			return indexedEA(base, disp, ix)
-		}
-		if _, err := p.want(RPAREN); err != nil {
-			return instructions.EAExpr{}, err
-		}
-		disp, err = p.pcRelativeDisp(expr, -32768, 32767)
-		if err != nil {
-			return instructions.EAExpr{}, err
-		}
-		return displacementEA(base, disp)
-	}
+	pcRelative := isPC(base.Text)
 
 	// Is it an indexed mode, d(An,ix) or d(PC,ix)?
 	if p.accept(COMMA) {
@@ -1228,14 +2466,36 @@ func (p *Parser) parseEADisplacementBody(expr exprInfo) (instructions.EAExpr, er
 		if _, err := p.want(RPAREN); err != nil {
 			return instructions.EAExpr{}, err
 		}
-		return indexedEA(base, disp, ix)
+		p.fixupDisplacement(expr, pcRelative, 8)
+		return indexedEA(base, ix, expr.Expr)
 	}
 
 	// It's a simple displacement mode: d(An) or d(PC)
 	if _, err := p.want(RPAREN); err != nil {
 		return instructions.EAExpr{}, err
 	}
-	return displacementEA(base, disp)
+	p.fixupDisplacement(expr, pcRelative, 16)
+	return displacementEA(base, expr.Expr)
+}
+
+// fixupDisplacement registers the layout of the displacement of a d(An) or
+// d(PC) operand, which has a field of bits bits.
+func (p *Parser) fixupDisplacement(expr exprInfo, pcRelative bool, bits uint) {
+	p.fixupEA(expr, func(p *Parser, ea *instructions.EAExpr, v exprInfo) error {
+		disp := v.Value
+		var err error
+		if pcRelative {
+			disp, err = p.pcRelativeDisp(v, -1<<(bits-1), 1<<(bits-1)-1)
+		} else {
+			err = p.checkDisplacement(v, bits)
+		}
+		if bits == 8 {
+			ea.Index.Disp8 = int8(disp)
+		} else {
+			ea.Disp16 = int32(disp)
+		}
+		return err
+	})
 }
 
 func (p *Parser) parseEAIndex() (instructions.EAIndex, error) {
@@ -1276,19 +2536,20 @@ func (p *Parser) parseEAIndex() (instructions.EAIndex, error) {
 	}
 
 	// Optional scale factor *1, *2, *4, *8
+	ix.Scale = 1 // Default scale
 	if p.accept(STAR) {
-		sc, err := p.parseExprUntil(COMMA, RPAREN)
+		sc, err := p.parseExprInfoUntil(COMMA, RPAREN)
 		if err != nil {
 			return ix, err
 		}
-		switch sc {
-		case 1, 2, 4, 8:
-			ix.Scale = uint8(sc)
-		default:
-			return ix, fmt.Errorf("invalid scale factor: %d", sc)
-		}
-	} else {
-		ix.Scale = 1 // Default scale
+		p.fixupEA(sc, func(_ *Parser, ea *instructions.EAExpr, v exprInfo) error {
+			switch v.Value {
+			case 1, 2, 4, 8:
+				ea.Index.Scale = uint8(v.Value)
+				return nil
+			}
+			return fmt.Errorf("invalid scale factor: %d", v.Value)
+		})
 	}
 
 	return ix, nil
@@ -1323,14 +2584,77 @@ func parseIndexSizeSuffix(suffix string) (bool, error) {
 // Token Reader / Lexer Integration
 func (p *Parser) fill(n int) {
 	for len(p.buf) < n {
-		p.buf = append(p.buf, p.lx.Next())
+		prev := p.lastKind
+		if len(p.buf) > 0 {
+			prev = p.buf[len(p.buf)-1].Kind
+		}
+		t := p.lx.Next()
+		if alias, ok := p.registerAlias(t, prev); ok {
+			p.buf = append(p.buf, alias...)
+			continue
+		}
+		p.buf = append(p.buf, t)
+	}
+}
+
+// registerAlias expands an EQUR/REG symbol to its register tokens. A size
+// suffix on a single-register alias ("idx.w") is kept. prev is the kind of
+// the token before t: a name that starts a statement is never expanded, so
+// that redefining it can be reported.
+func (p *Parser) registerAlias(t Token, prev Kind) ([]Token, bool) {
+	if t.Kind != IDENT || len(p.regAliases) == 0 || startsStatement(prev) {
+		return nil, false
+	}
+	name, suffix := t.Text, ""
+	if idx := strings.IndexByte(name[1:], '.'); idx >= 0 {
+		name, suffix = name[:idx+1], name[idx+1:]
 	}
+	alias, ok := p.regAliases[p.qualifyLocal(name)]
+	if !ok || (suffix != "" && len(alias.tokens) != 1) {
+		return nil, false
+	}
+	out := make([]Token, len(alias.tokens))
+	for i, a := range alias.tokens {
+		out[i] = relocatedToken(a, t)
+	}
+	out[0].Text += suffix
+	return out, true
+}
+
+func startsStatement(prev Kind) bool {
+	return prev == NEWLINE || prev == MACROEND || prev == EOF
+}
+
+// defineRegAlias binds name to register tokens from here on. Aliases cannot
+// be redefined, name a register, or share a name with a symbol; ".name"
+// aliases are local to the enclosing label like local labels.
+func (p *Parser) defineRegAlias(nameTok Token, tokens []Token) error {
+	name := p.qualifyLocal(nameTok.Text)
+	if isRegisterName(name) || strings.EqualFold(name, "SP") || strings.EqualFold(name, "PC") {
+		return parserError(nameTok, fmt.Sprintf("register alias %s cannot be a register name", name))
+	}
+	if prev, ok := p.regAliases[name]; ok {
+		return withCode(CodeRedefinedSymbol, parserError(nameTok, fmt.Sprintf("register alias %s already defined at line %d", name, prev.line)))
+	}
+	if idx, ok := p.definedLabelPos[name]; ok {
+		prev := p.definedLabels[idx]
+		return parserError(nameTok, fmt.Sprintf("register alias %s shadows the %s defined at line %d", name, prev.Kind, prev.Line))
+	}
+	p.regAliases[name] = regAlias{tokens: append([]Token(nil), tokens...), line: nameTok.Line}
+	// Lookahead and pending macro expansions may already hold the name.
+	pending := p.buf
+	p.buf = nil
+	p.prependTokens(pending)
+	return nil
 }
 func (p *Parser) next() Token {
 	p.fill(1)
 	t := p.buf[0]
 	p.buf = p.buf[1:]
-	p.line, p.col = t.Line, t.Col
+	p.line, p.col, p.lineOrigin, p.lastKind = t.Line, t.firstCol(), t.origin, t.Kind
+	if p.pp != nil {
+		p.pp.record(t)
+	}
 	return t
 }
 func (p *Parser) peek() Token {
@@ -53,3 +53,79 @@ func TestConstDefinitionWithEqu(t *testing.T) {
 		t.Fatalf("unexpected output: got %x want %x", out, want)
 	}
 }
+
+func TestSetSymbolsCanBeReassigned(t *testing.T) {
+	tests := []struct {
+		name   string
+		src    string
+		syntax asm.Syntax
+		want   []byte
+	}{
+		{"SetKeyword", "n SET 1\n.byte n\nn SET n+1\n.byte n\n", asm.SyntaxMotorola, []byte{1, 2}},
+		{"DotSetDirective", ".set n, 5\n.set n, n*2\n.byte n\n", asm.SyntaxMotorola, []byte{10}},
+		{"GasEquals", "n = 1\nn = n+1\n.byte n\n", asm.SyntaxGAS, []byte{2}},
+		{
+			"MacroCounter",
+			".macro COUNT\nn SET n+1\n.endmacro\nn SET 0\nCOUNT\nCOUNT\nCOUNT\n.byte n\n",
+			asm.SyntaxMotorola,
+			[]byte{3},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := assembleWithSyntax(t, tt.src, tt.syntax)
+			if !bytes.Equal(got, tt.want) {
+				t.Fatalf("unexpected output: got %x want %x", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSymbolRedefinitionErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"EquTwice", "A EQU 1\nA EQU 2\n", "A already defined as a constant at line 1"},
+		{"EqualsTwice", "A = 1\nA = 1\n", "A already defined as a constant at line 1"},
+		{"LabelTwice", "a: NOP\na: NOP\n", "a already defined as a label at line 1"},
+		{"LabelThenEqu", "a: NOP\na EQU 1\n", "a already defined as a label"},
+		{"SetThenEqu", "A SET 1\nA EQU 2\n", "A already defined as a variable"},
+		{"EquThenSet", "A EQU 1\n.set A, 2\n", "A already defined as a constant"},
+		{"RsTwice", "A RS.W 1\nA RS.W 1\n", "A already defined as a constant"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := asm.Parse(strings.NewReader(tt.src))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestDefinedSymbolKinds(t *testing.T) {
+	src := "SIZE EQU 4\nn SET 1\nn SET 2\nstart: NOP\n"
+
+	prog, err := asm.Parse(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	want := map[string]struct {
+		kind  asm.SymbolKind
+		value uint32
+	}{
+		"SIZE":  {asm.SymbolConstant, 4},
+		"n":     {asm.SymbolVariable, 2},
+		"start": {asm.SymbolLabel, 0},
+	}
+	if len(prog.DefinedLabels) != len(want) {
+		t.Fatalf("unexpected symbols %+v", prog.DefinedLabels)
+	}
+	for _, l := range prog.DefinedLabels {
+		if w := want[l.Name]; l.Kind != w.kind || l.Addr != w.value {
+			t.Fatalf("symbol %s: got %s %d, want %s %d", l.Name, l.Kind, l.Addr, w.kind, w.value)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package asm
+
+import (
+	"math"
+	"strings"
+
+	"github.com/jenska/m68kasm/internal/asm/instructions"
+)
+
+// pcRelativeSource turns an absolute source operand without a size suffix
+// that names a .text label into label(PC), for position-independent code.
+// The operand keeps its absolute form when the instruction does not accept a
+// PC-relative source or the displacement does not reach. Labels in other
+// sections stay absolute, as those may be loaded apart from the code.
+func (p *Parser) pcRelativeSource(form *instructions.FormDef, args instructions.Args) instructions.Args {
+	if !p.pcRelative || !args.Src.AutoSize || !sourceExtensionFirst(form) {
+		return args
+	}
+	e, ok := args.Src.Expr.(*Expr)
+	if !ok {
+		return args
+	}
+	if class, section := e.Class(); class != ExprRelocatable || section != SectionText {
+		return args
+	}
+	pcArgs := args
+	pcArgs.Src = instructions.EAExpr{Kind: instructions.EAkPCDisp16, Disp16: int32(e.Value()), Expr: e, AutoSize: true}
+	if form.Validate != nil {
+		// Validate may move a single operand to Dst, so it gets a copy.
+		check := pcArgs
+		if form.Validate(&check) != nil {
+			return args
+		}
+	}
+	disp := e.Value() - int64(p.pc) - 2
+	reach := disp >= math.MinInt16 && disp <= math.MaxInt16 && p.symbolsKnown(e)
+	// 0 picks the PC-relative form, 1 keeps the absolute one.
+	if p.layoutChoice(boolChoice(!reach)) != 0 {
+		return args
+	}
+	return pcArgs
+}
+
+// sourceExtensionFirst reports whether the extension words of the source
+// operand directly follow the opcode word, which is where the encoder
+// measures PC-relative displacements from. Single-operand forms emit their
+// operand as the destination.
+func sourceExtensionFirst(form *instructions.FormDef) bool {
+	words := 0
+	for _, step := range form.Steps {
+		if step.WordBits != 0 || len(step.Fields) > 0 {
+			words++
+		}
+		if len(step.Trailer) > 0 {
+			first := step.Trailer[0]
+			return words == 1 && (first == instructions.TSrcEAExt ||
+				(first == instructions.TDstEAExt && len(form.OperKinds) == 1))
+		}
+	}
+	return false
+}
+
+// auditOperands reports absolute operands and immediates that hold the
+// address of a .text label, which ties the code to its load address.
+func (p *Parser) auditOperands(line int, args *instructions.Args) {
+	for _, ea := range []instructions.EAExpr{args.Src, args.Dst} {
+		switch ea.Kind {
+		case instructions.EAkAbsW, instructions.EAkAbsL, instructions.EAkImm:
+			if e, ok := ea.Expr.(*Expr); ok {
+				p.auditAbsolute(line, e)
+			}
+		}
+	}
+}
+
+// auditData reports data values that hold the address of a .text label.
+func (p *Parser) auditData(exprs []DataExpr) {
+	for _, d := range exprs {
+		p.auditAbsolute(p.line, d.Expr)
+	}
+}
+
+func (p *Parser) auditAbsolute(line int, e *Expr) {
+	if !p.warnings[WarnPIC] || p.allowForwardRefs {
+		return
+	}
+	if class, section := e.Class(); class != ExprRelocatable || section != SectionText {
+		return
+	}
+	p.warn(WarnPIC, line, "absolute reference to code label: %s", e)
+}
+
+// parseOPT applies the options this assembler knows (PCREL, NOPCREL) and
+// ignores the others, which are meant for other assemblers.
+func parseOPT(p *Parser) error {
+	tokens := p.consumeUntilEOL()
+	defer p.releaseTokens(tokens)
+	pcRelative := p.pcRelative
+	for i, t := range tokens {
+		if t.Kind != IDENT || (i > 0 && tokens[i-1].Kind != COMMA) {
+			continue
+		}
+		switch strings.ToUpper(t.Text) {
+		case "PCREL":
+			pcRelative = true
+		case "NOPCREL":
+			pcRelative = false
+		}
+	}
+	p.place(func(p *Parser) error {
+		p.pcRelative = pcRelative
+		return nil
+	})
+	return nil
+}
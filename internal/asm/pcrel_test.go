@@ -0,0 +1,69 @@
+package asm_test
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/jenska/m68kasm/internal/asm"
+)
+
+func TestPCRelative(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want []byte
+	}{
+		{"Load", "LEA tab,A0\ntab: NOP\n", []byte{0x41, 0xFA, 0x00, 0x02, 0x4E, 0x71}},
+		{"MoveSource", "tab: NOP\nMOVE.W tab,D0\n", []byte{0x4E, 0x71, 0x30, 0x3A, 0xFF, 0xFC}},
+		{"Jump", "JSR sub\nNOP\nsub: RTS\n", []byte{0x4E, 0xBA, 0x00, 0x04, 0x4E, 0x71, 0x4E, 0x75}},
+		{"DestinationStaysAbsolute", "tab: MOVE.W D0,tab\n", []byte{0x31, 0xC0, 0x00, 0x00}},
+		{"AlterableStaysAbsolute", "tab: CLR.W tab\n", []byte{0x42, 0x78, 0x00, 0x00}},
+		{"MaskWordFirstStaysAbsolute", "tab: MOVEM.L tab,D0-D1\n", []byte{0x4C, 0xF8, 0x00, 0x03, 0x00, 0x00}},
+		{"ConstantStaysAbsolute", "io EQU $FFFF8240\nMOVE.W io,D0\n", []byte{0x30, 0x38, 0x82, 0x40}},
+		{"SizedStaysAbsolute", "tab: MOVE.W (tab).W,D0\n", []byte{0x30, 0x38, 0x00, 0x00}},
+		{"DataLabelStaysAbsolute", "MOVE.W var,D0\n.data\nvar: DC.W 0\n", []byte{0x30, 0x38, 0x00, 0x04, 0x00, 0x00}},
+		{"FarLabelStaysAbsolute", "LEA tab,A0\n.ALIGN 65536\ntab: NOP\n", append(append([]byte{0x41, 0xF9, 0x00, 0x01, 0x00, 0x00}, make([]byte, 65530)...), 0x4E, 0x71)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prog, err := asm.ParseWithOptions(strings.NewReader(tt.src), asm.ParseOptions{PCRelative: true})
+			if err != nil {
+				t.Fatalf("parse failed: %v", err)
+			}
+			got, err := asm.Assemble(prog)
+			if err != nil {
+				t.Fatalf("assemble failed: %v", err)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Fatalf("unexpected encoding: got %x want %x", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPCRelativeOption(t *testing.T) {
+	src := "start: LEA start,A0\n.opt pcrel\nLEA start,A0\nOPT O+,NOPCREL\nLEA start,A0\n"
+	got := assembleSource(t, src)
+	want := []byte{0x41, 0xF8, 0x00, 0x00, 0x41, 0xFA, 0xFF, 0xFA, 0x41, 0xF8, 0x00, 0x00}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("unexpected encoding: got %x want %x", got, want)
+	}
+}
+
+func TestAuditPIC(t *testing.T) {
+	src := "start: LEA start(PC),A0\nMOVE.L #start,A1\nJMP sub\nsub: RTS\nDC.L start,sub-start\nio EQU $FF8240\nMOVE.W io,D0\n"
+	prog, err := asm.ParseWithOptions(strings.NewReader(src), asm.ParseOptions{AuditPIC: true})
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	want := []string{
+		"line 2: warning: absolute reference to code label: start [-Wpic]",
+		"line 3: warning: absolute reference to code label: sub [-Wpic]",
+		"line 5: warning: absolute reference to code label: start [-Wpic]",
+	}
+	if got := warningLines(prog); !slices.Equal(got, want) {
+		t.Fatalf("warnings = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,201 @@
+package asm
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Preprocess writes the source read from r as the parser sees it after
+// conditional assembly and macro expansion. Every statement is written on a
+// line of its own, and "# line file" markers map the output back to the
+// original source, so the result can be assembled again with the same
+// options. name is the file name used in the markers. Nothing is written if
+// the source has errors.
+func Preprocess(w io.Writer, r io.Reader, name string, opts ParseOptions) error {
+	src, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	pp := &preprocessor{file: name, line: 1}
+	if _, err := parseSource(src, opts, pp); err != nil {
+		return err
+	}
+	_, err = w.Write(pp.out.Bytes())
+	return err
+}
+
+// PreprocessFile is Preprocess for the file at path.
+func PreprocessFile(w io.Writer, path string, opts ParseOptions) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	opts.IncludeDirs = append([]string{filepath.Dir(path)}, opts.IncludeDirs...)
+	return Preprocess(w, f, path, opts)
+}
+
+// preprocessor records the statements accepted in the final pass and renders
+// them as source text.
+type preprocessor struct {
+	out  bytes.Buffer
+	file string
+	// line is the source line the next output line maps to without a marker.
+	line int
+	// tokens holds the statement being parsed; the first labelEnd of them
+	// are its label.
+	tokens   []Token
+	labelEnd int
+	// drop is set by statements that have been fully applied, such as macro
+	// definitions.
+	drop bool
+}
+
+// fresh returns an empty preprocessor for one pass, or nil if pp is nil.
+func (pp *preprocessor) fresh() *preprocessor {
+	if pp == nil {
+		return nil
+	}
+	return &preprocessor{file: pp.file, line: pp.line}
+}
+
+// adopt takes the output of the final pass.
+func (pp *preprocessor) adopt(pass *preprocessor) {
+	if pp != nil {
+		pp.out.Write(pass.out.Bytes())
+	}
+}
+
+func (pp *preprocessor) begin() {
+	if pp == nil {
+		return
+	}
+	pp.tokens = pp.tokens[:0]
+	pp.labelEnd = 0
+	pp.drop = false
+}
+
+func (pp *preprocessor) record(t Token) {
+	if t.Kind != NEWLINE && t.Kind != MACROEND && t.Kind != EOF {
+		pp.tokens = append(pp.tokens, t)
+	}
+}
+
+// omit leaves the current statement out of the output.
+func (pp *preprocessor) omit() {
+	if pp != nil {
+		pp.drop = true
+	}
+}
+
+func (pp *preprocessor) markLabel() {
+	if pp == nil {
+		return
+	}
+	pp.labelEnd = len(pp.tokens)
+}
+
+// emit writes the recorded statement, or only its label when the rest was
+// consumed by a macro invocation or definition.
+func (pp *preprocessor) emit(labelOnly bool) {
+	if pp == nil {
+		return
+	}
+	tokens := pp.tokens
+	if labelOnly || pp.drop {
+		tokens = tokens[:pp.labelEnd]
+	}
+	if len(tokens) == 0 {
+		return
+	}
+	line := tokens[0].Line
+	if o := tokens[0].origin; o != nil {
+		line = o.line
+	}
+	if line != pp.line {
+		fmt.Fprintf(&pp.out, "# %d %s\n", line, strconv.Quote(pp.file))
+	}
+	pp.line = line + 1
+
+	label, stmt := tokens[:min(pp.labelEnd, len(tokens))], tokens[min(pp.labelEnd, len(tokens)):]
+	pp.out.WriteString(preprocessedText(label))
+	if len(stmt) > 0 {
+		// The mnemonic or directive is separated from its operands.
+		head := 1
+		if stmt[0].Kind == DOT && len(stmt) > 1 {
+			head = 2
+		}
+		pp.out.WriteByte('\t')
+		pp.out.WriteString(preprocessedText(stmt[:head]))
+		if len(stmt) > head {
+			pp.out.WriteByte(' ')
+			pp.out.WriteString(preprocessedText(stmt[head:]))
+		}
+	}
+	pp.out.WriteByte('\n')
+}
+
+// preprocessedText is tokensText with character literals that cannot be
+// written back verbatim turned into numbers.
+func preprocessedText(tokens []Token) string {
+	for i, t := range tokens {
+		if t.Kind == NUMBER && strings.HasPrefix(t.Text, "'") && (t.Val < ' ' || t.Val > '~' || t.Val == '\'' || t.Val == '\\') {
+			tokens = append([]Token(nil), tokens...)
+			tokens[i].Text = strconv.FormatInt(t.Val, 10)
+		}
+	}
+	return tokensText(tokens)
+}
+
+// lineMarker parses a "# line file" marker and returns the line number of
+// the line that follows it.
+func lineMarker(text string) (int, bool) {
+	rest, ok := strings.CutPrefix(text, "# ")
+	if !ok {
+		return 0, false
+	}
+	end := 0
+	for end < len(rest) && rest[end] >= '0' && rest[end] <= '9' {
+		end++
+	}
+	n, err := strconv.Atoi(rest[:end])
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
+
+// markedSourceLines indexes lines by the line numbers line markers assign
+// to them, so diagnostics on preprocessed source quote the right text. The
+// first line mapped to a number wins.
+func markedSourceLines(lines []string) []string {
+	var out []string
+	line, marked := 1, false
+	for _, text := range lines {
+		if n, ok := lineMarker(text); ok {
+			line, marked = n, true
+			continue
+		}
+		if line < 1 {
+			line++
+			continue
+		}
+		for len(out) < line {
+			out = append(out, "")
+		}
+		if out[line-1] == "" {
+			out[line-1] = text
+		}
+		line++
+	}
+	if !marked {
+		return lines
+	}
+	return out
+}
@@ -0,0 +1,86 @@
+package asm_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/jenska/m68kasm/internal/asm"
+)
+
+func TestPreprocessExpandsMacrosAndConditionals(t *testing.T) {
+	src := "; comment\n" +
+		".macro LOAD v, r=D0\n" +
+		"MOVE.L v,r\n" +
+		".endmacro\n" +
+		"FLAG = 0\n" +
+		"start: LOAD #2\n" +
+		".if FLAG\n" +
+		"NOP\n" +
+		".else\n" +
+		"RTS\n" +
+		".endif\n" +
+		".byte \"ok\", '\\n'\n"
+
+	var out bytes.Buffer
+	if err := asm.Preprocess(&out, strings.NewReader(src), "in.s", asm.ParseOptions{}); err != nil {
+		t.Fatalf("preprocess: %v", err)
+	}
+	want := "# 5 \"in.s\"\n" +
+		"\tFLAG =0\n" +
+		"start:\n" +
+		"# 3 \"in.s\"\n" +
+		"\tMOVE.L #2,D0\n" +
+		"# 10 \"in.s\"\n" +
+		"\tRTS\n" +
+		"# 12 \"in.s\"\n" +
+		"\t.byte \"ok\",10\n"
+	if out.String() != want {
+		t.Fatalf("unexpected output:\n%s\nwant:\n%s", out.String(), want)
+	}
+
+	orig := assembleSource(t, src)
+	again := assembleSource(t, out.String())
+	if !bytes.Equal(orig, again) {
+		t.Fatalf("preprocessed source assembled to %x, want %x", again, orig)
+	}
+}
+
+func TestPreprocessGeneratedNames(t *testing.T) {
+	src := ".macro WAIT\nl\\@: NOP\nBRA.S l\\@\n.endmacro\nWAIT\nWAIT\n"
+
+	var out bytes.Buffer
+	if err := asm.Preprocess(&out, strings.NewReader(src), "in.s", asm.ParseOptions{}); err != nil {
+		t.Fatalf("preprocess: %v", err)
+	}
+	if !strings.Contains(out.String(), "l_$002:") {
+		t.Fatalf("expected the generated name in the output:\n%s", out.String())
+	}
+	orig := assembleSource(t, src)
+	again := assembleSource(t, out.String())
+	if !bytes.Equal(orig, again) {
+		t.Fatalf("preprocessed source assembled to %x, want %x", again, orig)
+	}
+}
+
+func TestPreprocessLineMarkersMapDiagnostics(t *testing.T) {
+	src := "# 40 \"orig.s\"\n\tNOP\n\tBOGUS D0\n"
+
+	_, err := asm.Parse(strings.NewReader(src))
+	var asmErr *asm.Error
+	if !errors.As(err, &asmErr) {
+		t.Fatalf("expected *asm.Error, got %v", err)
+	}
+	if asmErr.Line != 41 || asmErr.LineText != "\tBOGUS D0" {
+		t.Fatalf("expected error at line 41, got line %d %q", asmErr.Line, asmErr.LineText)
+	}
+}
+
+func TestPreprocessReportsErrors(t *testing.T) {
+	var out bytes.Buffer
+	err := asm.Preprocess(&out, strings.NewReader(".if 1\nNOP\n"), "in.s", asm.ParseOptions{})
+	if err == nil || out.Len() != 0 {
+		t.Fatalf("expected error and no output, got %v and %q", err, out.String())
+	}
+}
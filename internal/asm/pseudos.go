@@ -1,34 +1,102 @@
 package asm
 
 import (
+	"bytes"
 	"fmt"
+	"math"
+	"os"
+	"path/filepath"
 	"strings"
 )
 
 var pseudoMap = map[string]func(*Parser) error{
-	".ORG":     parseORG,
-	".BYTE":    parseBYTE,
-	".WORD":    parseWORD,
-	".LONG":    parseLONG,
-	".ALIGN":   parseALIGN,
-	".EVEN":    parseEVEN,
-	".MACRO":   parseMACRO,
-	".TEXT":    parseTEXT,
-	".DATA":    parseDATA,
-	".BSS":     parseBSS,
-	".SECTION": parseSECTION,
+	".ORG":          parseORG,
+	".BYTE":         parseBYTE,
+	".WORD":         parseWORD,
+	".LONG":         parseLONG,
+	".ALIGN":        parseALIGN,
+	".EVEN":         parseEVEN,
+	".MACRO":        parseMACRO,
+	".TEXT":         parseTEXT,
+	".DATA":         parseDATA,
+	".BSS":          parseBSS,
+	".SECTION":      parseSECTION,
+	".GLOBL":        parseGLOBL,
+	".GLOBAL":       parseGLOBL,
+	".SHORT":        parseWORD,
+	".ASCII":        parseASCII,
+	".ASCIZ":        parseASCIZ,
+	".XDEF":         parseGLOBL,
+	".XREF":         parseIgnoredLine,
+	".OPT":          parseOPT,
+	".END":          parseEND,
+	".ENDM":         parseStrayENDM,
+	".LOCAL":        parseLOCAL,
+	".EXITM":        parseEXITM,
+	".MEXIT":        parseEXITM,
+	".CNOP":         parseCNOP,
+	".RSRESET":      parseRSRESET,
+	".RSSET":        parseRSSET,
+	".STRUCT":       parseSTRUCT,
+	".NAMESPACE":    parseNAMESPACE,
+	".ENDNAMESPACE": parseENDNAMESPACE,
+	".MODULE":       parseNAMESPACE,
+	".ENDMODULE":    parseENDNAMESPACE,
+	".ENDS":         parseStrayENDS,
+	".OBJ":          parseOBJ,
+	".OBJEND":       parseOBJEND,
+	".INCBIN":       parseINCBIN,
+	".INFORM":       parseINFORM,
+	".SET":          func(p *Parser) error { return parseSymbolAssignment(p, SymbolVariable) },
+	".EQU":          func(p *Parser) error { return parseSymbolAssignment(p, SymbolConstant) },
+}
+
+// sizedDirectives take a size suffix like DC.x; a missing suffix means .W.
+var sizedDirectives = map[string]func(*Parser, string) error{
+	"DS":  parseDS,
+	"DCB": parseDCB,
+	"RS":  func(p *Parser, suffix string) error { return parseRS(p, nil, suffix) },
+}
+
+// namingDirectives define the symbol written in front of them
+// ("name RS.W 1", "ptr EQUR A2", "name MACRO").
+var namingDirectives = map[string]func(p *Parser, name Token) error{
+	"RS":    parseNamedRS,
+	"EQUR":  parseEQUR,
+	"REG":   parseREG,
+	"MACRO": parseNamedMACRO,
+}
+
+// namingDirective reports whether the statement has the form "name DIRECTIVE".
+func (p *Parser) namingDirective() (func(*Parser, Token) error, bool) {
+	next := p.peekN(2)
+	if next.Kind != IDENT {
+		return nil, false
+	}
+	base, _ := splitMnemonic(next.Text)
+	directive, ok := namingDirectives[base]
+	if !ok {
+		return nil, false
+	}
+	if first, _ := splitMnemonic(p.peek().Text); p.instrs.Lookup(first) != nil {
+		return nil, false
+	}
+	return directive, true
 }
 
 func parseTEXT(p *Parser) error {
-	return p.setSection(SectionText)
+	p.switchSection(SectionText)
+	return nil
 }
 
 func parseDATA(p *Parser) error {
-	return p.setSection(SectionData)
+	p.switchSection(SectionData)
+	return nil
 }
 
 func parseBSS(p *Parser) error {
-	return p.setSection(SectionBSS)
+	p.switchSection(SectionBSS)
+	return nil
 }
 
 func parseSECTION(p *Parser) error {
@@ -37,10 +105,73 @@ func parseSECTION(p *Parser) error {
 		return err
 	}
 	section, ok := parseSectionName(name)
+	// Devpac names the section freely and gives its type after the comma
+	// (SECTION main,CODE_C); gas-style flags (.section .text,"ax",@progbits)
+	// carry no extra meaning for the fixed text/data/bss layout.
+	if p.accept(COMMA) {
+		if t := p.peek(); t.Kind == IDENT {
+			if typed, isType := parseSectionType(t.Text); isType {
+				section, ok = typed, true
+			}
+		}
+		p.releaseTokens(p.consumeUntilEOL())
+	}
 	if !ok {
-		return contextualizeAt(p.line, p.col, fmt.Errorf("unsupported section %q", name))
+		return contextualizeAt(p.line, p.col, withCode(CodeSection, fmt.Errorf("unsupported section %q", name)))
+	}
+	p.switchSection(section)
+	return nil
+}
+
+// .globl <label>[, <label>]...
+func parseGLOBL(p *Parser) error {
+	for {
+		name, err := p.want(IDENT)
+		if err != nil {
+			return err
+		}
+		p.globals[p.resolveSymbol(name.Text)] = true
+		if !p.accept(COMMA) {
+			return nil
+		}
+	}
+}
+
+// .ascii "<text>"[, "<text>"]...
+func parseASCII(p *Parser) error {
+	return parseStrings(p, ".ascii", false)
+}
+
+// .asciz "<text>"[, "<text>"]... appends a NUL byte to every string.
+func parseASCIZ(p *Parser) error {
+	return parseStrings(p, ".asciz", true)
+}
+
+func parseStrings(p *Parser, directive string, terminate bool) error {
+	col := p.col
+	var out []byte
+	for {
+		t, err := p.want(STRING)
+		if err != nil {
+			return err
+		}
+		out = append(out, t.Text...)
+		if terminate {
+			out = append(out, 0)
+		}
+		if !p.accept(COMMA) {
+			break
+		}
 	}
-	return p.setSection(section)
+	p.place(func(p *Parser) error {
+		if p.section == SectionBSS && !p.allowForwardRefs {
+			return contextualizeAt(p.line, p.col, fmt.Errorf("%s in %s must be zero-initialized", directive, p.section.Name()))
+		}
+		p.items = append(p.items, &DataBytes{Bytes: out, PC: p.pc, Line: p.line, Col: col, Section: p.section})
+		p.pc += uint32(len(out))
+		return nil
+	})
+	return nil
 }
 
 func parseSectionOperand(p *Parser) (string, error) {
@@ -60,20 +191,32 @@ func parseSectionOperand(p *Parser) (string, error) {
 }
 
 func parseORG(p *Parser) error {
-	val, err := p.parseExpr()
+	val, err := p.parseExprInfo()
 	if err != nil {
 		return err
 	}
-	newPC := uint32(val)
+	p.place(func(p *Parser) error {
+		val, err := p.evaluate(val)
+		if err != nil {
+			return err
+		}
+		return p.org(uint32(val.Value))
+	})
+	return nil
+}
 
+// org moves the location counter to newPC.
+func (p *Parser) org(newPC uint32) error {
 	if newPC > maxProgramSize {
-		return contextualizeAt(p.line, p.col, fmt.Errorf(".org would exceed maximum program size of %d bytes", maxProgramSize))
+		return contextualizeAt(p.line, p.col, withCode(CodeLayout, fmt.Errorf(".org would exceed maximum program size of %d bytes", maxProgramSize)))
 	}
+	p.checkOrgLabel(newPC)
 
 	if !p.hasOrg && p.pc == 0 {
 		p.origin = newPC
 		p.hasOrg = true
 		p.pc = newPC
+		p.sections[p.section].start = newPC
 		return nil
 	}
 	if !p.hasOrg {
@@ -82,7 +225,7 @@ func parseORG(p *Parser) error {
 	}
 
 	if newPC < p.pc {
-		return contextualizeAt(p.line, p.col, fmt.Errorf(".org cannot move backwards (pc=%d -> %d)", p.pc, newPC))
+		return contextualizeAt(p.line, p.col, withCode(CodeLayout, fmt.Errorf(".org cannot move backwards (pc=%d -> %d)", p.pc, newPC)))
 	}
 
 	if newPC > p.pc {
@@ -95,116 +238,99 @@ func parseORG(p *Parser) error {
 	return nil
 }
 
+// .byte <expr|string>[, <expr|string>]...
 func parseBYTE(p *Parser) error {
-	col := p.col
-	val, err := p.parseExpr()
-	if err != nil {
-		return err
-	}
-	if err := ensureBSSValue(p, val, ".byte"); err != nil {
-		return err
-	}
-	var bytes []byte
-	bytes = append(bytes, byte(val))
-	for p.accept(COMMA) {
-		v, err := p.parseExpr()
-		if err != nil {
-			return err
-		}
-		if err := ensureBSSValue(p, v, ".byte"); err != nil {
-			return err
-		}
-		bytes = append(bytes, byte(v))
-	}
-	p.items = append(p.items, &DataBytes{Bytes: bytes, PC: p.pc, Line: p.line, Col: col, Section: p.section})
-	p.pc += uint32(len(bytes))
-	return nil
+	return parseData(p, 1, ".byte")
 }
 
 // .word <expr>[, <expr>]...
 func parseWORD(p *Parser) error {
-	col := p.col
-	// first value
-	v, err := p.parseExpr()
-	if err != nil {
-		return err
-	}
-
-	out := make([]byte, 0, 2*4)
-	if v < -0x8000 || v > 0xFFFF {
-		return contextualizeAt(p.line, p.col, fmt.Errorf(".word value out of 16-bit range: %d", v))
-	}
-	if err := ensureBSSValue(p, v, ".word"); err != nil {
-		return err
-	}
-	w := uint16(int16(v))
-	out = append(out, byte(w>>8), byte(w))
-
-	// subsequent values
-	for p.accept(COMMA) { // use lex.COMMA if you still prefix tokens
-		v, err := p.parseExpr()
-		if err != nil {
-			return err
-		}
-		if v < -0x8000 || v > 0xFFFF {
-			return contextualizeAt(p.line, p.col, fmt.Errorf(".word value out of 16-bit range: %d", v))
-		}
-		if err := ensureBSSValue(p, v, ".word"); err != nil {
-			return err
-		}
-		w := uint16(int16(v))
-		out = append(out, byte(w>>8), byte(w))
-	}
-
-	p.items = append(p.items, &DataBytes{Bytes: out, PC: p.pc, Line: p.line, Col: col, Section: p.section})
-	p.pc += uint32(len(out))
-	return nil
+	return parseData(p, 2, ".word")
 }
 
 // .long <expr>[, <expr>]...
 func parseLONG(p *Parser) error {
-	col := p.col
-	v, err := p.parseExpr()
-	if err != nil {
-		return err
-	}
+	return parseData(p, 4, ".long")
+}
 
-	out := make([]byte, 0, 4*4)
-	if v < -0x80000000 || v > 0xFFFFFFFF {
-		return contextualizeAt(p.line, p.col, fmt.Errorf(".long value out of range: %d", v))
-	}
-	if err := ensureBSSValue(p, v, ".long"); err != nil {
-		return err
-	}
-	u := uint32(v) // two's complement when v is negative
-	out = append(out, byte(u>>24), byte(u>>16), byte(u>>8), byte(u))
+// dataValue is an operand of a data directive: a string, which .byte emits
+// as its characters, or an expression. col is where its errors point.
+type dataValue struct {
+	str   string
+	isStr bool
+	expr  exprInfo
+	col   int
+}
 
-	for p.accept(COMMA) {
-		v, err := p.parseExpr()
-		if err != nil {
-			return err
-		}
-		if v < -0x80000000 || v > 0xFFFFFFFF {
-			return contextualizeAt(p.line, p.col, fmt.Errorf(".long value out of range: %d", v))
+// parseData parses the values of a data directive whose values have size
+// bytes each.
+func parseData(p *Parser, size int, directive string) error {
+	col := p.col
+	var values []dataValue
+	for {
+		if t := p.peek(); size == 1 && t.Kind == STRING && isOperandEnd(p.peekN(2).Kind) {
+			p.next()
+			values = append(values, dataValue{str: t.Text, isStr: true, col: p.col})
+		} else {
+			v, err := p.parseExprInfo()
+			if err != nil {
+				return err
+			}
+			values = append(values, dataValue{expr: v, col: p.col})
 		}
-		if err := ensureBSSValue(p, v, ".long"); err != nil {
-			return err
+		if !p.accept(COMMA) {
+			break
 		}
-		u := uint32(v)
-		out = append(out, byte(u>>24), byte(u>>16), byte(u>>8), byte(u))
 	}
-
-	p.items = append(p.items, &DataBytes{Bytes: out, PC: p.pc, Line: p.line, Col: col, Section: p.section})
-	p.pc += uint32(len(out))
+	p.place(func(p *Parser) error {
+		out := make([]byte, 0, size*len(values))
+		var exprs []DataExpr
+		for _, d := range values {
+			if d.isStr {
+				if err := ensureBSSValue(p, int64(len(d.str)), d.col, directive); err != nil {
+					return err
+				}
+				out = append(out, d.str...)
+				continue
+			}
+			v, err := p.evaluate(d.expr)
+			if err != nil {
+				return err
+			}
+			if err := p.checkFit(v.Value, uint(8*size), directive); err != nil {
+				return contextualizeAt(p.line, d.col, err)
+			}
+			if err := ensureBSSValue(p, v.Value, d.col, directive); err != nil {
+				return err
+			}
+			exprs = appendDataExpr(exprs, len(out), size, v)
+			for i := size - 1; i >= 0; i-- {
+				out = append(out, byte(v.Value>>(8*i)))
+			}
+		}
+		p.auditData(exprs)
+		p.items = append(p.items, &DataBytes{Bytes: out, PC: p.pc, Line: p.line, Col: col, Section: p.section, Exprs: exprs})
+		p.pc += uint32(len(out))
+		return nil
+	})
 	return nil
 }
 
-func ensureBSSValue(p *Parser, v int64, directive string) error {
+// appendDataExpr records a data value that refers to symbols, so the
+// assembler can evaluate it again.
+func appendDataExpr(exprs []DataExpr, offset, size int, v exprInfo) []DataExpr {
+	if !v.HasSymbol {
+		return exprs
+	}
+	return append(exprs, DataExpr{Offset: offset, Size: size, Expr: v.Expr})
+}
+
+func ensureBSSValue(p *Parser, v int64, col int, directive string) error {
 	if p.section != SectionBSS || p.allowForwardRefs {
 		return nil
 	}
 	if v != 0 {
-		return contextualizeAt(p.line, p.col, fmt.Errorf("%s in %s must be zero-initialized", directive, p.section.Name()))
+		return contextualizeAt(p.line, col, fmt.Errorf("%s in %s must be zero-initialized", directive, p.section.Name()))
 	}
 	return nil
 }
@@ -212,41 +338,65 @@ func ensureBSSValue(p *Parser, v int64, directive string) error {
 // .align <expr>[, <fill>]
 func parseALIGN(p *Parser) error {
 	// alignment value
-	val, err := p.parseExpr()
+	val, err := p.parseExprInfo()
 	if err != nil {
 		return err
 	}
-	if val < 1 {
-		return contextualizeAt(p.line, p.col, fmt.Errorf(".align expects value >= 1, got %d", val))
-	}
-	align := uint32(val)
+	col := p.col
 
 	// optional fill
-	fill := byte(0x00)
+	var fill *exprInfo
 	if p.accept(COMMA) { // if you still use prefixed tokens, use lex.COMMA
-		fv, err := p.parseExpr()
+		fv, err := p.parseExprInfo()
 		if err != nil {
 			return err
 		}
-		fill = byte(fv) // truncate to 8-bit
+		fill = &fv
 	}
 
-	// compute padding
-	m := p.pc % align
-	if m == 0 {
-		return nil // already aligned, emit nothing
-	}
-	pad := align - m
+	p.place(func(p *Parser) error {
+		val, err := p.evaluate(val)
+		if err != nil {
+			return err
+		}
+		if val.Value < 1 {
+			return contextualizeAt(p.line, col, fmt.Errorf(".align expects value >= 1, got %d", val.Value))
+		}
+		align := uint32(val.Value)
 
-	// emit pad bytes of 'fill'
-	return p.emitPaddingBytes(pad, fill)
+		fillByte := byte(0x00)
+		if fill != nil {
+			fv, err := p.evaluate(*fill)
+			if err != nil {
+				return err
+			}
+			if err := p.checkFit(fv.Value, 8, ".align fill"); err != nil {
+				return p.operandError(fv, err)
+			}
+			fillByte = byte(fv.Value)
+		}
+
+		// compute padding
+		m := p.pc % align
+		if m == 0 {
+			return nil // already aligned, emit nothing
+		}
+		pad := align - m
+
+		// emit pad bytes of 'fill'
+		return p.emitPaddingBytes(pad, fillByte)
+	})
+	return nil
 }
 
 func parseEVEN(p *Parser) error {
-	if p.pc%2 == 0 {
-		return nil
-	}
-	return p.emitPaddingBytes(1, 0x00)
+	p.place(func(p *Parser) error {
+		if p.pc%2 == 0 {
+			return nil
+		}
+		return p.emitPaddingBytes(1, 0x00)
+	})
+	return nil
 }
 
 func parseDC(p *Parser, suffix string) error {
@@ -259,28 +409,47 @@ func parseDC(p *Parser, suffix string) error {
 	case "L":
 		return parseLONG(p)
 	default:
-		return fmt.Errorf("unknown DC size .%s", suffix)
+		return withCode(CodeInvalidSize, fmt.Errorf("unknown DC size .%s", suffix))
 	}
 }
 
+func isOperandEnd(k Kind) bool {
+	return k == COMMA || k == NEWLINE || k == EOF
+}
+
 func parseMACRO(p *Parser) error {
 	nameTok, err := p.want(IDENT)
 	if err != nil {
 		return err
 	}
+	return parseMacroDefinition(p, nameTok)
+}
+
+// name MACRO [param[, param ...]] (Devpac order)
+func parseNamedMACRO(p *Parser, nameTok Token) error {
+	_ = p.next() // MACRO
+	return parseMacroDefinition(p, nameTok)
+}
 
-	params := []string{}
+func parseMacroDefinition(p *Parser, nameTok Token) error {
+	p.pp.omit()
+	params := []macroParam{}
 	for {
 		t := p.peek()
 		if t.Kind == NEWLINE || t.Kind == EOF {
 			_ = p.next()
 			break
 		}
-		paramTok, err := p.want(IDENT)
+		param, err := parseMacroParam(p)
 		if err != nil {
 			return err
 		}
-		params = append(params, paramTok.Text)
+		for _, prev := range params {
+			if prev.vararg {
+				return parserError(t, "vararg parameter must be last")
+			}
+		}
+		params = append(params, param)
 		if !p.accept(COMMA) {
 			if p.peek().Kind == NEWLINE {
 				_ = p.next()
@@ -290,24 +459,572 @@ func parseMACRO(p *Parser) error {
 	}
 
 	body := []Token{}
+	var locals []string
+	lineStart := true
 	for {
 		t := p.next()
 		if t.Kind == EOF {
-			return contextualizeAt(nameTok.Line, nameTok.Col, fmt.Errorf("unexpected EOF inside macro"))
+			return contextualizeAt(nameTok.Line, nameTok.firstCol(), withCode(CodeUnbalancedBlock, fmt.Errorf("unexpected EOF inside macro")))
 		}
+		if lineStart && isMacroEnd(t) {
+			break
+		}
+		if lineStart && p.isLocalDeclaration(t) {
+			names, err := parseLocalNames(p)
+			if err != nil {
+				return err
+			}
+			locals = append(locals, names...)
+			continue
+		}
+		lineStart = t.Kind == NEWLINE
 		if t.Kind == DOT {
 			nxt := p.peek()
-			if nxt.Kind == IDENT && strings.EqualFold(nxt.Text, "ENDMACRO") {
+			if nxt.Kind == IDENT && (strings.EqualFold(nxt.Text, "ENDMACRO") || strings.EqualFold(nxt.Text, "ENDM")) {
 				_ = p.next()
-				if p.peek().Kind == NEWLINE {
-					_ = p.next()
-				}
 				break
 			}
 		}
 		body = append(body, t)
 	}
 
-	p.macros[nameTok.Text] = macroDef{params: params, body: body}
+	p.place(func(p *Parser) error {
+		p.checkMacroName(nameTok)
+		return nil
+	})
+	p.macros[nameTok.Text] = macroDef{name: nameTok.Text, line: nameTok.Line, params: params, body: body, locals: locals}
 	return nil
 }
+
+// isLocalDeclaration reports whether t starts a ".local name[, name]..." line
+// inside a macro body. The Devpac lexer delivers a column-one ".local" as a
+// single identifier followed by an inserted colon.
+func (p *Parser) isLocalDeclaration(t Token) bool {
+	switch t.Kind {
+	case DOT:
+		if next := p.peek(); next.Kind == IDENT && strings.EqualFold(next.Text, "local") {
+			_ = p.next()
+			return true
+		}
+	case IDENT:
+		if strings.EqualFold(t.Text, ".local") {
+			p.accept(COLON)
+			return true
+		}
+	}
+	return false
+}
+
+// parseLocalNames reads the names of a .local declaration and its newline.
+func parseLocalNames(p *Parser) ([]string, error) {
+	var names []string
+	for {
+		name, err := p.want(IDENT)
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, name.Text)
+		if !p.accept(COMMA) {
+			break
+		}
+	}
+	if t := p.next(); t.Kind != NEWLINE {
+		return nil, parserError(t, "expected end of line after .local")
+	}
+	return names, nil
+}
+
+// .exitm leaves the innermost macro expansion early, closing any conditional
+// blocks opened inside it.
+func parseEXITM(p *Parser) error {
+	p.pp.omit()
+	for i, t := range p.buf {
+		if t.Kind == MACROEND {
+			p.conds = p.conds[:t.Val]
+			p.buf = p.buf[i+1:]
+			return nil
+		}
+	}
+	return contextualizeAt(p.line, p.col, withCode(CodeUnbalancedBlock, fmt.Errorf(".exitm outside of a macro")))
+}
+
+func parseLOCAL(p *Parser) error {
+	return contextualizeAt(p.line, p.col, withCode(CodeUnbalancedBlock, fmt.Errorf(".local is only allowed inside a macro")))
+}
+
+// parseMacroParam reads "name", "name=default" or "name:vararg".
+func parseMacroParam(p *Parser) (macroParam, error) {
+	nameTok, err := p.want(IDENT)
+	if err != nil {
+		return macroParam{}, err
+	}
+	param := macroParam{name: nameTok.Text}
+	switch {
+	case p.accept(EQUAL):
+		param.def = []Token{}
+		for t := p.peek(); t.Kind != COMMA && t.Kind != NEWLINE && t.Kind != EOF; t = p.peek() {
+			param.def = append(param.def, p.next())
+		}
+	case p.accept(COLON):
+		qual, err := p.want(IDENT)
+		if err != nil {
+			return macroParam{}, err
+		}
+		if !strings.EqualFold(qual.Text, "vararg") {
+			return macroParam{}, parserError(qual, "unknown macro parameter qualifier")
+		}
+		param.vararg = true
+	}
+	return param, nil
+}
+
+func isMacroEnd(t Token) bool {
+	return t.Kind == IDENT && (strings.EqualFold(t.Text, "ENDM") || strings.EqualFold(t.Text, "ENDMACRO"))
+}
+
+func parseStrayENDM(p *Parser) error {
+	return contextualizeAt(p.line, p.col, withCode(CodeUnbalancedBlock, fmt.Errorf("ENDM without MACRO")))
+}
+
+// parseIgnoredLine accepts directives that have no effect on the flat output
+// (XREF) and discards their operands.
+func parseIgnoredLine(p *Parser) error {
+	p.releaseTokens(p.consumeUntilEOL())
+	return nil
+}
+
+// END stops assembly; the rest of the source is ignored.
+func parseEND(p *Parser) error {
+	p.ended = true
+	return nil
+}
+
+func directiveSize(suffix string) (uint32, error) {
+	switch strings.ToUpper(suffix) {
+	case "B":
+		return 1, nil
+	case "", "W":
+		return 2, nil
+	case "L":
+		return 4, nil
+	}
+	return 0, withCode(CodeInvalidSize, fmt.Errorf("unknown size .%s", suffix))
+}
+
+// DS.x <count> reserves count zero-filled elements.
+func parseDS(p *Parser, suffix string) error {
+	size, err := directiveSize(suffix)
+	if err != nil {
+		return contextualizeAt(p.line, p.col, err)
+	}
+	count, err := p.parseExprInfo()
+	if err != nil {
+		return err
+	}
+	p.place(func(p *Parser) error {
+		n, err := p.evaluate(count)
+		if err != nil {
+			return err
+		}
+		if n.Value < 0 || n.Value > int64(maxProgramSize) {
+			return contextualizeAt(p.line, p.col, withCode(CodeOutOfRange, fmt.Errorf("DS count out of range: %d", n.Value)))
+		}
+		return p.emitPaddingBytes(uint32(n.Value)*size, 0x00)
+	})
+	return nil
+}
+
+// DCB.x <count>[, <value>] emits count copies of value.
+func parseDCB(p *Parser, suffix string) error {
+	col := p.col
+	size, err := directiveSize(suffix)
+	if err != nil {
+		return contextualizeAt(p.line, p.col, err)
+	}
+	count, err := p.parseExprInfo()
+	if err != nil {
+		return err
+	}
+	countCol := p.col
+	var value exprInfo
+	if p.accept(COMMA) {
+		if value, err = p.parseExprInfo(); err != nil {
+			return err
+		}
+	}
+	p.place(func(p *Parser) error {
+		n, err := p.evaluate(count)
+		if err != nil {
+			return err
+		}
+		if n.Value < 0 || n.Value*int64(size) > int64(maxProgramSize) {
+			return contextualizeAt(p.line, countCol, withCode(CodeOutOfRange, fmt.Errorf("DCB count out of range: %d", n.Value)))
+		}
+		v, err := p.evaluate(value)
+		if err != nil {
+			return err
+		}
+		if err := p.checkFit(v.Value, 8*uint(size), "DCB"); err != nil {
+			return contextualizeAt(p.line, p.col, err)
+		}
+		if err := ensureBSSValue(p, v.Value, p.col, "DCB"); err != nil {
+			return err
+		}
+		elem := make([]byte, size)
+		for i := range elem {
+			elem[i] = byte(v.Value >> (8 * (int(size) - 1 - i)))
+		}
+		out := bytes.Repeat(elem, int(n.Value))
+		p.items = append(p.items, &DataBytes{Bytes: out, PC: p.pc, Line: p.line, Col: col, Section: p.section})
+		p.pc += uint32(len(out))
+		return nil
+	})
+	return nil
+}
+
+// CNOP <offset>, <alignment> pads until pc = offset (mod alignment).
+func parseCNOP(p *Parser) error {
+	offset, err := p.parseExprInfo()
+	if err != nil {
+		return err
+	}
+	if _, err := p.want(COMMA); err != nil {
+		return err
+	}
+	align, err := p.parseExprInfo()
+	if err != nil {
+		return err
+	}
+	p.place(func(p *Parser) error {
+		offset, err := p.evaluate(offset)
+		if err != nil {
+			return err
+		}
+		align, err := p.evaluate(align)
+		if err != nil {
+			return err
+		}
+		if align.Value < 1 || offset.Value < 0 {
+			return contextualizeAt(p.line, p.col, fmt.Errorf("CNOP expects offset >= 0 and alignment >= 1"))
+		}
+		a := uint32(align.Value)
+		pad := (uint32(offset.Value) - p.pc%a + a) % a
+		return p.emitPaddingBytes(pad, 0x00)
+	})
+	return nil
+}
+
+func parseRSRESET(p *Parser) error {
+	p.place(func(p *Parser) error {
+		p.rsCounter = 0
+		return nil
+	})
+	return nil
+}
+
+// RSSET <expr> sets the RS offset counter.
+func parseRSSET(p *Parser) error {
+	v, err := p.parseExprInfo()
+	if err != nil {
+		return err
+	}
+	p.place(func(p *Parser) error {
+		v, err := p.evaluate(v)
+		if err != nil {
+			return err
+		}
+		p.rsCounter = v.Value
+		return nil
+	})
+	return nil
+}
+
+// name RS.x <count> assigns the RS counter to name and advances it.
+func parseNamedRS(p *Parser, name Token) error {
+	_, suffix := splitMnemonic(p.next().Text)
+	return parseRS(p, &name, suffix)
+}
+
+func parseRS(p *Parser, name *Token, suffix string) error {
+	size, err := directiveSize(suffix)
+	if err != nil {
+		return contextualizeAt(p.line, p.col, err)
+	}
+	count, err := p.parseExprInfo()
+	if err != nil {
+		return err
+	}
+	var local, full string
+	if name != nil {
+		if local, full, err = p.symbolName(name.Text); err != nil {
+			return errorAtToken(*name, err)
+		}
+	}
+	p.place(func(p *Parser) error {
+		n, err := p.evaluate(count)
+		if err != nil {
+			return err
+		}
+		if name != nil {
+			if p.rsCounter < 0 || p.rsCounter > math.MaxUint32 {
+				return errorAtLine(name.Line, withCode(CodeOutOfRange, fmt.Errorf("RS offset out of 32-bit range: %d", p.rsCounter)))
+			}
+			if err := p.defineSymbol(local, full, uint32(p.rsCounter), SymbolConstant, name.Line); err != nil {
+				return errorAtToken(*name, err)
+			}
+		}
+		p.rsCounter += n.Value * int64(size)
+		return nil
+	})
+	return nil
+}
+
+// .set name, expr and .equ name, expr are the GNU as spellings of SET and EQU.
+func parseSymbolAssignment(p *Parser, kind SymbolKind) error {
+	nameTok, err := p.want(IDENT)
+	if err != nil {
+		return err
+	}
+	if _, err := p.want(COMMA); err != nil {
+		return err
+	}
+	val, err := p.parseExprInfo()
+	if err != nil {
+		return err
+	}
+	local, full, err := p.symbolName(nameTok.Text)
+	if err != nil {
+		return errorAtToken(nameTok, err)
+	}
+	p.place(func(p *Parser) error {
+		return p.defineValue(nameTok, local, full, val, kind)
+	})
+	return nil
+}
+
+// name EQUR <register> makes name an alias for a single register.
+func parseEQUR(p *Parser, name Token) error {
+	_ = p.next() // EQUR
+	reg := p.next()
+	if reg.Kind != IDENT || !isRegisterName(reg.Text) {
+		return parserError(reg, "EQUR expects a data or address register")
+	}
+	return p.defineRegAlias(name, []Token{reg})
+}
+
+// name REG <register list> names a MOVEM register list.
+func parseREG(p *Parser, name Token) error {
+	_ = p.next() // REG
+	list := p.consumeUntilEOL()
+	defer p.releaseTokens(list)
+	if len(list) == 0 {
+		return parserError(name, "REG expects a register list")
+	}
+	for _, t := range list {
+		if !(t.Kind == IDENT && isRegisterName(t.Text)) && t.Kind != MINUS && t.Kind != SLASH {
+			return parserError(t, "REG expects a register list")
+		}
+	}
+	return p.defineRegAlias(name, list)
+}
+
+func isRegisterName(name string) bool {
+	if ok, _ := isRegDn(name); ok {
+		return true
+	}
+	ok, _ := isRegAn(name)
+	return ok
+}
+
+// OBJ <addr> assembles the following code as if it were located at addr,
+// while the bytes continue in sequence, until OBJEND.
+func parseOBJ(p *Parser) error {
+	col := p.col
+	addr, err := p.parseExprInfo()
+	if err != nil {
+		return err
+	}
+	p.place(func(p *Parser) error {
+		if p.obj != nil {
+			return contextualizeAt(p.line, col, withCode(CodeUnbalancedBlock, fmt.Errorf("OBJ blocks cannot be nested")))
+		}
+		addr, err := p.evaluate(addr)
+		if err != nil {
+			return err
+		}
+		if addr.Value < 0 || addr.Value > math.MaxUint32 {
+			return contextualizeAt(p.line, p.col, withCode(CodeOutOfRange, fmt.Errorf("OBJ address out of 32-bit range: %d", addr.Value)))
+		}
+		p.obj = &objBlock{physical: p.pc, logical: uint32(addr.Value), line: p.line}
+		p.pc = uint32(addr.Value)
+		return nil
+	})
+	return nil
+}
+
+func parseOBJEND(p *Parser) error {
+	p.place(func(p *Parser) error {
+		if p.obj == nil {
+			return contextualizeAt(p.line, p.col, withCode(CodeUnbalancedBlock, fmt.Errorf("OBJEND without OBJ")))
+		}
+		p.pc = p.obj.physical + (p.pc - p.obj.logical)
+		p.obj = nil
+		return nil
+	})
+	return nil
+}
+
+// INCBIN "<file>"[, <offset>[, <length>]] inserts the raw bytes of a file.
+func parseINCBIN(p *Parser) error {
+	col := p.col
+	nameTok, err := p.want(STRING)
+	if err != nil {
+		return err
+	}
+	data, err := p.readIncludeFile(nameTok.Text)
+	if err != nil {
+		return errorAtToken(nameTok, withCode(CodeFile, err))
+	}
+	var offsetExpr, lengthExpr *exprInfo
+	if p.accept(COMMA) {
+		v, err := p.parseExprInfo()
+		if err != nil {
+			return err
+		}
+		offsetExpr = &v
+		if p.accept(COMMA) {
+			v, err := p.parseExprInfo()
+			if err != nil {
+				return err
+			}
+			lengthExpr = &v
+		}
+	}
+	p.place(func(p *Parser) error {
+		var offset int64
+		if offsetExpr != nil {
+			v, err := p.evaluate(*offsetExpr)
+			if err != nil {
+				return err
+			}
+			offset = v.Value
+		}
+		length := int64(len(data)) - offset
+		if lengthExpr != nil {
+			v, err := p.evaluate(*lengthExpr)
+			if err != nil {
+				return err
+			}
+			length = v.Value
+		}
+		if offset < 0 || length < 0 || offset+length > int64(len(data)) {
+			return contextualizeAt(p.line, p.col, fmt.Errorf("INCBIN range %d+%d exceeds %s (%d bytes)", offset, length, nameTok.Text, len(data)))
+		}
+		if uint64(p.pc)+uint64(length) > uint64(maxProgramSize) {
+			return contextualizeAt(p.line, p.col, withCode(CodeLayout, fmt.Errorf("INCBIN would exceed maximum program size of %d bytes", maxProgramSize)))
+		}
+		if p.section == SectionBSS && !p.allowForwardRefs {
+			return contextualizeAt(p.line, p.col, withCode(CodeSection, fmt.Errorf("INCBIN in %s must be zero-initialized", p.section.Name())))
+		}
+		out := data[offset : offset+length]
+		p.items = append(p.items, &DataBytes{Bytes: out, PC: p.pc, Line: p.line, Col: col, Section: p.section})
+		p.pc += uint32(len(out))
+		return nil
+	})
+	return nil
+}
+
+// readIncludeFile looks name up in the include directories, falling back to
+// the working directory.
+func (p *Parser) readIncludeFile(name string) ([]byte, error) {
+	if !filepath.IsAbs(name) {
+		for _, dir := range p.includeDirs {
+			if data, err := os.ReadFile(filepath.Join(dir, name)); err == nil {
+				return data, nil
+			}
+		}
+	}
+	return os.ReadFile(name)
+}
+
+// INFORM <severity>, "<text>"[, <expr>]... prints a message; %d and %h in
+// the text are replaced by the decimal and hex values of the expressions.
+// Severity 0 is a message, 1 a warning (WarnInform), 2 and 3 stop assembly
+// with an error.
+func parseINFORM(p *Parser) error {
+	line := p.line
+	severity, err := p.parseExprInfo()
+	if err != nil {
+		return err
+	}
+	if _, err := p.want(COMMA); err != nil {
+		return err
+	}
+	textTok, err := p.want(STRING)
+	if err != nil {
+		return err
+	}
+	var values []exprInfo
+	for p.accept(COMMA) {
+		v, err := p.parseExprInfo()
+		if err != nil {
+			return err
+		}
+		values = append(values, v)
+	}
+	p.place(func(p *Parser) error {
+		severity, err := p.evaluate(severity)
+		if err != nil {
+			return err
+		}
+		args := make([]int64, len(values))
+		for i, v := range values {
+			v, err := p.evaluate(v)
+			if err != nil {
+				return err
+			}
+			args[i] = v.Value
+		}
+		msg := formatInform(textTok.Text, args)
+		switch {
+		case severity.Value >= 2:
+			return errorAtLine(line, withCode(CodeUser, fmt.Errorf("%s", msg)))
+		case severity.Value == 1:
+			p.warn(WarnInform, line, "%s", msg)
+		case p.allowForwardRefs || p.messages == nil:
+			// Messages are printed once, from the final pass.
+		default:
+			fmt.Fprintf(p.messages, "line %d: %s\n", line, msg)
+		}
+		return nil
+	})
+	return nil
+}
+
+func formatInform(text string, args []int64) string {
+	var b strings.Builder
+	for i := 0; i < len(text); i++ {
+		if text[i] != '%' || i+1 >= len(text) {
+			b.WriteByte(text[i])
+			continue
+		}
+		i++
+		switch verb := text[i]; verb {
+		case 'd', 'h':
+			if len(args) == 0 {
+				b.WriteByte('%')
+				b.WriteByte(verb)
+				continue
+			}
+			if verb == 'd' {
+				fmt.Fprintf(&b, "%d", args[0])
+			} else {
+				fmt.Fprintf(&b, "%X", args[0])
+			}
+			args = args[1:]
+		default:
+			b.WriteByte(verb)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,98 @@
+package asm
+
+import (
+	"fmt"
+	"math"
+	"slices"
+
+	"github.com/jenska/m68kasm/internal/asm/instructions"
+)
+
+// checkFit reports a data value that fits bits neither as a signed nor as an
+// unsigned number. ParseOptions.AllowTruncation keeps the low bits instead.
+// The first pass sees forward references as 0 and is not checked.
+func (p *Parser) checkFit(v int64, bits uint, what string) error {
+	if p.allowForwardRefs || (v >= -1<<(bits-1) && v <= 1<<bits-1) {
+		return nil
+	}
+	return p.truncated(fmt.Errorf("%s value out of %d-bit range: %d", what, bits, v))
+}
+
+// checkDisplacement reports a displacement that does not fit bits as a signed
+// number, as the CPU sign-extends it.
+func (p *Parser) checkDisplacement(expr exprInfo, bits uint) error {
+	v := expr.Value
+	if p.allowForwardRefs || (v >= -1<<(bits-1) && v < 1<<(bits-1)) {
+		return nil
+	}
+	return p.operandError(expr, p.truncated(fmt.Errorf("displacement out of %d-bit range: %d", bits, v)))
+}
+
+// checkAbsoluteWord reports an address that xxx.W cannot reach. The CPU
+// sign-extends the word, so $FFFF8000-$FFFFFFFF may be written either way.
+func (p *Parser) checkAbsoluteWord(expr exprInfo) error {
+	if p.allowForwardRefs || fitsAbsoluteWord(expr.Value) {
+		return nil
+	}
+	return p.operandError(expr, p.truncated(fmt.Errorf("absolute address out of .W range: %d", expr.Value)))
+}
+
+// operandError places err at the operand expr starts, or at the line if the
+// operand is not known.
+func (p *Parser) operandError(expr exprInfo, err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case expr.start.Line == 0:
+		return errorAtLine(p.line, err)
+	}
+	return errorAtToken(expr.start, err)
+}
+
+// checkImmediate reports an immediate that form.Validate finds out of range
+// at the operand, as the data directives do, rather than when assembling.
+// The other checks of Validate are left to assembling.
+func (p *Parser) checkImmediate(mn Token, def *instructions.InstrDef, form *instructions.FormDef, args instructions.Args, operand []Token) error {
+	if p.allowForwardRefs || form.Validate == nil {
+		return nil
+	}
+	err := form.Validate(&args)
+	if codeOf(err) != CodeOutOfRange {
+		return nil
+	}
+	err = withHint(err, validateHint(mn.Text, def, form, args, err))
+	if operand == nil {
+		return errorAtToken(mn, err)
+	}
+	return errorAtOperand(operand, err)
+}
+
+// immediateOperand returns a copy of the tokens of the immediate operand,
+// from its '#' to the next comma, or nil if there is none.
+func immediateOperand(operands []Token) []Token {
+	for i, t := range operands {
+		if t.Kind != HASH {
+			continue
+		}
+		end := i + 1
+		for end < len(operands) && operands[end].Kind != COMMA {
+			end++
+		}
+		return slices.Clone(operands[i:end])
+	}
+	return nil
+}
+
+// errorAtOperand places err at the tokens of an operand.
+func errorAtOperand(operand []Token, err error) error {
+	first, last := operand[0], operand[len(operand)-1]
+	located := errorAtToken(first, err)
+	if e, ok := located.(*Error); ok && e.Expansions == nil && last.Line == first.Line {
+		e.EndCol = last.Col + 1
+	}
+	return located
+}
+
+func fitsAbsoluteWord(v int64) bool {
+	return (v >= math.MinInt16 && v <= math.MaxInt16) || (v >= 0xFFFF8000 && v <= 0xFFFFFFFF)
+}
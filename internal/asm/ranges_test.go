@@ -0,0 +1,108 @@
+package asm_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/jenska/m68kasm/internal/asm"
+)
+
+func TestValueRangeErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"Byte", ".byte 1,300\n", ".byte value out of 8-bit range: 300"},
+		{"NegativeByte", "DC.B -129\n", ".byte value out of 8-bit range: -129"},
+		{"Word", "DC.W $10000\n", ".word value out of 16-bit range: 65536"},
+		{"Long", "DC.L $100000000\n", ".long value out of 32-bit range: 4294967296"},
+		{"DCB", "DCB.W 2,$12345\n", "DCB value out of 16-bit range: 74565"},
+		{"AlignFill", "NOP\n.align 4,300\n", ".align fill value out of 8-bit range: 300"},
+		{"ImmediateByte", "MOVE.B #$1234,D0\n", "immediate out of range for .b: 4660"},
+		{"ImmediateMultiply", "MULU #$12345,D0\n", "immediate out of range for .w: 74565"},
+		{"Displacement", "MOVE.W 40000(A0),D0\n", "displacement out of 16-bit range: 40000"},
+		{"IndexDisplacement", "MOVE.W -129(A0,D0.W),D0\n", "displacement out of 8-bit range: -129"},
+		{"PCDisplacement", "MOVE.W $10000(PC),D0\n", "PC-relative displacement out of range: 65534"},
+		{"AbsoluteWord", "MOVE.W $8000.W,D0\n", "absolute address out of .W range: 32768"},
+		{"AbsoluteLong", "JMP ($100000000).L\n", "absolute address value out of 32-bit range"},
+		{"Moveq", "MOVEQ #200,D0\n", "MOVEQ immediate out of range (-128 to 127): 200"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prog, err := asm.Parse(strings.NewReader(tt.src))
+			if err == nil {
+				_, err = asm.Assemble(prog)
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestValueRangeErrorColumns(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		col  int
+	}{
+		{"Displacement", "MOVE.W 40000(A0),D0\n", 8},
+		{"IndexDisplacement", "MOVE.W (-129,A0,D0.W),D0\n", 9},
+		{"PCDisplacement", "LEA $10000(PC),A0\n", 5},
+		{"AbsoluteWord", "MOVE.W D0,$8000.W\n", 11},
+		{"AbsoluteLong", "JMP ($100000000).L\n", 6},
+		{"AlignFill", ".align 4,-200\n", 10},
+		{"Immediate", "MOVE.B #$1234,D0\n", 8},
+		{"QuickImmediate", "ADDQ.W #2*5,(A0)\n", 8},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := asm.Parse(strings.NewReader(tt.src))
+			var asmErr *asm.Error
+			if !errors.As(err, &asmErr) || asmErr.Col != tt.col {
+				t.Fatalf("expected an error at column %d, got %+v", tt.col, asmErr)
+			}
+		})
+	}
+}
+
+func TestValueRanges(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want []byte
+	}{
+		{"SignedOrUnsignedByte", "DC.B -128,255\n", []byte{0x80, 0xFF}},
+		{"ForwardDifference", "DC.B end-start\nstart: DS.B 200\nend:\n", append([]byte{200}, make([]byte, 200)...)},
+		{"Masked", "MOVE.B #$1234&$FF,D0\n", []byte{0x10, 0x3C, 0x00, 0x34}},
+		{"NegativeDisplacement", "MOVE.W -32768(A0),D0\n", []byte{0x30, 0x28, 0x80, 0x00}},
+		{"SignExtendedWordAddress", "MOVE.W $FFFF8000.W,D0\n", []byte{0x30, 0x38, 0x80, 0x00}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := assembleSource(t, tt.src)
+			if !bytes.Equal(got, tt.want) {
+				t.Fatalf("unexpected encoding for %q: got %x want %x", tt.src, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAllowTruncation(t *testing.T) {
+	src := ".byte 300,0\nMOVE.B #$1234,D0\nMOVE.W 40000(A0),D0\nMOVE.W $12345.W,D0\n"
+	prog, err := asm.ParseWithOptions(strings.NewReader(src), asm.ParseOptions{AllowTruncation: true})
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	got, err := asm.Assemble(prog)
+	if err != nil {
+		t.Fatalf("assemble failed: %v", err)
+	}
+	want := []byte{0x2C, 0x00, 0x10, 0x3C, 0x00, 0x34, 0x30, 0x28, 0x9C, 0x40, 0x30, 0x38, 0x23, 0x45}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("unexpected encoding: got %x want %x", got, want)
+	}
+}
@@ -8,8 +8,18 @@ const (
 	SectionText SectionKind = iota
 	SectionData
 	SectionBSS
+
+	sectionCount = 3
 )
 
+// sectionRange is the address range a section occupies. Sections follow each
+// other in .text, .data, .bss order.
+type sectionRange struct {
+	start, end uint32
+	// closed is set once end is known.
+	closed bool
+}
+
 func (s SectionKind) Name() string {
 	switch s {
 	case SectionText:
@@ -37,6 +47,24 @@ func parseSectionName(name string) (SectionKind, bool) {
 	}
 }
 
+// parseSectionType maps a Devpac/vasm section type (CODE, DATA, BSS with an
+// optional _C/_F/_P memory suffix) to a section.
+func parseSectionType(typ string) (SectionKind, bool) {
+	upper := strings.ToUpper(typ)
+	if idx := strings.IndexByte(upper, '_'); idx >= 0 {
+		upper = upper[:idx]
+	}
+	switch upper {
+	case "CODE", "TEXT":
+		return SectionText, true
+	case "DATA":
+		return SectionData, true
+	case "BSS":
+		return SectionBSS, true
+	}
+	return SectionText, false
+}
+
 func sectionToELFIndex(section SectionKind) uint16 {
 	switch section {
 	case SectionText:
@@ -43,14 +43,17 @@ func FormatSRecords(entries []ListingEntry, origin uint32, header string) []byte
 	return []byte(strings.Join(lines, "\n") + "\n")
 }
 
-type srecSegment struct {
+// dataSegment is a run of bytes at consecutive addresses.
+type dataSegment struct {
 	Addr uint32
 	Data []byte
 }
 
-func listingSegments(entries []ListingEntry) []srecSegment {
-	segs := make([]srecSegment, 0, len(entries))
-	var current *srecSegment
+// listingSegments joins the bytes of the listing entries into segments at
+// their load addresses; a new segment starts wherever those jump.
+func listingSegments(entries []ListingEntry) []dataSegment {
+	segs := make([]dataSegment, 0, len(entries))
+	var current *dataSegment
 	var nextAddr uint32
 
 	for i := range entries {
@@ -59,10 +62,10 @@ func listingSegments(entries []ListingEntry) []srecSegment {
 			continue
 		}
 
-		if current == nil || entry.PC != nextAddr {
-			segs = append(segs, srecSegment{Addr: entry.PC})
+		if current == nil || entry.Load != nextAddr {
+			segs = append(segs, dataSegment{Addr: entry.Load})
 			current = &segs[len(segs)-1]
-			nextAddr = entry.PC
+			nextAddr = entry.Load
 		}
 
 		current.Data = append(current.Data, entry.Bytes...)
@@ -55,3 +55,23 @@ func TestFormatSRecords_SplitsIntoChunks(t *testing.T) {
 		t.Fatalf("unexpected multi-record output:\n%s\nwant:\n%s", srec, want)
 	}
 }
+
+func TestFormatSRecords_ObjBlockAtLoadAddress(t *testing.T) {
+	src := "ORG $1000\nNOP\nOBJ $1010\nRTS\nOBJEND\nNOP\n"
+	prog, err := Parse(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+
+	_, listing, err := AssembleWithListing(prog)
+	if err != nil {
+		t.Fatalf("assemble failed: %v", err)
+	}
+
+	// The bytes of an OBJ block stay in sequence, as in the binary output.
+	srec := string(FormatSRecords(listing, prog.Origin, ""))
+	want := "S00A00006D36386B61736D6E\nS30B000010004E714E754E71A3\nS70500001000EA\n"
+	if srec != want {
+		t.Fatalf("unexpected S-record output:\n%s\nwant:\n%s", srec, want)
+	}
+}
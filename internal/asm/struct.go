@@ -0,0 +1,258 @@
+package asm
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// structBlock is the open .struct definition. Its member lines define
+// "name_member" constants holding their offsets.
+type structBlock struct {
+	name   string
+	line   int
+	offset int64
+	// align is the largest member alignment, which pads the total size.
+	align int64
+}
+
+// .struct name opens a structure definition closed by .ends. The block is
+// opened in layout, where its offsets are counted in every pass; the front
+// end runs that right away, which sends the member lines to
+// parseStructMember.
+func parseSTRUCT(p *Parser) error {
+	nameTok, err := p.want(IDENT)
+	if err != nil {
+		return err
+	}
+	p.place(func(p *Parser) error {
+		p.strct = &structBlock{name: nameTok.Text, line: nameTok.Line, align: 1}
+		return nil
+	})
+	return nil
+}
+
+func parseStrayENDS(p *Parser) error {
+	return contextualizeAt(p.line, p.col, withCode(CodeUnbalancedBlock, fmt.Errorf(".ends without .struct")))
+}
+
+// structKeyword returns the upper-case member directive starting at the
+// n-th lookahead token ("ds.w", ".l", "ENDS"), without a leading dot.
+func (p *Parser) structKeyword(n int) (string, bool) {
+	t := p.peekN(n)
+	if t.Kind == DOT {
+		t = p.peekN(n + 1)
+	}
+	if t.Kind != IDENT {
+		return "", false
+	}
+	// Devpac merges ".w" into a single local-label token.
+	kw := strings.ToUpper(strings.TrimPrefix(t.Text, "."))
+	base, _ := splitMnemonic(kw)
+	switch base {
+	case "B", "W", "L", "BYTE", "WORD", "LONG", "DS", "RS", "ALIGN", "EVEN", "ENDS", "STRUCT":
+		return kw, true
+	}
+	return "", false
+}
+
+// parseStructMember parses one line of an open .struct block:
+//
+//	[name[:]] .b|.w|.l|ds.x|rs.x [count]
+//	.align n | .even | .ends
+//
+// Macro invocations are left to the caller, so macros can emit members.
+func (p *Parser) parseStructMember() (bool, error) {
+	t := p.peek()
+	if t.Kind == IDENT {
+		if _, _, ok := p.lookupMacro(t.Text); ok {
+			return false, nil
+		}
+	}
+	var member *Token
+	if _, ok := p.structKeyword(2); t.Kind == IDENT && (ok || p.peekN(2).Kind == COLON) {
+		tok := p.next()
+		member = &tok
+		p.accept(COLON)
+		p.pp.markLabel()
+	}
+	name := p.strct.name
+	define := func(p *Parser) error { return nil }
+	if member != nil {
+		m := *member
+		local, full, err := p.symbolName(name + "_" + m.Text)
+		if err != nil {
+			return true, errorAtToken(m, err)
+		}
+		define = func(p *Parser) error { return p.defineStructMember(m, local, full) }
+	}
+	if member != nil && (p.peek().Kind == NEWLINE || p.peek().Kind == EOF) {
+		p.place(define)
+		return true, nil
+	}
+
+	kw, ok := p.structKeyword(1)
+	if !ok {
+		return true, parserError(p.peek(), "expected a structure member")
+	}
+	if p.peek().Kind == DOT {
+		p.next()
+	}
+	kwTok := p.next()
+	base, suffix := splitMnemonic(kw)
+
+	var size int64
+	switch base {
+	case "B", "BYTE":
+		size = 1
+	case "W", "WORD":
+		size = 2
+	case "L", "LONG":
+		size = 4
+	case "DS", "RS":
+		n, err := directiveSize(suffix)
+		if err != nil {
+			return true, errorAtToken(kwTok, err)
+		}
+		size = int64(n)
+	case "ALIGN", "EVEN":
+		var align exprInfo
+		if base == "ALIGN" {
+			v, err := p.parseExprInfo()
+			if err != nil {
+				return true, err
+			}
+			align = v
+		}
+		p.place(func(p *Parser) error {
+			n := int64(2)
+			if base == "ALIGN" {
+				v, err := p.evaluate(align)
+				if err != nil {
+					return err
+				}
+				if v.Value < 1 {
+					return errorAtToken(kwTok, fmt.Errorf(".align expects value >= 1, got %d", v.Value))
+				}
+				n = v.Value
+			}
+			s := p.strct
+			s.offset = alignStructOffset(s.offset, n)
+			s.align = max(s.align, n)
+			return define(p)
+		})
+		return true, nil
+	case "STRUCT":
+		nameTok, err := p.want(IDENT)
+		if err != nil {
+			return true, err
+		}
+		return true, errorAtToken(nameTok, fmt.Errorf(".struct %s inside .struct %s", nameTok.Text, name))
+	case "ENDS":
+		if member != nil {
+			return true, errorAtToken(*member, fmt.Errorf("unexpected label before .ends"))
+		}
+		return true, p.closeStruct()
+	}
+
+	var count *exprInfo
+	if k := p.peek().Kind; k != NEWLINE && k != EOF {
+		v, err := p.parseExprInfo()
+		if err != nil {
+			return true, err
+		}
+		count = &v
+	}
+	p.place(func(p *Parser) error {
+		n := int64(1)
+		if count != nil {
+			v, err := p.evaluate(*count)
+			if err != nil {
+				return err
+			}
+			if v.Value < 0 {
+				return errorAtToken(kwTok, fmt.Errorf("structure member count must be >= 0, got %d", v.Value))
+			}
+			n = v.Value
+		}
+		// Words and longs sit on even offsets, as they would in memory.
+		s := p.strct
+		if size > 1 {
+			s.offset = alignStructOffset(s.offset, 2)
+			s.align = max(s.align, 2)
+		}
+		if err := define(p); err != nil {
+			return err
+		}
+		s.offset += size * n
+		return nil
+	})
+	return true, nil
+}
+
+// defineStructMember defines the member, whose names symbolName returned, as
+// the offset of the open structure.
+func (p *Parser) defineStructMember(member Token, local, full string) error {
+	s := p.strct
+	if s.offset > math.MaxUint32 {
+		return errorAtToken(member, withCode(CodeOutOfRange, fmt.Errorf("structure offset out of 32-bit range: %d", s.offset)))
+	}
+	if err := p.defineSymbol(local, full, uint32(s.offset), SymbolConstant, member.Line); err != nil {
+		return errorAtToken(member, err)
+	}
+	return nil
+}
+
+// closeStruct defines the structure name as its size, padded to the largest
+// member alignment.
+func (p *Parser) closeStruct() error {
+	s := p.strct
+	local, full, err := p.symbolName(s.name)
+	if err != nil {
+		return errorAtLine(s.line, err)
+	}
+	p.place(func(p *Parser) error {
+		s := p.strct
+		p.strct = nil
+		size := alignStructOffset(s.offset, s.align)
+		if size > math.MaxUint32 {
+			return errorAtLine(s.line, fmt.Errorf("structure %s too large: %d bytes", s.name, size))
+		}
+		if err := p.defineSymbol(local, full, uint32(size), SymbolConstant, s.line); err != nil {
+			return errorAtLine(s.line, err)
+		}
+		p.structs[full] = true
+		return nil
+	})
+	return nil
+}
+
+func (p *Parser) ensureStructClosed() error {
+	if p.strct == nil {
+		return nil
+	}
+	return errorAtLine(p.strct.line, withCode(CodeUnbalancedBlock, fmt.Errorf(".struct %s without matching .ends", p.strct.name)))
+}
+
+// sizeOf evaluates SIZEOF(name). Structures defined further down are known
+// from the previous pass.
+func (p *Parser) sizeOf(name string) (int64, error) {
+	name = p.resolveSymbol(name)
+	if p.defined[name] && !p.structs[name] {
+		return 0, fmt.Errorf("SIZEOF: %s is not a structure", name)
+	}
+	if v, ok := p.labels[name]; ok {
+		return int64(v), nil
+	}
+	if p.allowForwardRefs {
+		return 0, nil
+	}
+	return 0, fmt.Errorf("SIZEOF: unknown structure %s", name)
+}
+
+func alignStructOffset(offset, align int64) int64 {
+	if r := offset % align; r != 0 {
+		offset += align - r
+	}
+	return offset
+}
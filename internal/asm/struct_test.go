@@ -0,0 +1,57 @@
+package asm_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/jenska/m68kasm/internal/asm"
+)
+
+func TestStructOffsets(t *testing.T) {
+	tests := []struct {
+		name   string
+		src    string
+		syntax asm.Syntax
+		want   []byte
+	}{
+		{"Members", ".struct obj\nflag: .b 1\nx: .w 1\npos: .ds.l 2\nname: ds.b 3\n.ends\nDC.B obj_flag,obj_x,obj_pos,obj_name,SIZEOF(obj)\n", asm.SyntaxMotorola, []byte{0, 2, 4, 12, 16}},
+		{"Align", ".struct hdr\nid: .b\n.align 4\nlen: .b 2\n.ends\nDC.B hdr_len,SIZEOF(hdr)\n", asm.SyntaxMotorola, []byte{4, 8}},
+		{"Operand", ".struct obj\nnext: .l\nx: .w\n.ends\nMOVE.W D0,obj_x(A0)\n", asm.SyntaxMotorola, []byte{0x31, 0x40, 0x00, 0x04}},
+		{"ForwardSizeof", "DC.W SIZEOF(obj)\n.struct obj\nx: .w 3\n.ends\n", asm.SyntaxMotorola, []byte{0x00, 0x06}},
+		{"Devpac", "\tstruct obj\nx\tds.b 1\ny\tds.w 1\nends\n\tdc.b obj_y,SIZEOF(obj)\n", asm.SyntaxDevpac, []byte{2, 4}},
+		{"MacroMembers", ".macro VEC p\n\\p\\()x: .w\n\\p\\()y: .w\n.endmacro\n.struct obj\nVEC pos\nVEC vel\n.ends\nDC.B obj_velx,SIZEOF(obj)\n", asm.SyntaxMotorola, []byte{4, 8}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := assembleWithSyntax(t, tt.src, tt.syntax)
+			if !bytes.Equal(got, tt.want) {
+				t.Fatalf("unexpected encoding for %q: got %x want %x", tt.src, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStructErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"Unterminated", ".struct obj\nx: .w\n", ".struct obj without matching .ends"},
+		{"StrayEnds", ".ends\n", ".ends without .struct"},
+		{"Nested", ".struct a\n.struct b\n", ".struct b inside .struct a"},
+		{"BadMember", ".struct obj\nNOP\n.ends\n", "expected a structure member"},
+		{"Duplicate", ".struct obj\nx: .w\nx: .w\n.ends\n", "obj_x already defined"},
+		{"NotAStruct", "obj EQU 4\nDC.W SIZEOF(obj)\n", "SIZEOF: obj is not a structure"},
+		{"Unknown", "DC.W SIZEOF(obj)\n", "SIZEOF: unknown structure obj"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := asm.Parse(strings.NewReader(tt.src))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
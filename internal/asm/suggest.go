@@ -0,0 +1,329 @@
+package asm
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/jenska/m68kasm/internal/asm/instructions"
+)
+
+// suggest returns the candidate closest to name by edit distance, ignoring
+// case, or "" if none is close enough to be a likely typo: one edit for
+// names of up to four characters, two for longer ones. Ties go to the
+// candidate that sorts first, so suggestions do not depend on map order.
+func suggest(name string, candidates []string) string {
+	limit := 2
+	if len(name) <= 4 {
+		limit = 1
+	}
+	best, bestDist := "", limit+1
+	for _, c := range candidates {
+		if c == "" || c == name {
+			continue
+		}
+		d := distanceFold(name, c)
+		if d < bestDist || d == bestDist && c < best {
+			best, bestDist = c, d
+		}
+	}
+	return best
+}
+
+// editDistance returns the number of insertions, deletions, substitutions
+// and swaps of adjacent characters that turn a into b.
+func editDistance(a, b string) int {
+	// Rows i-2, i-1 and i of the distance matrix.
+	rows := [3][]int{make([]int, len(b)+1), make([]int, len(b)+1), make([]int, len(b)+1)}
+	for j := range rows[1] {
+		rows[1][j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		pp, prev, cur := rows[0], rows[1], rows[2]
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				cur[j] = min(cur[j], pp[j-2]+1)
+			}
+		}
+		rows[0], rows[1], rows[2] = prev, cur, pp
+	}
+	return rows[1][len(b)]
+}
+
+func distanceFold(a, b string) int {
+	return editDistance(strings.ToUpper(a), strings.ToUpper(b))
+}
+
+// didYouMean formats a suggestion as a hint, or returns "" for none.
+func didYouMean(s string) string {
+	if s == "" {
+		return ""
+	}
+	return fmt.Sprintf("did you mean %s?", s)
+}
+
+// mnemonicHint suggests the instruction, directive or macro meant by an
+// unknown statement mnemonic. The size suffix is kept as written and the
+// suggestion follows the case of text.
+func (p *Parser) mnemonicHint(text string) string {
+	name, suffix, _ := strings.Cut(text, ".")
+	candidates := p.instrs.Mnemonics()
+	for d := range directiveNames {
+		candidates = append(candidates, d[1:])
+	}
+	candidates = append(candidates, "DC")
+	for d := range sizedDirectives {
+		candidates = append(candidates, d)
+	}
+	s := followCase(suggest(name, candidates), name)
+	// Macros keep the case they were defined in and win over a builtin
+	// that is further away.
+	if macro := suggest(name, slices.Collect(maps.Keys(p.macros))); macro != "" {
+		if s == "" || distanceFold(name, macro) < distanceFold(name, s) {
+			s = macro
+		}
+	}
+	if s != "" && strings.Contains(text, ".") {
+		s += "." + suffix
+	}
+	return didYouMean(s)
+}
+
+// directiveHint suggests the dot directive meant by an unknown one.
+func directiveHint(name string) string {
+	return didYouMean(followCase(suggest(name, slices.Collect(maps.Keys(directiveNames))), name))
+}
+
+// followCase lowers s, a hint or suggestion written in upper case, when the
+// text it is about was written in lower case.
+func followCase(s, written string) string {
+	if written == strings.ToLower(written) {
+		return strings.ToLower(s)
+	}
+	return s
+}
+
+// labelHint suggests the symbol meant by an undefined one. Names generated
+// for numeric local labels and macro expansions are left out.
+func labelHint(name string, labels map[string]uint32) string {
+	var candidates []string
+	for l := range labels {
+		if !strings.HasPrefix(l, "__local_") && !strings.ContainsRune(l, generatedMark) {
+			candidates = append(candidates, l)
+		}
+	}
+	return didYouMean(suggest(name, candidates))
+}
+
+// rangeHints explain the range of the immediates that are most often
+// written out of range, and what to use instead.
+var rangeHints = map[string]string{
+	"ADDQ":  "ADDQ takes 1 to 8; use ADDI for other values",
+	"SUBQ":  "SUBQ takes 1 to 8; use SUBI for other values",
+	"MOVEQ": "MOVEQ takes -128 to 127; use MOVE.L for other values",
+	"TRAP":  "TRAP takes vectors 0 to 15",
+}
+
+// shiftMnemonics take an immediate count of 1 to 8.
+var shiftMnemonics = map[string]bool{
+	"ASL": true, "ASR": true, "LSL": true, "LSR": true,
+	"ROL": true, "ROR": true, "ROXL": true, "ROXR": true,
+}
+
+// instructionHint suggests a fix for an instruction that failed to parse
+// with err: the sizes it takes, the range of a quick immediate, or operands
+// that parse once a missing '#' is added or (An)+ and -(An) are swapped.
+// Hints follow the case of the mnemonic.
+func (p *Parser) instructionHint(mn Token, def *instructions.InstrDef, operandTokens []Token, err error) string {
+	switch codeOf(err) {
+	case CodeInvalidSize:
+		return followCase(sizesHint(def), mn.Text)
+	case CodeOutOfRange:
+		return followCase(rangeHint(def.Mnemonic), mn.Text)
+	case CodeInvalidOperand, CodeSyntax, "":
+		operands := splitOperands(operandTokens)
+		for i := range operands {
+			if fixed, ok := p.operandsParse(mn, def, withImmediate(operands, i)); ok {
+				return fmt.Sprintf("did you mean %s %s?", mn.Text, fixed)
+			}
+		}
+		for i := -1; i < len(operands); i++ {
+			if fixed, ok := p.operandsParse(mn, def, swapIncrements(operands, i)); ok {
+				return fmt.Sprintf("did you mean %s %s?", mn.Text, fixed)
+			}
+		}
+	}
+	return ""
+}
+
+// validateHint suggests a fix for an instruction that form.Validate
+// rejected with err when assembling: the range of a quick immediate, or
+// (An)+ and -(An) swapped. Hints follow the case of mnemonic, the
+// mnemonic as written.
+func validateHint(mnemonic string, def *instructions.InstrDef, form *instructions.FormDef, args instructions.Args, err error) string {
+	switch codeOf(err) {
+	case CodeOutOfRange:
+		return followCase(rangeHint(def.Mnemonic), mnemonic)
+	case CodeInvalidOperand:
+		for i := -1; i < 2; i++ {
+			swapped := args
+			if !swapArgIncrements(&swapped, i) || form.Validate(&swapped) != nil {
+				continue
+			}
+			if src, ok := eaText(swapped.Src); ok {
+				if dst, ok := eaText(swapped.Dst); ok {
+					return followCase(fmt.Sprintf("did you mean %s.%s %s,%s?", def.Mnemonic, sizeLetter(args.Size), src, dst), mnemonic)
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// rangeHint explains the range of the immediate of mnemonic, for the
+// instructions whose immediates are most often written out of range.
+func rangeHint(mnemonic string) string {
+	if shiftMnemonics[mnemonic] {
+		return "shift counts are 1 to 8; put larger counts in a data register"
+	}
+	return rangeHints[mnemonic]
+}
+
+// sizesHint lists the sizes def takes.
+func sizesHint(def *instructions.InstrDef) string {
+	var sizes []string
+	for _, f := range def.Forms {
+		for _, sz := range f.Sizes {
+			if s := "." + sizeLetter(sz); !slices.Contains(sizes, s) {
+				sizes = append(sizes, s)
+			}
+		}
+	}
+	slices.SortFunc(sizes, func(a, b string) int { return sizeOrder(a) - sizeOrder(b) })
+	switch len(sizes) {
+	case 0:
+		return ""
+	case 1:
+		return fmt.Sprintf("%s takes %s only", def.Mnemonic, sizes[0])
+	}
+	return fmt.Sprintf("%s takes %s or %s", def.Mnemonic, strings.Join(sizes[:len(sizes)-1], ", "), sizes[len(sizes)-1])
+}
+
+func sizeOrder(s string) int {
+	return strings.Index(".B.W.L", s)
+}
+
+// operandsParse reports whether def accepts the operands, and renders them.
+// nil operands are a rewrite that did not apply.
+func (p *Parser) operandsParse(mn Token, def *instructions.InstrDef, operands [][]Token) (string, bool) {
+	if operands == nil {
+		return "", false
+	}
+	tokens := joinMacroArgs(operands)
+	_, err := p.parseForms(mn, def, tokens)
+	return tokensText(tokens), err == nil
+}
+
+// splitOperands splits operand tokens at the commas outside parentheses.
+func splitOperands(tokens []Token) [][]Token {
+	var operands [][]Token
+	depth, start := 0, 0
+	for i, t := range tokens {
+		switch t.Kind {
+		case LPAREN:
+			depth++
+		case RPAREN:
+			depth--
+		case COMMA:
+			if depth == 0 {
+				operands = append(operands, tokens[start:i])
+				start = i + 1
+			}
+		}
+	}
+	if len(tokens) > 0 {
+		operands = append(operands, tokens[start:])
+	}
+	return operands
+}
+
+// withImmediate returns operands with a '#' in front of operand i, or nil
+// if it has one or does not start with a value.
+func withImmediate(operands [][]Token, i int) [][]Token {
+	op := operands[i]
+	if len(op) == 0 || (op[0].Kind != NUMBER && op[0].Kind != IDENT && op[0].Kind != MINUS) {
+		return nil
+	}
+	out := slices.Clone(operands)
+	out[i] = append([]Token{{Kind: HASH, Text: "#", Line: op[0].Line}}, op...)
+	return out
+}
+
+// swapIncrements returns operands with (An)+ and -(An) exchanged in
+// operand which, or in all of them if which is -1. It returns nil if there
+// is nothing to swap.
+func swapIncrements(operands [][]Token, which int) [][]Token {
+	out := slices.Clone(operands)
+	swapped := false
+	for i, op := range operands {
+		if which >= 0 && i != which {
+			continue
+		}
+		switch {
+		case len(op) == 4 && op[0].Kind == LPAREN && op[1].Kind == IDENT && op[2].Kind == RPAREN && op[3].Kind == PLUS:
+			out[i] = []Token{{Kind: MINUS, Text: "-", Line: op[0].Line}, op[0], op[1], op[2]}
+			swapped = true
+		case len(op) == 4 && op[0].Kind == MINUS && op[1].Kind == LPAREN && op[2].Kind == IDENT && op[3].Kind == RPAREN:
+			out[i] = []Token{op[1], op[2], op[3], {Kind: PLUS, Text: "+", Line: op[0].Line}}
+			swapped = true
+		}
+	}
+	if !swapped {
+		return nil
+	}
+	return out
+}
+
+// swapArgIncrements exchanges (An)+ and -(An) in the source (which 0), the
+// destination (which 1) or both (which -1) and reports whether it changed
+// anything.
+func swapArgIncrements(args *instructions.Args, which int) bool {
+	swapped := false
+	for i, ea := range []*instructions.EAExpr{&args.Src, &args.Dst} {
+		if which >= 0 && i != which {
+			continue
+		}
+		switch ea.Kind {
+		case instructions.EAkAddrPredec:
+			ea.Kind, swapped = instructions.EAkAddrPostinc, true
+		case instructions.EAkAddrPostinc:
+			ea.Kind, swapped = instructions.EAkAddrPredec, true
+		}
+	}
+	return swapped
+}
+
+// eaText renders the register operands a swapped (An)+ or -(An) appears
+// with.
+func eaText(ea instructions.EAExpr) (string, bool) {
+	switch ea.Kind {
+	case instructions.EAkDn:
+		return fmt.Sprintf("D%d", ea.Reg), true
+	case instructions.EAkAn:
+		return fmt.Sprintf("A%d", ea.Reg), true
+	case instructions.EAkAddrInd:
+		return fmt.Sprintf("(A%d)", ea.Reg), true
+	case instructions.EAkAddrPredec:
+		return fmt.Sprintf("-(A%d)", ea.Reg), true
+	case instructions.EAkAddrPostinc:
+		return fmt.Sprintf("(A%d)+", ea.Reg), true
+	}
+	return "", false
+}
@@ -0,0 +1,151 @@
+package asm
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Syntax selects the source dialect accepted by the lexer and parser.
+type Syntax uint8
+
+const (
+	// SyntaxMotorola is the native Motorola-style syntax (the default).
+	SyntaxMotorola Syntax = iota
+	// SyntaxGAS accepts GNU as (MIT/Motorola-gas) conventions: %-prefixed
+	// registers, a0@+ style addressing, '|' comments and size-suffixed
+	// mnemonics such as movel.
+	SyntaxGAS
+	// SyntaxDevpac accepts Devpac/vasm-mot conventions: labels in column one
+	// without colons, '*' comment lines, '.name' local labels and
+	// single-quoted strings.
+	SyntaxDevpac
+	// SyntaxASM68K accepts SEGA ASM68K/SNASM68K conventions as used by Mega
+	// Drive projects: the Devpac layout plus '@name' local labels.
+	SyntaxASM68K
+)
+
+var syntaxNames = map[Syntax]string{
+	SyntaxMotorola: "motorola",
+	SyntaxGAS:      "gas",
+	SyntaxDevpac:   "devpac",
+	SyntaxASM68K:   "asm68k",
+}
+
+func (s Syntax) String() string {
+	if name, ok := syntaxNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("Syntax(%d)", int(s))
+}
+
+// ParseSyntax returns the dialect named by name (case-insensitive).
+func ParseSyntax(name string) (Syntax, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", "motorola", "mot":
+		return SyntaxMotorola, nil
+	case "gas", "gnu", "mit":
+		return SyntaxGAS, nil
+	case "devpac", "vasm", "vasm-mot":
+		return SyntaxDevpac, nil
+	case "asm68k", "snasm68k", "snasm":
+		return SyntaxASM68K, nil
+	}
+	return SyntaxMotorola, fmt.Errorf("unknown syntax %q", name)
+}
+
+// newSyntaxLexer builds the token stream for src in the given dialect.
+func newSyntaxLexer(r io.Reader, syntax Syntax) lexer {
+	lx := NewLexerWithSyntax(r, syntax)
+	switch syntax {
+	case SyntaxGAS:
+		return &gasLexer{lx: lx}
+	case SyntaxDevpac, SyntaxASM68K:
+		return &devpacLexer{lx: lx}
+	}
+	return &motorolaLexer{lx: lx}
+}
+
+// motorolaLexer joins ".name" local labels, which the plain lexer splits into
+// a '.' and an identifier so that directives keep their own tokens.
+type motorolaLexer struct {
+	lx      *Lexer
+	pending []Token
+}
+
+func (m *motorolaLexer) Next() Token {
+	if len(m.pending) == 0 {
+		var line []Token
+		var starts []int
+		for {
+			t := m.lx.Next()
+			line = append(line, t)
+			starts = append(starts, m.lx.startCol)
+			if t.Kind == NEWLINE || t.Kind == EOF {
+				break
+			}
+		}
+		m.pending = mergeLocalLabels(line, starts)
+	}
+	t := m.pending[0]
+	m.pending = m.pending[1:]
+	return t
+}
+
+// mergeLocalLabels joins a '.' and the identifier right after it where a
+// local label can stand: a ".name:" definition at the start of the line, or
+// an operand after the mnemonic, even where the name is also a directive
+// (".end", ".even"). Only the directive of "NAME .equ value" stays split.
+func mergeLocalLabels(line []Token, starts []int) []Token {
+	out := make([]Token, 0, len(line))
+	operands := false
+	for i := 0; i < len(line); i++ {
+		t := line[i]
+		if t.Kind == DOT && i+1 < len(line) && line[i+1].Kind == IDENT && starts[i+1] == starts[i]+1 &&
+			(operands || len(out) == 0) && !namesSymbol(out, line, starts, i) && mergesLocalLabel(out, line, i, false) {
+			t = line[i+1]
+			t.Text = "." + t.Text
+			i++
+		} else if !operands && t.Kind == IDENT && (i+1 >= len(line) || line[i+1].Kind != COLON) {
+			// The mnemonic or directive name; operands follow.
+			operands = true
+		}
+		out = append(out, t)
+	}
+	return out
+}
+
+// isDialectComment reports whether ch starts a comment in the lexer's syntax.
+// GNU as uses '|' for comments and '#' at the start of a line for the line
+// markers emitted by the C preprocessor; Devpac and ASM68K treat a leading
+// '*' as a comment line.
+func (lx *Lexer) isDialectComment(ch rune) bool {
+	switch lx.syntax {
+	case SyntaxGAS:
+		return ch == '|' || (ch == '#' && lx.lineStart)
+	case SyntaxDevpac, SyntaxASM68K:
+		return ch == '*' && lx.lineStart
+	}
+	return false
+}
+
+// quotedStrings reports whether single quotes delimit strings rather than
+// character literals.
+func (lx *Lexer) quotedStrings() bool {
+	return lx.syntax == SyntaxDevpac || lx.syntax == SyntaxASM68K
+}
+
+// namesSymbol reports whether the '.' at line[i] starts the directive of a
+// "NAME .equ value" or "NAME .set value" definition: it follows the name
+// that opens the line and a value follows it. "bra .set" and
+// "lea .set(pc),a0" refer to a local label instead.
+func namesSymbol(out, line []Token, starts []int, i int) bool {
+	if len(out) != 1 || out[0].Kind != IDENT || !isEquToken(line[i+1]) || i+2 >= len(line) {
+		return false
+	}
+	switch line[i+2].Kind {
+	case NEWLINE, EOF, COMMA:
+		return false
+	}
+	return starts[i+2] != starts[i+1]+len(line[i+1].Text)
+}
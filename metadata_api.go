@@ -9,9 +9,19 @@ import (
 	internal "github.com/jenska/m68kasm/internal/asm"
 )
 
-// DefinedLabel captures a named label defined in source.
+// DefinedLabel captures a named label, constant or variable defined in source.
 type DefinedLabel = internal.DefinedLabel
 
+// SymbolKind tells labels from EQU constants and SET variables.
+type SymbolKind = internal.SymbolKind
+
+// Symbol kinds reported in DefinedLabel.Kind.
+const (
+	SymbolLabel    = internal.SymbolLabel
+	SymbolConstant = internal.SymbolConstant
+	SymbolVariable = internal.SymbolVariable
+)
+
 // InstructionMetadata describes a single assembled instruction.
 type InstructionMetadata struct {
 	Line      int
@@ -20,6 +30,9 @@ type InstructionMetadata struct {
 	Size      int
 	Words     int
 	Canonical string
+	// Symbolic is Canonical with operand values written as their source
+	// expressions, e.g. "MOVE.W D0,obj_x(A0)", for listings.
+	Symbolic string
 }
 
 // AssemblyResult captures both encoded bytes and test-friendly metadata.
@@ -31,6 +44,8 @@ type AssemblyResult struct {
 	LineAddresses map[int]uint32
 	Listing       []ListingEntry
 	Instructions  []InstructionMetadata
+	// Warnings are the warnings of the enabled categories.
+	Warnings []Diagnostic
 }
 
 // AddressOf resolves a named source label to its assembled address.
@@ -53,6 +68,8 @@ func (r *AssemblyResult) AddressForLine(line int) (uint32, bool) {
 
 // NormalizeError strips source-location prefixes from assembler errors so tests
 // can assert the stable message independently of line and column numbers.
+// Tools that act on the kind of error should match ErrorCode instead, as
+// messages may be reworded.
 func NormalizeError(err error) string {
 	if err == nil {
 		return ""
@@ -127,9 +144,13 @@ func buildAssemblyResult(prog *internal.Program) (*AssemblyResult, error) {
 		DefinedLabels: append([]DefinedLabel(nil), prog.DefinedLabels...),
 		Listing:       cloneListing(listing),
 		LineAddresses: make(map[int]uint32, len(listing)+len(prog.DefinedLabels)),
+		Warnings:      prog.Warnings,
 	}
 
 	for _, label := range prog.DefinedLabels {
+		if label.Kind != internal.SymbolLabel {
+			continue
+		}
 		result.Labels[label.Name] = label.Addr
 		if _, exists := result.LineAddresses[label.Line]; !exists && label.Line > 0 {
 			result.LineAddresses[label.Line] = label.Addr
@@ -155,7 +176,9 @@ func cloneListing(listing []ListingEntry) []ListingEntry {
 		out[i] = ListingEntry{
 			Line:  entry.Line,
 			PC:    entry.PC,
+			Load:  entry.Load,
 			Bytes: append([]byte(nil), entry.Bytes...),
+			Sizes: append([]string(nil), entry.Sizes...),
 		}
 	}
 	return out
@@ -187,6 +210,7 @@ func collectInstructionMetadata(items []any, listing []ListingEntry) []Instructi
 			Size:      len(entry.Bytes),
 			Words:     len(entry.Bytes) / 2,
 			Canonical: canonicalInstruction(ins),
+			Symbolic:  symbolicInstruction(ins),
 		}
 		metadata = append(metadata, md)
 	}
@@ -2,8 +2,11 @@ package e2e_test
 
 import (
 	"bytes"
+	"encoding/json"
+	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -23,6 +26,17 @@ func runCLI(t *testing.T, args ...string) ([]byte, error) {
 	return cmd.CombinedOutput()
 }
 
+// runCLIStderr runs the CLI and returns its standard error alone.
+func runCLIStderr(t *testing.T, args ...string) ([]byte, error) {
+	t.Helper()
+	var stderr bytes.Buffer
+	cmd := exec.Command("go", append([]string{"run", "./cmd/m68kasm"}, args...)...)
+	cmd.Dir = repoRoot(t)
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	return stderr.Bytes(), err
+}
+
 // Test_Assemble_Invalid asserts that assembling an invalid source
 // returns a non-zero exit status and a helpful error message.
 func Test_Assemble_Invalid(t *testing.T) {
@@ -73,3 +87,144 @@ func Test_Assemble_MissingInput(t *testing.T) {
 
 	t.Logf("CLI output without -i (expected failure):\n%s", string(out))
 }
+
+// Test_Assemble_MultipleErrors asserts that the CLI reports every error of a
+// source in one run, followed by their count.
+func Test_Assemble_MultipleErrors(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "errors.s")
+	if err := os.WriteFile(src, []byte("NOP\nFOOBAR D0\nMOVE.W D0\nDC.B 300\nMOVE.B #$1234,D0\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out, err := runCLI(t, "-i", src, "-o", filepath.Join(t.TempDir(), "out.bin"))
+	if err == nil {
+		t.Fatalf("expected CLI to fail, but it succeeded.\nOUTPUT:\n%s", string(out))
+	}
+	for _, want := range []string{"unknown mnemonic", "expected comma", ".byte value out of 8-bit range", "line 5, col 8: immediate out of range for .b", "4 errors", "exit status 2"} {
+		if !bytes.Contains(out, []byte(want)) {
+			t.Fatalf("expected output to contain %q, got:\n%s", want, string(out))
+		}
+	}
+}
+
+// Test_Assemble_MaxErrorsOne asserts that --max-errors 1 still says that
+// errors were left out.
+func Test_Assemble_MaxErrorsOne(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "errors.s")
+	if err := os.WriteFile(src, []byte("FOOBAR D0\nMOVE.W D0\nDC.B 300\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out, err := runCLI(t, "-i", src, "-o", filepath.Join(t.TempDir(), "out.bin"), "--max-errors", "1")
+	if err == nil {
+		t.Fatalf("expected CLI to fail, but it succeeded.\nOUTPUT:\n%s", string(out))
+	}
+	if !bytes.Contains(out, []byte("unknown mnemonic")) || bytes.Contains(out, []byte("expected comma")) {
+		t.Fatalf("expected only the first error, got:\n%s", string(out))
+	}
+	if !bytes.Contains(out, []byte("1 error (more left out, see --max-errors)\n")) {
+		t.Fatalf("expected the summary to note the errors left out, got:\n%s", string(out))
+	}
+}
+
+const diagnosticsSource = "mission: NOP\nrd MACRO\nMOVE.W \\1,D0\nENDM\nrd missing\nMOVE.W $1001,D0\nDC.B 300\n"
+
+// Test_Assemble_DiagnosticsJSON asserts that --diagnostics-format=json
+// writes every warning and error with its position and code to stderr.
+func Test_Assemble_DiagnosticsJSON(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "diag.s")
+	if err := os.WriteFile(src, []byte(diagnosticsSource), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out, err := runCLIStderr(t, "-i", src, "-o", filepath.Join(t.TempDir(), "out.bin"), "--diagnostics-format=json")
+	if err == nil {
+		t.Fatalf("expected CLI to fail, but it succeeded.\nSTDERR:\n%s", out)
+	}
+	type frame struct {
+		Macro string
+		File  string
+		Line  int
+	}
+	var doc struct {
+		Diagnostics []struct {
+			File      string
+			Line      int
+			Column    int
+			EndColumn int `json:"end_column"`
+			Severity  string
+			Code      string
+			Message   string
+			Hint      string
+			Backtrace []frame
+		}
+	}
+	// go run appends its own exit status line after the document.
+	if err := json.NewDecoder(bytes.NewReader(out)).Decode(&doc); err != nil {
+		t.Fatalf("decoding diagnostics: %v\n%s", err, out)
+	}
+	if len(doc.Diagnostics) != 2 {
+		t.Fatalf("expected two errors, got:\n%s", out)
+	}
+	undef, rng := doc.Diagnostics[0], doc.Diagnostics[1]
+	if undef.File != src || undef.Line != 3 || undef.Severity != "error" || undef.Code != "undefined-symbol" ||
+		undef.Message != "undefined label in expression: missing" || undef.Hint != "did you mean mission?" {
+		t.Fatalf("unexpected diagnostic %+v", undef)
+	}
+	if len(undef.Backtrace) != 1 || undef.Backtrace[0] != (frame{Macro: "rd", File: src, Line: 5}) {
+		t.Fatalf("unexpected backtrace %+v", undef.Backtrace)
+	}
+	if rng.Line != 7 || rng.Column != 6 || rng.EndColumn != 9 || rng.Code != "out-of-range" {
+		t.Fatalf("unexpected diagnostic %+v", rng)
+	}
+}
+
+// Test_Assemble_DiagnosticsSARIF asserts that --diagnostics-format=sarif
+// writes a SARIF log with the warnings of a successful run.
+func Test_Assemble_DiagnosticsSARIF(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "warn.s")
+	if err := os.WriteFile(src, []byte("NOP\nMOVE.W $1001,D0\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out, err := runCLIStderr(t, "-i", src, "-o", filepath.Join(t.TempDir(), "out.bin"), "--diagnostics-format=sarif")
+	if err != nil {
+		t.Fatalf("CLI failed: %v\nSTDERR:\n%s", err, out)
+	}
+	var log struct {
+		Version string
+		Runs    []struct {
+			Tool struct {
+				Driver struct {
+					Name  string
+					Rules []struct{ ID string }
+				}
+			}
+			Results []struct {
+				RuleID    string
+				Level     string
+				Message   struct{ Text string }
+				Locations []struct {
+					PhysicalLocation struct {
+						ArtifactLocation struct{ URI string }
+						Region           struct{ StartLine int }
+					}
+				}
+			}
+		}
+	}
+	if err := json.Unmarshal(out, &log); err != nil {
+		t.Fatalf("decoding SARIF: %v\n%s", err, out)
+	}
+	if log.Version != "2.1.0" || len(log.Runs) != 1 || len(log.Runs[0].Results) != 1 {
+		t.Fatalf("unexpected SARIF log:\n%s", out)
+	}
+	run := log.Runs[0]
+	res := run.Results[0]
+	if run.Tool.Driver.Name != "m68kasm" || len(run.Tool.Driver.Rules) != 1 || run.Tool.Driver.Rules[0].ID != "odd-address" {
+		t.Fatalf("unexpected tool %+v", run.Tool)
+	}
+	if res.RuleID != "odd-address" || res.Level != "warning" || res.Message.Text != ".W access to odd address $1001" {
+		t.Fatalf("unexpected result %+v", res)
+	}
+	loc := res.Locations[0].PhysicalLocation
+	if !strings.HasPrefix(loc.ArtifactLocation.URI, "file:///") || !strings.HasSuffix(loc.ArtifactLocation.URI, "/warn.s") || loc.Region.StartLine != 2 {
+		t.Fatalf("unexpected location %+v", loc)
+	}
+}
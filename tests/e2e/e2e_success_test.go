@@ -1,6 +1,7 @@
 package e2e_test
 
 import (
+	"bytes"
 	"crypto/sha256"
 	"encoding/binary"
 	"encoding/hex"
@@ -74,6 +75,8 @@ func Test_Assemble_Hello_Listing(t *testing.T) {
 		"76 07",
 		"0x00000028",
 		"11 22 33 44",
+		"Symbol",
+		"start ",
 	} {
 		if !strings.Contains(listing, want) {
 			t.Fatalf("listing missing %q\n%s", want, listing)
@@ -109,6 +112,32 @@ func Test_Assemble_SRecord_Output(t *testing.T) {
 	}
 }
 
+// Test_Assemble_IntelHex_Output ensures the CLI can emit Intel HEX text.
+func Test_Assemble_IntelHex_Output(t *testing.T) {
+	root := repoRoot(t)
+	src := filepath.Join(root, "tests", "testdata", "hello.s")
+
+	outDir := t.TempDir()
+	out := filepath.Join(outDir, "out.hex")
+
+	cmd := exec.Command("go", "run", "./cmd/m68kasm", "-i", src, "-o", out, "--format", "ihex")
+	cmd.Dir = root
+	cmd.Env = append(os.Environ(), "CGO_ENABLED=0")
+	if outBytes, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("CLI failed: %v\nOUTPUT:\n%s", err, string(outBytes))
+	}
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("cannot read Intel HEX file: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if len(lines) < 3 || !strings.HasPrefix(lines[0], ":") || !strings.HasPrefix(lines[len(lines)-2], ":04000005") || lines[len(lines)-1] != ":00000001FF" {
+		t.Fatalf("unexpected Intel HEX output\n%s", data)
+	}
+}
+
 // Test_Assemble_ELF_Output ensures the CLI can emit an ELF32 image for m68k.
 func Test_Assemble_ELF_Output(t *testing.T) {
 	root := repoRoot(t)
@@ -205,3 +234,54 @@ func readELFString(table []byte, off uint32) string {
 	}
 	return string(table[off:end])
 }
+
+// Test_Assemble_GASSyntax checks that --syntax gas accepts MIT-style sources.
+func Test_Assemble_GASSyntax(t *testing.T) {
+	root := repoRoot(t)
+	src := filepath.Join(root, "tests", "testdata", "strcpy_gas.s")
+	out := filepath.Join(t.TempDir(), "out.bin")
+
+	if outBytes, err := runCLI(t, "-i", src, "-o", out, "--syntax", "gas"); err != nil {
+		t.Fatalf("CLI failed: %v\nOUTPUT:\n%s", err, string(outBytes))
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("cannot read output: %v", err)
+	}
+	want := []byte{0x22, 0x6F, 0x00, 0x04, 0x20, 0x6F, 0x00, 0x08, 0x12, 0xD8, 0x66, 0xFC, 0x4E, 0x75}
+	if !bytes.Equal(data, want) {
+		t.Fatalf("unexpected output: % X want % X", data, want)
+	}
+}
+
+// Test_Preprocess_Output checks that -E writes expanded source that assembles
+// to the same bytes as the original.
+func Test_Preprocess_Output(t *testing.T) {
+	root := repoRoot(t)
+	src := filepath.Join(root, "tests", "testdata", "devpac", "macros.s")
+	dir := t.TempDir()
+	expanded := filepath.Join(dir, "expanded.s")
+
+	if outBytes, err := runCLI(t, "-i", src, "-o", expanded, "--syntax", "devpac", "-E"); err != nil {
+		t.Fatalf("CLI failed: %v\nOUTPUT:\n%s", err, string(outBytes))
+	}
+	text, err := os.ReadFile(expanded)
+	if err != nil {
+		t.Fatalf("cannot read output: %v", err)
+	}
+	if !strings.Contains(string(text), "# 11 \""+src+"\"\n") || strings.Contains(string(text), "MACRO") {
+		t.Fatalf("unexpected preprocessed output:\n%s", text)
+	}
+
+	for _, in := range []string{src, expanded} {
+		out := filepath.Join(dir, filepath.Base(in)+".bin")
+		if outBytes, err := runCLI(t, "-i", in, "-o", out, "--syntax", "devpac"); err != nil {
+			t.Fatalf("CLI failed on %s: %v\nOUTPUT:\n%s", in, err, string(outBytes))
+		}
+	}
+	want, _ := os.ReadFile(filepath.Join(dir, "macros.s.bin"))
+	got, _ := os.ReadFile(filepath.Join(dir, "expanded.s.bin"))
+	if len(want) == 0 || !bytes.Equal(got, want) {
+		t.Fatalf("preprocessed source assembled to % X, want % X", got, want)
+	}
+}
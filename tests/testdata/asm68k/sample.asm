@@ -0,0 +1,71 @@
+* ---------------------------------------------------------------------------
+* Small Mega Drive style program in ASM68K syntax.
+* Written for the m68kasm test suite and released into the public domain.
+* ---------------------------------------------------------------------------
+
+		opt	l@			; '@' local labels
+
+VDP_data:	equ	$C00000
+VDP_ctrl:	equ	$C00004
+RAM_start	equ	$FF0000
+
+* Object status table layout
+		rsset	0
+obID:		rs.b	1
+obRender:	rs.b	1
+obGfx:		rs.w	1
+obX:		rs.l	1
+obSize:		equ	__rs
+
+* RAM variables
+		rsset	RAM_start
+v_objects:	rs.b	obSize*4
+v_frame:	rs.w	1
+v_end:		equ	__rs
+
+vdpReg:		macro	reg,val
+		move.w	#$8000|((reg)<<8)|(val),(a6)
+		endm
+
+vdpRegs:	macro
+		if narg>0
+		vdpReg	\*
+		endc
+		endm
+
+		inform	0,"object size is %d bytes",obSize
+
+Start:
+		move.w	#$2700,sr
+		lea	(VDP_ctrl).l,a6
+		vdpRegs	1,$74
+		vdpRegs
+		lea	(v_objects).l,a0
+		moveq	#(v_end-v_objects)/4-1,d0
+@clear:		clr.l	(a0)+
+		dbf	d0,@clear
+		bsr.s	CopyTiles
+		jmp	(RAM_start).l
+
+CopyTiles:
+		lea	Tiles(pc),a0
+		moveq	#TileSize/4-1,d1
+@loop:		move.l	(a0)+,(VDP_data).l
+		dbf	d1,@loop
+		rts
+
+* Code copied to RAM and run from there.
+RamCode:
+		obj	RAM_start
+@wait:		tst.w	(v_frame).l
+		beq.s	@wait
+		rts
+		objend
+RamCodeEnd:
+		even
+
+Title:		dc.b	"SAMPLE",0
+		even
+Tiles:		incbin	"tile.bin"
+TileSize:	equ	*-Tiles
+		dc.w	RamCodeEnd-RamCode
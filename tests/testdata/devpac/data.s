@@ -0,0 +1,24 @@
+* Data directives, strings, CNOP and section types.
+
+	SECTION	code,CODE
+	nop
+	CNOP	0,4
+	nop
+	CNOP	2,4
+	rts
+
+	SECTION	tables,DATA_F
+msg	dc.b	'Hello, ''World''',0
+	even
+tag	dc.l	'FORM'
+len	dc.l	*-msg
+	dc.w	"ab"
+	dcb.w	2,$4e71
+	ds.b	1
+	dc.b	$ff
+
+	SECTION	vars,BSS_C
+buf	ds.l	2
+	END
+
+	this line is never assembled
@@ -0,0 +1,17 @@
+*************************************************
+* Column-one labels, '*' comment lines and
+* .local labels scoped to the previous label.
+*************************************************
+
+start	moveq	#3,d0
+.loop	move.w	d0,(a0)+	; store counter
+	dbf	d0,.loop
+	bsr.s	sub
+	rts
+
+sub	moveq	#0,d1
+.loop	addq.w	#1,d1		; same local name, new scope
+	cmp.w	#3,d1
+	bne.s	.loop
+	rts
+end:	bra.s	start
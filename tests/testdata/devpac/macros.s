@@ -0,0 +1,38 @@
+* Devpac macros with \1..\9 parameters and conditional assembly.
+
+DEBUG	equ	0
+LEVEL	equ	2
+
+PAIR	MACRO
+	dc.w	\1,\2
+	ENDM
+
+CLEAR	macro
+	moveq	#0,\1
+	IFNE	\2
+	move.l	\1,\3
+	ENDC
+	endm
+
+	PAIR	1,2
+	CLEAR	d0,0
+	CLEAR	d1,1,d2
+
+	IFEQ	DEBUG
+	nop
+	ELSE
+	illegal
+	ENDC
+
+	IFGT	LEVEL-1
+	IFLT	LEVEL-3
+	dc.w	LEVEL
+	ENDC
+	ENDC
+
+	IFD	UNDEFINED_SYMBOL
+	this line is skipped
+	ENDC
+	IFND	UNDEFINED_SYMBOL
+	rts
+	ENDC
@@ -0,0 +1,16 @@
+* Macro size passthrough (\0), concatenation and quoted parameters.
+
+COPY	MACRO
+	move.\0	\1,\2
+	ENDM
+
+TAG	MACRO
+\1_tag	dc.b	'\1',0
+	ENDM
+
+	COPY.L	d0,d1
+	COPY.B	(a0)+,(a1)+
+	COPY	#1,d2
+	TAG	ab
+	even
+	dc.w	ab_tag
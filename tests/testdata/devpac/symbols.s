@@ -0,0 +1,27 @@
+* EQU/SET/=, EQUR/REG aliases and RS offset counters.
+
+SCREEN	equ	$f8000
+COUNT	=	4
+STEP	set	2
+ptr	equr	a2
+saved	reg	d2-d7/a2-a6
+
+	rsreset
+obj_x	rs.w	1
+obj_y	rs.w	1
+obj_flags	rs.b	1
+obj_len	rs.b	0
+
+	SECTION	main,CODE_C
+	opt	o+,w-
+	xdef	entry
+
+entry	movem.l	saved,-(sp)
+	lea	SCREEN,ptr
+	moveq	#COUNT-1,d0
+	move.w	obj_y(ptr),d1
+	move.b	obj_flags(ptr),d2
+	addq.w	#STEP,d1
+	movem.l	(sp)+,saved
+	rts
+	dc.w	obj_len
@@ -0,0 +1,10 @@
+| strcpy in GNU as (MIT) syntax
+# 1 "strcpy.S"
+	.text
+	.globl	strcpy
+strcpy:
+	movel	%sp@(4),%a1
+	movel	%sp@(8),%a0
+1:	moveb	%a0@+,%a1@+
+	jne	1b
+	rts